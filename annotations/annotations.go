@@ -0,0 +1,125 @@
+package annotations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// Rule annotates the groups matching any of its names or patterns
+// See prots.Annotation for the fields, SelfService is a pointer so that a
+// rule for one group can turn off what a pattern turned on
+type Rule struct {
+	Groups      []string `json:"groups"` // Group names or patterns, e.g. P_jam_*
+	Description string   `json:"description,omitempty"`
+	Team        string   `json:"team,omitempty"`
+	RequestURL  string   `json:"requestURL,omitempty"`
+	Chat        string   `json:"chat,omitempty"`
+	SelfService *bool    `json:"selfService,omitempty"`
+}
+
+// Annotations is an ordered list of rules
+// Rules naming a group exactly come before patterns, and each field is
+// taken from the first matching rule that sets it
+type Annotations struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Load reads an annotations file, an empty path annotates nothing
+func Load(file string) (*Annotations, error) {
+	a := &Annotations{}
+	if file == "" {
+		return a, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read annotations %s, %v", file, err)
+	}
+	if err := json.Unmarshal(b, a); err != nil {
+		return nil, fmt.Errorf("Failed to read annotations %s, %v", file, err)
+	}
+	for i, r := range a.Rules {
+		if err := r.check(); err != nil {
+			return nil, fmt.Errorf("Failed to read annotations %s, rule %d %v", file, i+1, err)
+		}
+	}
+	return a, nil
+}
+
+// check makes sure the rule can be used
+func (r Rule) check() error {
+	if len(r.Groups) == 0 {
+		return fmt.Errorf("needs at least one group name or pattern")
+	}
+	for _, g := range r.Groups {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("has a bad group pattern '%s'", g)
+		}
+	}
+	return nil
+}
+
+// Find merges the rules that apply to the group, nil when none do
+func (a *Annotations) Find(group string) *prots.Annotation {
+	if a == nil {
+		return nil
+	}
+	var out *prots.Annotation
+	var self *bool
+	for _, exact := range []bool{true, false} {
+		for _, r := range a.Rules {
+			if !r.matches(group, exact) {
+				continue
+			}
+			if out == nil {
+				out = &prots.Annotation{}
+			}
+			r.merge(out)
+			if self == nil {
+				self = r.SelfService
+			}
+		}
+	}
+	if self != nil {
+		out.SelfService = *self
+	}
+	return out
+}
+
+// Annotate sets the annotation of each group
+func (a *Annotations) Annotate(infos []prots.Info) {
+	for i := range infos {
+		infos[i].Annotation = a.Find(infos[i].Group)
+	}
+}
+
+func (r Rule) matches(group string, exact bool) bool {
+	for _, g := range r.Groups {
+		if exact && g == group {
+			return true
+		}
+		if ok, _ := path.Match(g, group); !exact && ok && g != group {
+			return true
+		}
+	}
+	return false
+}
+
+// merge fills in the fields of out that are still empty
+func (r Rule) merge(out *prots.Annotation) {
+	if out.Description == "" {
+		out.Description = r.Description
+	}
+	if out.Team == "" {
+		out.Team = r.Team
+	}
+	if out.RequestURL == "" {
+		out.RequestURL = r.RequestURL
+	}
+	if out.Chat == "" {
+		out.Chat = r.Chat
+	}
+}
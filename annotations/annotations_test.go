@@ -0,0 +1,70 @@
+package annotations
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAnnotations = `{"rules": [
+	{"groups": ["P_jam_*"], "team": "Jam", "chat": "#jam", "requestURL": "https://jam.example.com/access"},
+	{"groups": ["P_tools_*"], "description": "Shared build tools", "selfService": true},
+	{"groups": ["P_tools_secret"], "description": "Signing keys", "selfService": false},
+	{"groups": ["P_jam_main_rw"], "description": "Write access to Jam MAIN", "team": "Jam core"}
+]}`
+
+func load(t *testing.T, annotations string) (*Annotations, error) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(annotations), 0666))
+	return Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	a, err := load(t, testAnnotations)
+	assert.Nil(err)
+	assert.Len(a.Rules, 4)
+
+	a, err = Load("")
+	assert.Nil(err)
+	assert.Empty(a.Rules)
+
+	for rules, want := range map[string]string{
+		`{"team": "Jam"}`:                       "rule 1 needs at least one group name or pattern",
+		`{"groups": ["P_[jam"], "team": "Jam"}`: "rule 1 has a bad group pattern 'P_[jam'",
+	} {
+		_, err := load(t, `{"rules": [`+rules+`]}`)
+		assert.Error(err)
+		assert.Contains(err.Error(), want)
+	}
+	_, err = load(t, `{"rules": [`)
+	assert.Error(err)
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+	a, err := load(t, testAnnotations)
+	assert.Nil(err)
+	// The exact rule comes first, the pattern fills in the rest
+	assert.Equal(&prots.Annotation{
+		Description: "Write access to Jam MAIN",
+		Team:        "Jam core",
+		RequestURL:  "https://jam.example.com/access",
+		Chat:        "#jam",
+	}, a.Find("P_jam_main_rw"))
+	assert.Equal(&prots.Annotation{Description: "Shared build tools", SelfService: true}, a.Find("P_tools_cmake"))
+	// A group can be taken out of a self-service pattern
+	assert.Equal(&prots.Annotation{Description: "Signing keys"}, a.Find("P_tools_secret"))
+	assert.Nil(a.Find("P_other"))
+
+	var none *Annotations
+	assert.Nil(none.Find("P_jam_main_rw"))
+
+	infos := []prots.Info{{Group: "P_tools_cmake"}, {Group: "P_other"}}
+	a.Annotate(infos)
+	assert.True(infos[0].Annotation.SelfService)
+	assert.Nil(infos[1].Annotation)
+}
@@ -0,0 +1,151 @@
+package approvers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// Rule adds approvers to, or sets how many approvals are needed for,
+// the groups matching any of its patterns
+type Rule struct {
+	Name      string   `json:"name"`
+	Groups    []string `json:"groups"`              // Group name patterns, e.g. P_secret_*
+	Delegates []string `json:"delegates,omitempty"` // Users who can approve as well as the owners
+	Required  int      `json:"required,omitempty"`  // Approvals needed, 0 leaves it to a later rule
+}
+
+// Resolver works out who approves requests to join a group
+// Delegates from every matching rule are added to the group's owners,
+// the first matching rule that sets Required decides how many must approve
+type Resolver struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Set is everyone who can approve requests to join a group
+type Set struct {
+	Group     string        `json:"group"`
+	Owners    []prots.Owner `json:"owners"`              // From the group spec
+	Delegates []prots.Owner `json:"delegates,omitempty"` // From the approvers file, less any owners
+	Required  int           `json:"required"`
+}
+
+// Load reads an approvers file, an empty path leaves approval to a single owner
+func Load(file string) (*Resolver, error) {
+	r := &Resolver{}
+	if file == "" {
+		return r, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read approvers %s, %v", file, err)
+	}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("Failed to read approvers %s, %v", file, err)
+	}
+	for i, rule := range r.Rules {
+		if err := rule.check(); err != nil {
+			return nil, fmt.Errorf("Failed to read approvers %s, rule %d '%s' %v", file, i+1, rule.Name, err)
+		}
+	}
+	return r, nil
+}
+
+// check makes sure the rule can be used
+func (r Rule) check() error {
+	if len(r.Groups) == 0 {
+		return fmt.Errorf("needs at least one group pattern")
+	}
+	for _, g := range r.Groups {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("has a bad group pattern '%s'", g)
+		}
+	}
+	if r.Required < 0 {
+		return fmt.Errorf("can't require %d approvals", r.Required)
+	}
+	return nil
+}
+
+func (r Rule) matches(group string) bool {
+	for _, g := range r.Groups {
+		if ok, _ := path.Match(g, group); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve finds the group's owners and delegates and how many of them must approve
+func (r *Resolver) Resolve(p4r prots.P4Runner, group string) (Set, error) {
+	owners, err := prots.Owners(p4r, group)
+	if err != nil {
+		return Set{}, err
+	}
+	s := Set{Group: group, Owners: owners, Delegates: []prots.Owner{}, Required: 1}
+	if r == nil {
+		return s, nil
+	}
+	seen := map[string]bool{}
+	for _, o := range owners {
+		seen[o.User] = true
+	}
+	required := 0
+	for _, rule := range r.Rules {
+		if !rule.matches(group) {
+			continue
+		}
+		if required == 0 {
+			required = rule.Required
+		}
+		for _, d := range rule.Delegates {
+			if seen[d] {
+				continue
+			}
+			seen[d] = true
+			o, err := prots.GetUser(p4r, d)
+			if err != nil {
+				return Set{}, err
+			}
+			s.Delegates = append(s.Delegates, o)
+		}
+	}
+	if required > 0 {
+		s.Required = required
+	}
+	return s, nil
+}
+
+// All is the owners then the delegates
+func (s Set) All() []prots.Owner {
+	return append(append([]prots.Owner{}, s.Owners...), s.Delegates...)
+}
+
+// Can checks whether the user is one of the approvers
+func (s Set) Can(user string) bool {
+	for _, o := range s.All() {
+		if o.User == user {
+			return true
+		}
+	}
+	return false
+}
+
+// Reachable checks there are enough approvers besides the requester to ever
+// give the approvals needed, the group's owners aren't known until it is
+// resolved so the file can't say
+func (s Set) Reachable(requester string) error {
+	n := 0
+	for _, o := range s.All() {
+		if o.User != requester {
+			n++
+		}
+	}
+	if n > 0 && s.Required > n {
+		return fmt.Errorf("%s needs %d approvals but only %d people can approve it, please contact support", s.Group, s.Required, n)
+	}
+	return nil
+}
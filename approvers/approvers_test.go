@@ -0,0 +1,129 @@
+package approvers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type FakeP4Runner struct {
+	mock.Mock
+}
+
+// Mocks p4.Run, so we can run fake perforce commands
+func (mock *FakeP4Runner) Run(args []string) ([]map[interface{}]interface{}, error) {
+	ags := mock.Called(args)
+	return ags.Get(0).([]map[interface{}]interface{}), ags.Error(1)
+}
+
+// fakeUsers mocks 'p4 user -o' for each user
+func fakeUsers(fp4 *FakeP4Runner, users ...string) {
+	for _, u := range users {
+		fp4.On("Run", []string{"user", "-o", u}).Return(
+			[]map[interface{}]interface{}{{"User": u, "Email": u + "@email.com", "FullName": u}}, nil)
+	}
+}
+
+const testApprovers = `{"rules": [
+	{"name": "secret", "groups": ["P_secret_*"], "delegates": ["sec.lead"], "required": 2},
+	{"name": "leavers", "groups": ["P_secret_old", "P_jam"], "delegates": ["jam.lead", "old.owner"], "required": 3}
+]}`
+
+func load(t *testing.T, approvers string) (*Resolver, error) {
+	path := filepath.Join(t.TempDir(), "approvers.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(approvers), 0666))
+	return Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	r, err := load(t, testApprovers)
+	assert.Nil(err)
+	assert.Len(r.Rules, 2)
+
+	r, err = Load("")
+	assert.Nil(err)
+	assert.Empty(r.Rules)
+
+	for approvers, want := range map[string]string{
+		`{"rules": [{"name": "x", "required": 2}]}`:                     "rule 1 'x' needs at least one group pattern",
+		`{"rules": [{"name": "x", "groups": ["P_[a"]}]}`:                "rule 1 'x' has a bad group pattern 'P_[a'",
+		`{"rules": [{"name": "x", "groups": ["P_*"], "required": -1}]}`: "rule 1 'x' can't require -1 approvals",
+	} {
+		_, err := load(t, approvers)
+		assert.Error(err)
+		assert.Contains(err.Error(), want)
+	}
+	_, err = load(t, `{"rules": [`)
+	assert.Error(err)
+}
+
+func TestResolve(t *testing.T) {
+	assert := assert.New(t)
+	r, err := load(t, testApprovers)
+	assert.Nil(err)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_secret_old"}).Return(
+		[]map[interface{}]interface{}{{"Group": "P_secret_old", "Owners0": "old.owner"}}, nil).
+		On("Run", []string{"group", "-o", "P_other"}).Return(
+		[]map[interface{}]interface{}{{"Group": "P_other", "Owners0": "other.owner"}}, nil)
+	fakeUsers(fp4, "old.owner", "other.owner", "sec.lead", "jam.lead")
+
+	s, err := r.Resolve(fp4, "P_secret_old")
+	assert.Nil(err)
+	assert.Equal("P_secret_old", s.Group)
+	assert.Equal([]prots.Owner{{User: "old.owner", FullName: "old.owner", Email: "old.owner@email.com"}}, s.Owners)
+	// Delegates from both rules, the owner isn't repeated
+	assert.Equal([]prots.Owner{
+		{User: "sec.lead", FullName: "sec.lead", Email: "sec.lead@email.com"},
+		{User: "jam.lead", FullName: "jam.lead", Email: "jam.lead@email.com"},
+	}, s.Delegates)
+	// The first rule to set it wins
+	assert.Equal(2, s.Required)
+	assert.Len(s.All(), 3)
+	assert.True(s.Can("old.owner"))
+	assert.True(s.Can("jam.lead"))
+	assert.False(s.Can("other.owner"))
+
+	s, err = r.Resolve(fp4, "P_other")
+	assert.Nil(err)
+	assert.Empty(s.Delegates)
+	assert.Equal(1, s.Required)
+	assert.True(s.Can("other.owner"))
+
+	// Without a file it's just the owners
+	var none *Resolver
+	s, err = none.Resolve(fp4, "P_other")
+	assert.Nil(err)
+	assert.Equal(1, s.Required)
+	assert.Len(s.All(), 1)
+}
+
+func TestReachable(t *testing.T) {
+	assert := assert.New(t)
+	r, err := load(t, `{"rules": [{"name": "x", "groups": ["P_jam"], "delegates": ["jam.lead"], "required": 3}]}`)
+	assert.Nil(err)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_jam"}).Return(
+		[]map[interface{}]interface{}{{"Group": "P_jam", "Owners0": "jam.owner"}}, nil).Once().
+		On("Run", []string{"group", "-o", "P_jam"}).Return(
+		[]map[interface{}]interface{}{{"Group": "P_jam", "Owners0": "jam.owner", "Owners1": "second.owner"}}, nil)
+	fakeUsers(fp4, "jam.owner", "second.owner", "jam.lead")
+
+	s, err := r.Resolve(fp4, "P_jam")
+	assert.Nil(err)
+	assert.EqualError(s.Reachable("a.user"), "P_jam needs 3 approvals but only 2 people can approve it, please contact support")
+
+	// Another owner makes it possible, unless they are the one asking
+	s, err = r.Resolve(fp4, "P_jam")
+	assert.Nil(err)
+	assert.Nil(s.Reachable("a.user"))
+	assert.EqualError(s.Reachable("second.owner"), "P_jam needs 3 approvals but only 2 people can approve it, please contact support")
+
+	// Nobody at all is left to the caller
+	assert.Nil(Set{Group: "P_none", Required: 1}.Reachable("a.user"))
+}
@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+)
+
+// Entry is a single line of the audit log
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Event   string            `json:"event"`
+	Request io.Request        `json:"request"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Log appends entries to the audit log, one json object per line
+type Log struct {
+	path string
+	now  func() time.Time
+}
+
+// New returns a Log writing to the given file
+func New(path string) *Log {
+	return &Log{path, time.Now}
+}
+
+// Record writes an event along with the request that caused it
+func (l *Log) Record(event string, req io.Request, details map[string]string) error {
+	b, err := json.Marshal(Entry{l.now().UTC(), event, req, details})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// A single write with O_APPEND keeps concurrent broker invocations from
+	// interleaving their lines
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := New(path)
+	l.now = func() time.Time { return time.Date(2021, 3, 9, 10, 18, 1, 0, time.UTC) }
+	req := io.Request{Command: "access", User: "a.user", ClientIP: "10.0.0.1", Workspace: "a_ws"}
+
+	assert.Nil(l.Record("query", req, map[string]string{"path": "//depot/..."}))
+	assert.Nil(l.Record("query", req, nil))
+
+	out, err := ioutil.ReadFile(path)
+	assert.Nil(err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	assert.Len(lines, 2)
+	assert.Equal(`{"time":"2021-03-09T10:18:01Z","event":"query","request":{"command":"access","user":"a.user",`+
+		`"workspace":"a_ws","cwd":"","clientIp":"10.0.0.1","clientHost":"","clientProg":"","clientVersion":"",`+
+		`"brokerListenPort":"","brokerTargetPort":""},"details":{"path":"//depot/..."}}`, lines[0])
+}
@@ -0,0 +1,223 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/policy"
+	"github.com/brettbates/p4access/prots"
+)
+
+const accessHelp = `Finds the group(s) that give %s access to path and who owns them.
+Be as specific with the path as possible, it gives better results.
+The path can be in depot, client or local syntax.
+
+-v       Show the protections line that each group comes from
+-j       Respond with json instead of text
+-a       Show groups from every level of the path, not just the most specific
+-n max   Show at most max groups
+-u user  Find groups for another user, admins only`
+
+func init() {
+	for _, a := range []string{"read", "write"} {
+		Register(&Command{
+			Name:    a,
+			Usage:   a + " [-v -j -a -n max -u user] path",
+			Summary: "find the groups that give " + a + " access to a path",
+			Help:    fmt.Sprintf(accessHelp, a),
+			Broker:  true,
+			Direct:  true,
+			Run:     access,
+		})
+	}
+}
+
+// access finds the groups for 'p4 access read|write path'
+func access(e *Env) error {
+	a := &e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) == 0 {
+		return c.UsageError(e, "Missing path")
+	}
+	if len(a.Params) > 1 {
+		return c.UsageError(e, fmt.Sprintf("Too many arguments, expected one path, got %d", len(a.Params)))
+	}
+	a.ReqAccess = a.Command
+	cl, err := e.client()
+	if err != nil {
+		return err
+	}
+	a.Path, err = prots.Where(e.P4, cl, a.Params[0])
+	if err != nil {
+		return err
+	}
+	e.record("query", map[string]string{
+		"access": a.ReqAccess,
+		"path":   a.Path,
+		"as":     a.As,
+	})
+	res, err := prots.Protections(e.P4, a.Path)
+	if err != nil {
+		return err
+	}
+	var advice *prots.Advice
+	if a.All {
+		advice, err = res.AdviseAll(e.P4, cl, a.Target(), a.Path, a.ReqAccess)
+	} else {
+		advice, err = res.Advise(e.P4, cl, a.Target(), a.Path, a.ReqAccess)
+	}
+	if err != nil {
+		return err
+	}
+	infos, err := advice.AllInfo(e.P4, a.Path, a.ReqAccess)
+	if err != nil {
+		return err
+	}
+	infos, err = e.resolveOwners(infos)
+	if err != nil {
+		return err
+	}
+	e.Annotations.Annotate(infos)
+	infos, _, err = e.applyPolicy(a.Target(), infos)
+	if err != nil {
+		return err
+	}
+	e.hook(hookPayload{Event: "query", Access: a.ReqAccess, Path: a.Path, Context: advice.Context, Groups: infos})
+	io.ShowResults(infos, advice.Context, *a, e.Config)
+	return nil
+}
+
+// client checks -u is allowed and returns where the request came from
+func (e *Env) client() (prots.Client, error) {
+	cl := e.Args.Request.Client()
+	// Only admins may ask on behalf of someone else
+	if e.Args.As != "" {
+		admin, err := prots.IsAdmin(e.P4, e.Args.User)
+		if err != nil {
+			return cl, err
+		}
+		if !admin {
+			return cl, fmt.Errorf("Only admins can use -u, user %s is not an admin", e.Args.User)
+		}
+		// The admin's host says nothing about where the other user works from
+		cl.IP, cl.ProxyIP = "", ""
+	}
+	return cl, nil
+}
+
+// record writes to the audit log, failures are logged rather than stopping the command
+func (e *Env) record(event string, details map[string]string) {
+	if e.Audit == nil {
+		return
+	}
+	if err := e.Audit.Record(event, e.Args.Request, details); err != nil {
+		log.Printf("Failed to write audit log, %v", err)
+	}
+}
+
+// errNoGroups is returned when every group is left out, hidden groups are
+// reported the same way so they stay hidden
+var errNoGroups = errors.New("No matching groups found, try again with a more specific path")
+
+// applyPolicy hides, annotates and blocks groups for the user as the policy
+// says, recording each decision in the audit log
+// The groups that are shown but blocked from being requested are returned too
+func (e *Env) applyPolicy(user string, infos []prots.Info) ([]prots.Info, map[string]bool, error) {
+	blocked := map[string]bool{}
+	if e.Policy == nil || len(e.Policy.Rules) == 0 {
+		return infos, blocked, nil
+	}
+	s, err := e.Policy.Subject(e.P4, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, ds := e.Policy.Apply(s, infos)
+	for _, d := range ds {
+		if d.Action == policy.Block {
+			blocked[d.Group] = true
+		}
+		e.record("policy", map[string]string{
+			"for":     user,
+			"group":   d.Group,
+			"action":  d.Action,
+			"rule":    d.Rule,
+			"explain": d.Explain,
+		})
+	}
+	if len(out) == 0 {
+		// The same as when there are no groups at all, hidden groups stay hidden
+		return nil, nil, errNoGroups
+	}
+	return out, blocked, nil
+}
+
+// accounts reads the owners' users for checking them, nil when P4ACCESS_OWNERCHECK is off
+func (e *Env) accounts(owners []prots.Owner) (map[string]prots.Account, error) {
+	if e.Config.OwnerCheck != "hide" && e.Config.OwnerCheck != "demote" {
+		return nil, nil
+	}
+	users := []string{}
+	for _, o := range owners {
+		if !contains(users, o.User) {
+			users = append(users, o.User)
+		}
+	}
+	return prots.Accounts(e.P4, users)
+}
+
+// checkOwners classifies the owners, putting those who can't approve last or
+// hiding them as P4ACCESS_OWNERCHECK says
+// It reports whether there are owners but none of them can approve, owners
+// are unchanged without accounts
+func (e *Env) checkOwners(owners []prots.Owner, accounts map[string]prots.Account) ([]prots.Owner, bool) {
+	if accounts == nil {
+		return owners, false
+	}
+	out := prots.Classify(owners, accounts, time.Now().Add(-e.Config.StaleAfter))
+	usable := prots.UsableOwners(out)
+	if e.Config.OwnerCheck == "hide" {
+		out = usable
+	}
+	return out, len(owners) > 0 && len(usable) == 0
+}
+
+// resolveOwners checks the owners of each group, finding someone else to ask
+// about the groups none of them can approve, see fallback, or who to ask on
+// the LDAP side for groups 'p4 ldapsync' fills
+// Groups with no owners at all and nobody else to ask are left out
+func (e *Env) resolveOwners(infos []prots.Info) ([]prots.Info, error) {
+	// Looked up together, rather than a p4 command for each group
+	owners := []prots.Owner{}
+	for _, info := range infos {
+		owners = append(owners, info.Owners...)
+	}
+	accounts, err := e.accounts(owners)
+	if err != nil {
+		return nil, err
+	}
+	out := []prots.Info{}
+	for _, info := range infos {
+		named := len(info.Owners) > 0 || info.Ldap != nil
+		info.Owners, info.Unowned = e.checkOwners(info.Owners, accounts)
+		if info.Ldap != nil {
+			// LDAP decides who is in the group, not its owners
+			e.ldap(info.Ldap)
+		} else if len(prots.UsableOwners(info.Owners)) == 0 {
+			info.Fallback, info.Contacts, err = e.fallback(info)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !named && len(info.Contacts) == 0 {
+			continue
+		}
+		out = append(out, info)
+	}
+	if len(out) == 0 {
+		return nil, errNoGroups
+	}
+	return out, nil
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/annotations"
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAnnotations writes rules to an annotations file and loads it
+func testAnnotations(t *testing.T, rules string) *annotations.Annotations {
+	f := filepath.Join(t.TempDir(), "annotations.json")
+	if err := ioutil.WriteFile(f, []byte(`{"rules": [`+rules+`]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := annotations.Load(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+const annotateJamGroups = `{"groups": ["P_jam*"], "team": "Jam", "chat": "#jam"},
+	{"groups": ["P_jam_main"], "description": "Write access to Jam MAIN", "requestURL": "https://jam.example.com/access"},
+	{"groups": ["P_jam_main_rw"], "description": "Write access to \"jam\" MAIN", "selfService": true}`
+
+func TestAccessAnnotations(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	e := &Env{
+		Config:      testConfig(),
+		P4:          fp4,
+		Args:        io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}, All: true},
+		Annotations: testAnnotations(t, annotateJamGroups),
+		Mode:        io.Broker,
+	}
+	res, err := run(e)
+	assert.Nil(t, err)
+	check(t, "access_annotations.txt", res)
+
+	e.Args.JSON = true
+	e.Mode = io.Direct
+	res, err = run(e)
+	assert.Nil(t, err)
+	assert.Contains(t, res, `"annotation": {`)
+	assert.Contains(t, res, `"requestURL": "https://jam.example.com/access"`)
+}
+
+func TestOwnersAnnotations(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"groups", "-i", "-u", "a.user"}).Return([]map[interface{}]interface{}{}, nil)
+	FakeGroup(fp4, "P_jam_main_rw", "owner.first")
+	args := io.Args{User: "a.user", Command: "owners", Params: []string{"P_jam_main_rw"}}
+	res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Annotations: testAnnotations(t, annotateJamGroups), Mode: io.Broker})
+	assert.Nil(t, err)
+	check(t, "owners_annotations.txt", res)
+}
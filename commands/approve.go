@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brettbates/p4access/approvers"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "approve",
+		Usage:   "approve [-j] id [-e expiry] [-m comment]",
+		Summary: "approve a request to join a group you own",
+		Help: `Approves the request, adding the requester to the group straight away.
+Only the owners of the group, or its delegated approvers, can approve
+requests to join it. Some groups need several of them to approve, the
+requester is added once the last one has. The requester is told it has
+been approved.
+
+-e expiry   Only give access for this long, e.g. 7d, 2w or 12h,
+            'p4access expire' takes them out of the group afterwards
+-m comment  Passed on to the requester
+-j          Respond with json instead of text`,
+		Broker: true,
+		Direct: true,
+		Run:    decide,
+	})
+	Register(&Command{
+		Name:    "deny",
+		Usage:   "deny [-j] id -m reason",
+		Summary: "deny a request to join a group you own",
+		Help: `Denies the request, the requester is told why.
+Only the owners of the group, or its delegated approvers, can deny
+requests to join it, one denial is enough.
+
+-m reason  Why the request is denied, this is required
+-j         Respond with json instead of text`,
+		Broker: true,
+		Direct: true,
+		Run:    decide,
+	})
+}
+
+// decide approves or denies a request for 'p4 access approve|deny id'
+func decide(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) != 1 {
+		return c.UsageError(e, fmt.Sprintf("Expected a request ID, got %d arguments", len(a.Params)))
+	}
+	if a.As != "" {
+		return c.UsageError(e, fmt.Sprintf("-u can't be used, you can only %s as yourself", a.Command))
+	}
+	state := requests.Approved
+	if a.Command == "deny" {
+		state = requests.Denied
+		if a.Message == "" {
+			return c.UsageError(e, "A reason is required, add -m \"why it's denied\"")
+		}
+		if a.Expires != 0 {
+			return c.UsageError(e, "-e only applies to approvals")
+		}
+	}
+	// The decision and the change to the group are made inside the store's
+	// lock, so owners deciding at the same time each see the other's approval
+	var r *requests.Request
+	var set approvers.Set
+	changed := false
+	err := e.Store.Modify(a.Params[0], func(saved *requests.Request) error {
+		r = saved
+		var err error
+		set, err = e.Approvers.Resolve(e.P4, r.Info.Group)
+		if err != nil {
+			return err
+		}
+		if !set.Can(a.User) {
+			return fmt.Errorf("Only the owners of %s can %s request %s", r.Info.Group, a.Command, r.ID)
+		}
+		if r.Removal && a.Expires != 0 {
+			return c.UsageError(e, "-e doesn't apply to removals")
+		}
+		var g prots.Group
+		if state == requests.Approved {
+			g, err = prots.GetGroup(e.P4, r.Info.Group)
+			if err != nil {
+				return err
+			}
+			// 'p4 ldapsync' would undo the change, so no approval is recorded
+			if g.Ldap != nil {
+				e.ldap(g.Ldap)
+				return ldapError(g.Name, g.Ldap)
+			}
+		}
+		if state == requests.Denied {
+			err = r.Decide(state, a.User, a.Message)
+		} else if r.Removal {
+			// Taking someone out only ever needs one approver
+			err = r.Approve(a.User, a.Message, 1, time.Now())
+		} else if a.User == r.User && set.Required > 1 {
+			err = fmt.Errorf("Request %s needs %d approvals from people other than %s", r.ID, set.Required, r.User)
+		} else {
+			err = r.Approve(a.User, a.Message, set.Required, time.Now())
+		}
+		if err != nil {
+			return err
+		}
+		// Access runs from the final approval, not the first
+		r.Lasts(a.Expires, time.Now())
+		if r.State != requests.Approved {
+			return nil
+		}
+		member := contains(g.Users, r.User)
+		if r.Removal {
+			if err := prots.RemoveUser(e.P4, r.Info.Group, r.User); err != nil {
+				log.Printf("Failed to remove %s from %s for %s, %v", r.User, r.Info.Group, r.ID, err)
+				return fmt.Errorf("Failed to remove %s from %s, the request is still pending, please contact support", r.User, r.Info.Group)
+			}
+			changed = member
+		} else {
+			if err := prots.AddUser(e.P4, r.Info.Group, r.User); err != nil {
+				log.Printf("Failed to add %s to %s for %s, %v", r.User, r.Info.Group, r.ID, err)
+				return fmt.Errorf("Failed to add %s to %s, the request is still pending, please contact support", r.User, r.Info.Group)
+			}
+			changed = !member
+		}
+		return nil
+	})
+	if err != nil && changed {
+		// An unsaved approval would never expire, so the change is undone
+		log.Printf("Failed to save %s after changing %s, %v", r.ID, r.Info.Group, err)
+		undo := prots.RemoveUser
+		if r.Removal {
+			undo = prots.AddUser
+		}
+		if uerr := undo(e.P4, r.Info.Group, r.User); uerr != nil {
+			log.Printf("Failed to undo the change to %s for %s, %v", r.Info.Group, r.ID, uerr)
+			return fmt.Errorf("Failed to save request %s and to undo the change to %s, please contact support", r.ID, r.Info.Group)
+		}
+		return fmt.Errorf("Failed to save request %s, the request is still pending, please contact support", r.ID)
+	} else if err != nil {
+		return err
+	}
+	details := map[string]string{
+		"id":     r.ID,
+		"for":    r.User,
+		"group":  r.Info.Group,
+		"reason": a.Message,
+	}
+	if r.Expires != nil {
+		details["expires"] = r.Expires.Format(time.RFC3339)
+	}
+	if r.Required > 1 {
+		details["approvals"] = fmt.Sprintf("%d/%d", len(r.Approvals), r.Required)
+	}
+	if r.Removal {
+		details["removal"] = "true"
+	}
+	e.record(a.Command, details)
+	// Everyone else who could have decided needs to know
+	others := []prots.Owner{}
+	for _, o := range set.All() {
+		if o.User != a.User {
+			others = append(others, o)
+		}
+	}
+	if r.State == requests.Pending {
+		e.notify("approval", r, emails(others), fmt.Sprintf("Access request %s: approved by %s, %d more needed", r.ID, a.User, r.Needed()))
+	} else if r.Removal {
+		// The member didn't ask for it, only the approvers are told
+		subject := fmt.Sprintf("Removal %s: %s by %s", r.ID, r.State, r.Approver)
+		e.notify("decided", r, emails(others), subject)
+	} else {
+		requester, err := prots.GetUser(e.P4, r.User)
+		if err != nil {
+			log.Printf("Failed to find %s to tell them %s was %s, %v", r.User, r.ID, r.State, err)
+		}
+		subject := fmt.Sprintf("Access request %s: %s by %s", r.ID, r.State, r.Approver)
+		e.notify(a.Command, r, emails([]prots.Owner{requester}), subject)
+		e.notify("decided", r, emails(others), subject)
+	}
+	e.hook(hookPayload{Event: a.Command, Access: r.Info.Access, Path: r.Info.Path, Groups: []prots.Info{r.Info}, Request: r})
+	return io.Output(e.Config, a, a.Command, r)
+}
@@ -0,0 +1,332 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/approvers"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeStore returns a store holding a pending request R00000001 from a.user to join P_jam_main
+func FakeStore(t *testing.T) *requests.FileStore {
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	err := st.Add(&requests.Request{
+		ID:   "R00000001",
+		User: "a.user",
+		Info: prots.Info{
+			Path:   "//depot/Jam/MAIN/...",
+			Access: "write",
+			Group:  "P_jam_main",
+			Owners: []prots.Owner{{User: "main.owner", FullName: "main.owner", Email: "main.owner@email.com"}},
+		},
+		Justification: "Fixing bug 123",
+		State:         requests.Pending,
+		Created:       time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC),
+	})
+	assert.Nil(t, err)
+	return st
+}
+
+// FakeDecide mocks p4r so main.owner and second.owner own P_jam_main and adding a.user to it works
+func FakeDecide(fp4 *FakeP4Runner, addErr error) {
+	FakeGroup(fp4, "P_jam_main", "main.owner", "second.owner")
+	fp4.On("Run", []string{"user", "-o", "a.user"}).Return(
+		[]map[interface{}]interface{}{{"User": "a.user", "Email": "a.user@email.com", "FullName": "A User"}}, nil).
+		On("Input", []string{"group", "-i"}, groupSpec).
+		Return("Group P_jam_main updated.", addErr)
+}
+
+// groupSpec is P_jam_main with a.user added
+const groupSpec = "Group: P_jam_main\n\nOwners:\n\tmain.owner\n\tsecond.owner\n\nUsers:\n\ta.user\n\n"
+
+type decideTest struct {
+	user   string
+	cmd    string
+	params []string
+	msg    string
+	addErr error
+	want   string
+	state  string
+	err    error
+}
+
+var decideTests = []decideTest{
+	{"main.owner", "approve", []string{"R00000001"}, "", nil, "approve.txt", requests.Approved, nil},
+	{"main.owner", "deny", []string{"R00000001"}, "Use P_jam_read, you only need to read it", nil, "deny.txt", requests.Denied, nil},
+	{"main.owner", "deny", []string{"R00000001"}, "", nil, "", requests.Pending,
+		errors.New("A reason is required, add -m \"why it's denied\"\nUsage: p4 access deny [-j] id -m reason")},
+	{"main.owner", "approve", []string{}, "", nil, "", requests.Pending,
+		errors.New("Expected a request ID, got 0 arguments\nUsage: p4 access approve [-j] id [-e expiry] [-m comment]")},
+	{"main.owner", "approve", []string{"R99999999"}, "", nil, "", requests.Pending,
+		errors.New("No such request 'R99999999'")},
+	{"a.user", "approve", []string{"R00000001"}, "", nil, "", requests.Pending,
+		errors.New("Only the owners of P_jam_main can approve request R00000001")},
+	{"main.owner", "approve", []string{"R00000001"}, "", errors.New("p4 group -i failed, exit status 1"), "", requests.Pending,
+		errors.New("Failed to add a.user to P_jam_main, the request is still pending, please contact support")},
+}
+
+func TestDecide(t *testing.T) {
+	for _, tst := range decideTests {
+		fp4 := &FakeP4Runner{}
+		FakeDecide(fp4, tst.addErr)
+		fn := &FakeNotifier{}
+		st := FakeStore(t)
+		args := io.Args{User: tst.user, Command: tst.cmd, Params: tst.params, Message: tst.msg}
+		res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: fn, Store: st, Mode: io.Broker})
+		r, gerr := st.Get("R00000001")
+		assert.Nil(t, gerr)
+		assert.Equal(t, tst.state, r.State, tst.cmd)
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			assert.Empty(t, fn.events)
+			continue
+		}
+		assert.Nil(t, err)
+		check(t, tst.want, res)
+		assert.Equal(t, tst.user, r.Approver)
+		assert.Equal(t, tst.msg, r.Reason)
+		if tst.state == requests.Approved {
+			fp4.AssertCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+		} else {
+			fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+		}
+		assert.Len(t, fn.events, 2)
+		assert.Equal(t, tst.cmd, fn.events[0].Type)
+		assert.Equal(t, []string{"a.user@email.com"}, fn.events[0].To)
+		check(t, "notify_"+tst.want, fn.events[0].Body)
+		// The owner who decided isn't told about it
+		assert.Equal(t, "decided", fn.events[1].Type)
+		assert.Equal(t, []string{"second.owner@email.com"}, fn.events[1].To)
+		check(t, "notify_decided_"+tst.want, fn.events[1].Body)
+	}
+}
+
+func TestDecideAs(t *testing.T) {
+	for cmd, usage := range map[string]string{
+		"approve": "approve [-j] id [-e expiry] [-m comment]",
+		"deny":    "deny [-j] id -m reason",
+	} {
+		fp4 := &FakeP4Runner{}
+		FakeDecide(fp4, nil)
+		st := FakeStore(t)
+		args := io.Args{User: "main.owner", As: "second.owner", Command: cmd, Params: []string{"R00000001"}, Message: "No"}
+		_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: &FakeNotifier{}, Store: st, Mode: io.Broker})
+		assert.EqualError(t, err, "-u can't be used, you can only "+cmd+" as yourself\nUsage: p4 access "+usage)
+		r, err := st.Get("R00000001")
+		assert.Nil(t, err)
+		assert.Equal(t, requests.Pending, r.State, cmd)
+		fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+	}
+}
+
+func TestDecideTwice(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeDecide(fp4, nil)
+	st := FakeStore(t)
+	e := &Env{Config: testConfig(), P4: fp4, Notify: &FakeNotifier{}, Store: st, Mode: io.Broker}
+	e.Args = io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}}
+	_, err := run(e)
+	assert.Nil(t, err)
+	e.Args = io.Args{User: "main.owner", Command: "deny", Params: []string{"R00000001"}, Message: "Changed my mind"}
+	_, err = run(e)
+	assert.EqualError(t, err, "Request R00000001 has already been approved by main.owner")
+}
+
+// racingStore makes another change to a request or campaign just before
+// each Modify, as if someone else had decided it while this command was
+// starting up
+type racingStore struct {
+	*requests.FileStore
+	before         func(*requests.Request) error
+	beforeCampaign func(*requests.Campaign) error
+}
+
+func (s racingStore) Modify(id string, fn func(*requests.Request) error) error {
+	if err := s.FileStore.Modify(id, s.before); err != nil {
+		return err
+	}
+	return s.FileStore.Modify(id, fn)
+}
+
+func (s racingStore) ModifyCampaign(id string, fn func(*requests.Campaign) error) error {
+	if err := s.FileStore.ModifyCampaign(id, s.beforeCampaign); err != nil {
+		return err
+	}
+	return s.FileStore.ModifyCampaign(id, fn)
+}
+
+// failingStore runs each Modify and then fails to save it, as if the disk were full
+type failingStore struct {
+	*requests.FileStore
+}
+
+func (s failingStore) Modify(id string, fn func(*requests.Request) error) error {
+	return s.FileStore.Modify(id, func(r *requests.Request) error {
+		if err := fn(r); err != nil {
+			return err
+		}
+		return errors.New("no space left on device")
+	})
+}
+
+func TestDecideUnsaved(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	// P_jam_main until a.user is added, for the approvers, the check and the add
+	without := []map[interface{}]interface{}{{"Group": "P_jam_main", "Owners0": "main.owner", "Owners1": "second.owner"}}
+	with := []map[interface{}]interface{}{{"Group": "P_jam_main", "Owners0": "main.owner", "Owners1": "second.owner", "Users0": "a.user"}}
+	fp4.On("Run", []string{"group", "-o", "P_jam_main"}).Return(without, nil).Times(3).
+		On("Run", []string{"group", "-o", "P_jam_main"}).Return(with, nil).Once().
+		On("Input", []string{"group", "-i"}, "Group: P_jam_main\n\nOwners:\n\tmain.owner\n\tsecond.owner\n\n").
+		Return("Group P_jam_main updated.", nil)
+	FakeDecide(fp4, nil)
+	st := failingStore{FakeStore(t)}
+	fn := &FakeNotifier{}
+	args := io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}, Expires: time.Hour}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: fn, Store: st, Mode: io.Broker})
+	assert.EqualError(err, "Failed to save request R00000001, the request is still pending, please contact support")
+	// Access that would never expire is taken away again
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, "Group: P_jam_main\n\nOwners:\n\tmain.owner\n\tsecond.owner\n\n")
+	r, err := st.Get("R00000001")
+	assert.Nil(err)
+	assert.Equal(requests.Pending, r.State)
+	assert.Empty(fn.events)
+}
+
+func TestDecideRace(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeDecide(fp4, nil)
+	st := racingStore{FileStore: FakeStore(t), before: func(r *requests.Request) error {
+		return r.Decide(requests.Denied, "second.owner", "No")
+	}}
+	args := io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: &FakeNotifier{}, Store: st, Mode: io.Broker})
+	assert.EqualError(t, err, "Request R00000001 has already been denied by second.owner")
+	r, err := st.Get("R00000001")
+	assert.Nil(t, err)
+	assert.Equal(t, requests.Denied, r.State)
+	fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+}
+
+func TestApproveExpires(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeDecide(fp4, nil)
+	st := FakeStore(t)
+	fn := &FakeNotifier{}
+	e := &Env{Config: testConfig(), P4: fp4, Notify: fn, Store: st, Mode: io.Broker}
+	e.Args = io.Args{User: "main.owner", Command: "deny", Params: []string{"R00000001"}, Message: "No", Expires: time.Hour}
+	_, err := run(e)
+	assert.EqualError(err, "-e only applies to approvals\nUsage: p4 access deny [-j] id -m reason")
+
+	e.Args = io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}, Expires: 7 * 24 * time.Hour}
+	res, err := run(e)
+	assert.Nil(err)
+	r, err := st.Get("R00000001")
+	assert.Nil(err)
+	assert.Equal(requests.Approved, r.State)
+	assert.WithinDuration(time.Now().Add(7*24*time.Hour), *r.Expires, time.Minute)
+	until := r.Expires.Format("Mon 2 Jan 2006 15:04 MST")
+	assert.Contains(res, "Access lasts until "+until+", then a.user is taken out of the group again.")
+	assert.Contains(fn.events[0].Body, "Your access lasts until "+until)
+	assert.Contains(fn.events[1].Body, "Access lasts until "+until)
+}
+
+// twoApprovers needs two of P_jam_main's owners and jam.lead to approve
+var twoApprovers = &approvers.Resolver{Rules: []approvers.Rule{
+	{Name: "main", Groups: []string{"P_jam_*"}, Delegates: []string{"jam.lead"}, Required: 2},
+}}
+
+func TestApproveSeveral(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeDecide(fp4, nil)
+	fp4.On("Run", []string{"user", "-o", "jam.lead"}).Return(
+		[]map[interface{}]interface{}{{"User": "jam.lead", "Email": "jam.lead@email.com", "FullName": "jam.lead"}}, nil)
+	st := FakeStore(t)
+	fn := &FakeNotifier{}
+	e := &Env{Config: testConfig(), P4: fp4, Notify: fn, Store: st, Approvers: twoApprovers, Mode: io.Broker}
+
+	e.Args = io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}, Message: "Fine by me", Expires: 7 * 24 * time.Hour}
+	res, err := run(e)
+	assert.Nil(err)
+	check(t, "approve_partial.txt", res)
+	r, err := st.Get("R00000001")
+	assert.Nil(err)
+	assert.Equal(requests.Pending, r.State)
+	// Nothing expires until it's approved
+	assert.Nil(r.Expires)
+	assert.Equal(7*24*time.Hour, r.ExpiresIn)
+	assert.Equal(1, r.Needed())
+	fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+	assert.Len(fn.events, 1)
+	assert.Equal("approval", fn.events[0].Type)
+	assert.Equal([]string{"second.owner@email.com", "jam.lead@email.com"}, fn.events[0].To)
+	check(t, "notify_approval.txt", fn.events[0].Body)
+
+	_, err = run(e)
+	assert.EqualError(err, "main.owner has already approved request R00000001, it needs 1 more")
+
+	// The delegate makes it two, access runs from now
+	fn.events = nil
+	e.Args = io.Args{User: "jam.lead", Command: "approve", Params: []string{"R00000001"}}
+	_, err = run(e)
+	assert.Nil(err)
+	r, err = st.Get("R00000001")
+	assert.Nil(err)
+	assert.Equal(requests.Approved, r.State)
+	assert.WithinDuration(time.Now().Add(7*24*time.Hour), *r.Expires, time.Minute)
+	assert.Equal("jam.lead", r.Approver)
+	assert.Len(r.Approvals, 2)
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+	assert.Len(fn.events, 2)
+	assert.Equal([]string{"a.user@email.com"}, fn.events[0].To)
+	assert.Equal([]string{"main.owner@email.com", "second.owner@email.com"}, fn.events[1].To)
+}
+
+func TestApproveSeveralRace(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeDecide(fp4, nil)
+	fp4.On("Run", []string{"user", "-o", "jam.lead"}).Return(
+		[]map[interface{}]interface{}{{"User": "jam.lead", "Email": "jam.lead@email.com", "FullName": "jam.lead"}}, nil)
+	// jam.lead approves while main.owner's approve is starting up
+	st := racingStore{FileStore: FakeStore(t), before: func(r *requests.Request) error {
+		return r.Approve("jam.lead", "", 2, time.Now())
+	}}
+	args := io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: &FakeNotifier{}, Store: st, Approvers: twoApprovers, Mode: io.Broker})
+	assert.Nil(t, err)
+	r, err := st.Get("R00000001")
+	assert.Nil(t, err)
+	// Neither approval is lost, so it's approved
+	assert.Equal(t, requests.Approved, r.State)
+	assert.Len(t, r.Approvals, 2)
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+}
+
+func TestApproveSeveralDeny(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeDecide(fp4, nil)
+	fp4.On("Run", []string{"user", "-o", "jam.lead"}).Return(
+		[]map[interface{}]interface{}{{"User": "jam.lead", "Email": "jam.lead@email.com", "FullName": "jam.lead"}}, nil)
+	st := FakeStore(t)
+	e := &Env{Config: testConfig(), P4: fp4, Notify: &FakeNotifier{}, Store: st, Approvers: twoApprovers, Mode: io.Broker}
+	e.Args = io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}}
+	_, err := run(e)
+	assert.Nil(t, err)
+	// One denial is enough
+	e.Args = io.Args{User: "jam.lead", Command: "deny", Params: []string{"R00000001"}, Message: "Not this quarter"}
+	_, err = run(e)
+	assert.Nil(t, err)
+	r, err := st.Get("R00000001")
+	assert.Nil(t, err)
+	assert.Equal(t, requests.Denied, r.State)
+	fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, groupSpec)
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brettbates/p4access/annotations"
+	"github.com/brettbates/p4access/approvers"
+	"github.com/brettbates/p4access/audit"
+	"github.com/brettbates/p4access/config"
+	"github.com/brettbates/p4access/contacts"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/notify"
+	"github.com/brettbates/p4access/policy"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+// Env is shared by every command, so they all use the same config,
+// p4 connection and output
+type Env struct {
+	Config      config.Config
+	P4          prots.P4Writer
+	Args        io.Args
+	Audit       *audit.Log
+	Notify      notify.Notifier
+	Webhook     *notify.Webhook
+	Store       requests.Store
+	Policy      *policy.Policy
+	Approvers   *approvers.Resolver
+	Contacts    *contacts.Contacts
+	Annotations *annotations.Annotations
+	Mode        io.Mode
+}
+
+// Command is a single 'p4 access' subcommand
+type Command struct {
+	Name    string
+	Usage   string // e.g. "read [-v -j -a -n max -u user] path"
+	Summary string // One line for the command list
+	Help    string // Longer description shown by 'p4 access help <name>'
+	Broker  bool   // Can be run through the p4broker as 'p4 access <name>'
+	Direct  bool   // Can be run directly as 'p4access <name>'
+	Run     func(e *Env) error
+}
+
+// registry holds every command, keyed by name
+var registry = map[string]*Command{}
+
+// Register adds a command, each command registers itself from init
+func Register(c *Command) {
+	if _, ok := registry[c.Name]; ok {
+		panic("command registered twice: " + c.Name)
+	}
+	registry[c.Name] = c
+}
+
+// Lookup finds a registered command
+func Lookup(name string) (*Command, bool) {
+	c, ok := registry[name]
+	return c, ok
+}
+
+// List returns the registered commands sorted by name
+func List() []*Command {
+	out := []*Command{}
+	for _, c := range registry {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Run dispatches to the command named in the args
+func Run(e *Env) error {
+	a := e.Args
+	if a.Command == "" {
+		// 'p4 access -h'
+		if a.Help {
+			io.Respond(generalHelp(e))
+			return nil
+		}
+		return io.UsageError("Missing command", io.Usage)
+	}
+	c, ok := Lookup(a.Command)
+	if !ok || !c.allowed(e.Mode) {
+		return io.UsageError(fmt.Sprintf("Unknown command '%s'", a.Command), io.Usage)
+	}
+	if a.Help {
+		io.Respond(c.helpText(e.Mode))
+		return nil
+	}
+	return c.Run(e)
+}
+
+// allowed checks the command can be run the way we've been invoked
+func (c *Command) allowed(m io.Mode) bool {
+	if m == io.Direct {
+		return c.Direct
+	}
+	return c.Broker
+}
+
+// usage is the one line usage for the way we've been invoked
+func (c *Command) usage(m io.Mode) string {
+	if m == io.Direct {
+		return "Usage: p4access " + c.Usage
+	}
+	return "Usage: p4 access " + c.Usage
+}
+
+func (c *Command) helpText(m io.Mode) string {
+	return fmt.Sprintf("\n%s -- %s\n\n%s\n\n%s\n", c.Name, c.Summary, c.usage(m), indent(c.Help))
+}
+
+// UsageError reports bad arguments to the given command
+func (c *Command) UsageError(e *Env, msg string) error {
+	return io.UsageError(msg, c.usage(e.Mode))
+}
+
+func indent(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = "    " + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brettbates/p4access/config"
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type FakeP4Runner struct {
+	mock.Mock
+}
+
+// Mocks p4.Run, so we can run fake perforce commands
+func (mock *FakeP4Runner) Run(args []string) ([]map[interface{}]interface{}, error) {
+	ags := mock.Called(args)
+	return ags.Get(0).([]map[interface{}]interface{}), ags.Error(1)
+}
+
+// Mocks p4 commands that read a spec, e.g. 'p4 group -i'
+func (mock *FakeP4Runner) Input(args []string, spec string) (string, error) {
+	ags := mock.Called(args, spec)
+	return ags.String(0), ags.Error(1)
+}
+
+// testConfig points at the real templates in ../io
+func testConfig() config.Config {
+	return config.Config{
+		Results:   "../io/results.go.tpl",
+		Help:      "../io/help.txt",
+		Templates: "../io",
+	}
+}
+
+// FakeGroup mocks p4r so the group has the given owners
+func FakeGroup(fp4 *FakeP4Runner, group string, owners ...string) {
+	gret := []map[interface{}]interface{}{{"Group": group}}
+	for i, o := range owners {
+		gret[0][fmt.Sprintf("Owners%d", i)] = o
+		fp4.On("Run", []string{"user", "-o", o}).Return(
+			[]map[interface{}]interface{}{{"User": o, "Email": o + "@email.com", "FullName": o}}, nil)
+	}
+	fp4.On("Run", []string{"group", "-o", group}).Return(gret, nil)
+}
+
+// FakeAdmin mocks p4r so admin is a super user and the users are not
+func FakeAdmin(fp4 *FakeP4Runner, admin string, users ...string) {
+	fp4.On("Run", []string{"protects", "-M", "-u", admin, "//..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "super"}}, nil)
+	for _, u := range users {
+		fp4.On("Run", []string{"protects", "-M", "-u", u, "//..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": "write"}}, nil)
+	}
+}
+
+// run runs a command capturing what it responds with
+func run(e *Env) (string, error) {
+	var b bytes.Buffer
+	prev := io.SetOutput(&b)
+	defer io.SetOutput(prev)
+	io.SetMode(e.Mode)
+	defer io.SetMode(io.Broker)
+	err := Run(e)
+	return b.String(), err
+}
+
+// update rewrites the files in ./want, run 'go test ./commands -update' after
+// changing a template and check the diff
+var update = flag.Bool("update", false, "update the files in ./want")
+
+// check compares a response with the expected one in ./want
+func check(t *testing.T, name, res string) {
+	if *update {
+		if err := ioutil.WriteFile(filepath.Join("want", name), []byte(res), 0644); err != nil {
+			t.Fatalf("Failed to update %s, %v", name, err)
+		}
+	}
+	// This makes it easier to see line differences
+	assert.Equal(t, strings.Split(want(t, name), "\n"), strings.Split(res, "\n"), name)
+}
+
+// want reads an expected response from ./want
+func want(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join("want", name))
+	if err != nil {
+		t.Fatalf("Failed to read in file %s, %v", name, err)
+	}
+	return string(b)
+}
+
+type runTest struct {
+	mode io.Mode
+	args io.Args
+	err  error
+}
+
+var runTests = []runTest{
+	{
+		io.Broker,
+		io.Args{User: "usr"},
+		errors.New("Missing command\n" + io.Usage),
+	},
+	{
+		io.Broker,
+		io.Args{User: "usr", Command: "nope"},
+		errors.New("Unknown command 'nope'\n" + io.Usage),
+	},
+	{
+		io.Broker,
+		io.Args{User: "usr", Command: "read"},
+		errors.New("Missing path\nUsage: p4 access read [-v -j -a -n max -u user] path"),
+	},
+	{
+		io.Direct,
+		io.Args{User: "usr", Command: "write", Params: []string{"//a/...", "//b/..."}},
+		errors.New("Too many arguments, expected one path, got 2\nUsage: p4access write [-v -j -a -n max -u user] path"),
+	},
+	{
+		io.Broker,
+		io.Args{User: "usr", Command: "help", Params: []string{"nope"}},
+		errors.New("No such command 'nope', see 'p4 access help' for the list of commands"),
+	},
+}
+
+func TestRun(t *testing.T) {
+	for _, tst := range runTests {
+		e := &Env{Config: testConfig(), P4: &FakeP4Runner{}, Args: tst.args, Mode: tst.mode}
+		_, err := run(e)
+		assert.EqualError(t, err, tst.err.Error())
+	}
+}
+
+func TestGeneralHelp(t *testing.T) {
+	e := &Env{Config: testConfig(), Mode: io.Broker}
+	res := generalHelp(e)
+	assert := assert.New(t)
+	assert.True(strings.HasPrefix(res, io.HelpText(e.Config)))
+	assert.Contains(res, "    read       find the groups that give read access to a path\n")
+	assert.Contains(res, "    help       show help for p4 access or one of its commands\n")
+}
+
+func TestHelpText(t *testing.T) {
+	c, ok := Lookup("read")
+	assert.True(t, ok)
+	assert.Equal(t, "\nread -- find the groups that give read access to a path\n\n"+
+		"Usage: p4 access read [-v -j -a -n max -u user] path\n\n"+
+		"    Finds the group(s) that give read access to path and who owns them.\n"+
+		"    Be as specific with the path as possible, it gives better results.\n"+
+		"    The path can be in depot, client or local syntax.\n\n"+
+		"    -v       Show the protections line that each group comes from\n"+
+		"    -j       Respond with json instead of text\n"+
+		"    -a       Show groups from every level of the path, not just the most specific\n"+
+		"    -n max   Show at most max groups\n"+
+		"    -u user  Find groups for another user, admins only\n", c.helpText(io.Broker))
+}
+
+func TestAccess(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	pnone := []map[interface{}]interface{}{{"permMax": "none"}}
+	pwrite := []map[interface{}]interface{}{{"permMax": "write"}}
+	fp4.On("Run", []string{"-c", "a_ws", "where", "//a_ws/path/..."}).Return(
+		[]map[interface{}]interface{}{{"depotFile": "//depot/path/..."}}, nil).
+		On("Run", []string{"protects", "-a", "//depot/path/..."}).Return(
+		[]map[interface{}]interface{}{{
+			"perm":      "write",
+			"host":      "*",
+			"user":      "grp",
+			"isgroup":   "",
+			"line":      "1",
+			"depotFile": "//depot/...",
+		}}, nil).
+		On("Run", []string{"protects", "-M", "-u", "usr", "//depot/path/..."}).Return(pnone, nil).
+		On("Run", []string{"protects", "-M", "-g", "grp", "//depot/path/..."}).Return(pwrite, nil)
+	FakeGroup(fp4, "grp", "owner")
+
+	e := &Env{
+		Config: testConfig(),
+		P4:     fp4,
+		Args: io.Args{
+			Request: io.Request{User: "usr", Workspace: "a_ws"},
+			User:    "usr",
+			Command: "write",
+			Params:  []string{"//a_ws/path/..."},
+		},
+		Mode: io.Broker,
+	}
+	_, err := run(e)
+	assert.Nil(t, err)
+	assert.Equal(t, "write", e.Args.ReqAccess)
+	assert.Equal(t, "//depot/path/...", e.Args.Path)
+	fp4.AssertExpectations(t)
+}
+
+func TestAccessNotAdmin(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"protects", "-M", "-u", "usr", "//..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "write"}}, nil)
+	e := &Env{
+		Config: testConfig(),
+		P4:     fp4,
+		Args:   io.Args{User: "usr", Command: "read", Params: []string{"//depot/..."}, As: "other"},
+		Mode:   io.Broker,
+	}
+	_, err := run(e)
+	assert.EqualError(t, err, "Only admins can use -u, user usr is not an admin")
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "emergency",
+		Usage:   "emergency [-j] <read|write> path -m reason",
+		Summary: "break-glass access for on-call engineers during an incident",
+		Help: `Adds you to the break-glass group straight away, without waiting for an owner,
+for a short fixed time. Only members of the on-call group can use it.
+The owners of the path and security are alerted, and you are taken out of
+the group again by 'p4access expire' once the time is up. Nothing is
+given unless it can be written to the audit log first.
+
+-m reason  What the incident is, this is required
+-j         Respond with json instead of text`,
+		Broker: true,
+		Direct: true,
+		Run:    emergency,
+	})
+}
+
+// emergency gives break-glass access for 'p4 access emergency read|write path'
+func emergency(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	cfg := e.Config
+	if cfg.OnCall == "" || cfg.BreakGlass == "" {
+		return fmt.Errorf("Emergency access isn't set up, P4ACCESS_ONCALL and P4ACCESS_BREAKGLASS are needed")
+	}
+	if len(a.Params) != 2 {
+		return c.UsageError(e, fmt.Sprintf("Expected an access level and a path, got %d arguments", len(a.Params)))
+	}
+	reqAccess := a.Params[0]
+	if reqAccess != "read" && reqAccess != "write" {
+		return c.UsageError(e, fmt.Sprintf("Unknown access level '%s', must be read or write", reqAccess))
+	}
+	if a.Message == "" {
+		return c.UsageError(e, "A reason is required, add -m \"the incident\"")
+	}
+	if a.As != "" {
+		return c.UsageError(e, "-u can't be used, emergency access is only given to yourself")
+	}
+	groups, err := prots.UserGroups(e.P4, a.User)
+	if err != nil {
+		return err
+	}
+	if !contains(groups, cfg.OnCall) {
+		e.record("emergency-refused", map[string]string{"access": reqAccess, "path": a.Params[1], "reason": a.Message})
+		return fmt.Errorf("Only members of %s can use emergency access", cfg.OnCall)
+	}
+	path, err := prots.Where(e.P4, a.Request.Client(), a.Params[1])
+	if err != nil {
+		return err
+	}
+	ok, err := prots.GroupHasAccess(e.P4, cfg.BreakGlass, path, reqAccess)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s doesn't give %s access to %s, please contact support", cfg.BreakGlass, reqAccess, path)
+	}
+
+	r, err := requests.New(a.User, prots.Info{Path: path, Access: reqAccess, Group: cfg.BreakGlass}, a.Message)
+	if err != nil {
+		return err
+	}
+	if err := r.Decide(requests.Approved, a.User, a.Message); err != nil {
+		return err
+	}
+	r.Emergency = true
+	expires := time.Now().UTC().Add(cfg.BreakGlassFor)
+	r.Expires = &expires
+	// Someone already directly in the group keeps the access an earlier
+	// emergency gave them if anything goes wrong
+	g, err := prots.GetGroup(e.P4, cfg.BreakGlass)
+	if err != nil {
+		return err
+	}
+	member := contains(g.Users, a.User)
+	// Nothing is given that the audit log doesn't know about
+	if e.Audit != nil {
+		err := e.Audit.Record("emergency", a.Request, map[string]string{
+			"id":      r.ID,
+			"group":   cfg.BreakGlass,
+			"access":  reqAccess,
+			"path":    path,
+			"reason":  r.Justification,
+			"expires": expires.Format(time.RFC3339),
+		})
+		if err != nil {
+			log.Printf("Failed to write audit log for emergency %s, %v", r.ID, err)
+			return fmt.Errorf("Failed to write the audit log, emergency access can't be given without it, please contact support")
+		}
+	}
+	if err := prots.AddUser(e.P4, cfg.BreakGlass, a.User); err != nil {
+		log.Printf("Failed to add %s to %s for emergency %s, %v", a.User, cfg.BreakGlass, r.ID, err)
+		e.record("emergency-failed", map[string]string{"id": r.ID, "group": cfg.BreakGlass, "path": path, "reason": err.Error()})
+		return fmt.Errorf("Failed to add %s to %s, please contact support", a.User, cfg.BreakGlass)
+	}
+	// Unsaved access would never expire, so it is taken away again
+	if err := e.Store.Add(r); err != nil {
+		log.Printf("Failed to save emergency %s, %v", r.ID, err)
+		if !member {
+			if err := prots.RemoveUser(e.P4, cfg.BreakGlass, a.User); err != nil {
+				log.Printf("Failed to remove %s from %s after failing to save emergency %s, %v", a.User, cfg.BreakGlass, r.ID, err)
+			}
+		}
+		e.record("emergency-failed", map[string]string{"id": r.ID, "group": cfg.BreakGlass, "path": path, "reason": err.Error()})
+		return fmt.Errorf("Failed to save emergency access, please contact support")
+	}
+
+	// Alert whoever would normally have been asked
+	owners := e.pathOwners(a.User, path, reqAccess)
+	to := append(emails(owners), cfg.Security...)
+	e.notify("emergency", r, to,
+		fmt.Sprintf("EMERGENCY access %s: %s has %s access to %s", r.ID, r.User, reqAccess, path))
+	e.hook(hookPayload{Event: "emergency", Access: reqAccess, Path: path, Groups: []prots.Info{r.Info}, Request: r})
+	return io.Output(e.Config, a, "emergency", r)
+}
+
+// pathOwners finds the owners of every group giving reqAccess to path
+// Failures are logged, they mustn't get in the way of emergency access
+func (e *Env) pathOwners(user, path, reqAccess string) []prots.Owner {
+	out := []prots.Owner{}
+	ps, err := prots.Protections(e.P4, path)
+	if err != nil {
+		log.Printf("Failed to find the owners of %s, %v", path, err)
+		return out
+	}
+	adv, err := ps.AdviseAll(e.P4, prots.Client{}, user, path, reqAccess)
+	if err != nil {
+		log.Printf("Failed to find the owners of %s, %v", path, err)
+		return out
+	}
+	infos, err := adv.OutputInfo(e.P4, path, reqAccess)
+	if err != nil {
+		log.Printf("Failed to find the owners of %s, %v", path, err)
+		return out
+	}
+	seen := map[string]bool{}
+	for _, i := range infos {
+		for _, o := range i.Owners {
+			if !seen[o.User] {
+				seen[o.User] = true
+				out = append(out, o)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/audit"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// FakeEmergency mocks p4r so oncall.user is on call and G_breakglass gives
+// breakPerm to //depot/Jam/MAIN/...
+func FakeEmergency(fp4 *FakeP4Runner, breakPerm string) {
+	FakeRequest(fp4, "oncall.user")
+	fp4.On("Run", []string{"groups", "-i", "-u", "oncall.user"}).Return(
+		[]map[interface{}]interface{}{{"group": "G_oncall"}}, nil).
+		On("Run", []string{"groups", "-i", "-u", "a.user"}).Return(
+		[]map[interface{}]interface{}{{"group": "P_other"}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "G_breakglass", "//depot/Jam/MAIN/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": breakPerm}}, nil).
+		On("Run", []string{"group", "-o", "G_breakglass"}).Return(
+		[]map[interface{}]interface{}{{"Group": "G_breakglass", "Owners0": "security"}}, nil).
+		On("Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\nUsers:\n\toncall.user\n\n").
+		Return("Group G_breakglass updated.", nil)
+}
+
+func emergencyConfig() Env {
+	c := testConfig()
+	c.OnCall = "G_oncall"
+	c.BreakGlass = "G_breakglass"
+	c.BreakGlassFor = 4 * time.Hour
+	c.Security = []string{"security@email.com"}
+	return Env{Config: c, Mode: io.Broker}
+}
+
+func TestEmergency(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeEmergency(fp4, "write")
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	e := emergencyConfig()
+	e.P4, e.Notify, e.Store = fp4, fn, st
+	e.Args = io.Args{User: "oncall.user", Command: "emergency", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "INC-123 build is broken"}
+	res, err := run(&e)
+	assert.Nil(err)
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\nUsers:\n\toncall.user\n\n")
+
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(err)
+	assert.Len(saved, 1)
+	r := saved[0]
+	assert.True(r.Emergency)
+	assert.Equal(requests.Approved, r.State)
+	assert.Equal("oncall.user", r.Approver)
+	assert.Equal("G_breakglass", r.Info.Group)
+	assert.Equal("INC-123 build is broken", r.Justification)
+	assert.WithinDuration(time.Now().Add(4*time.Hour), *r.Expires, time.Minute)
+	// 'p4access expire' takes them out again
+	assert.True(r.Lapsed(time.Now().Add(5 * time.Hour)))
+
+	assert.Contains(res, "You are now in G_breakglass with write access to:")
+	assert.Contains(res, "this has been recorded as "+r.ID)
+	assert.Len(fn.events, 1)
+	assert.Equal("emergency", fn.events[0].Type)
+	// The most specific group's owners first, then security
+	assert.Equal([]string{"main.owner@email.com", "jam.owner@email.com", "security@email.com"}, fn.events[0].To)
+	assert.Contains(fn.events[0].Body, "oncall.user has used break-glass access")
+}
+
+func TestEmergencyUnsaved(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	// G_breakglass before (checked, then added to) and after oncall.user is added
+	fp4.On("Run", []string{"group", "-o", "G_breakglass"}).Return(
+		[]map[interface{}]interface{}{{"Group": "G_breakglass", "Owners0": "security"}}, nil).Twice().
+		On("Run", []string{"group", "-o", "G_breakglass"}).Return(
+		[]map[interface{}]interface{}{{"Group": "G_breakglass", "Owners0": "security", "Users0": "oncall.user"}}, nil).Once().
+		On("Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\n").
+		Return("Group G_breakglass updated.", nil)
+	FakeEmergency(fp4, "write")
+	fn := &FakeNotifier{}
+	e := emergencyConfig()
+	// The store can't be written inside a file
+	e.P4, e.Notify, e.Store = fp4, fn, requests.NewFileStore(filepath.Join("emergency.go", "requests.json"))
+	e.Args = io.Args{User: "oncall.user", Command: "emergency", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "INC-123"}
+	_, err := run(&e)
+	assert.EqualError(err, "Failed to save emergency access, please contact support")
+	// Access that would never expire is taken away again
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\nUsers:\n\toncall.user\n\n")
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\n")
+	assert.Empty(fn.events)
+}
+
+func TestEmergencyUnsavedIndirect(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	// In G_breakglass through G_oncall, which doesn't keep a direct member in it
+	fp4.On("Run", []string{"groups", "-i", "-u", "oncall.user"}).Return(
+		[]map[interface{}]interface{}{{"group": "G_oncall"}, {"group": "G_breakglass"}}, nil).
+		On("Run", []string{"group", "-o", "G_breakglass"}).Return(
+		[]map[interface{}]interface{}{{"Group": "G_breakglass", "Owners0": "security"}}, nil).Twice().
+		On("Run", []string{"group", "-o", "G_breakglass"}).Return(
+		[]map[interface{}]interface{}{{"Group": "G_breakglass", "Owners0": "security", "Users0": "oncall.user"}}, nil).Once().
+		On("Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\n").
+		Return("Group G_breakglass updated.", nil)
+	FakeEmergency(fp4, "write")
+	e := emergencyConfig()
+	e.P4, e.Notify, e.Store = fp4, &FakeNotifier{}, requests.NewFileStore(filepath.Join("emergency.go", "requests.json"))
+	e.Args = io.Args{User: "oncall.user", Command: "emergency", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "INC-123"}
+	_, err := run(&e)
+	assert.EqualError(err, "Failed to save emergency access, please contact support")
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\n")
+}
+
+func TestEmergencyUnaudited(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeEmergency(fp4, "write")
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	e := emergencyConfig()
+	// The audit log can't be written inside a file
+	e.P4, e.Notify, e.Store, e.Audit = fp4, fn, st, audit.New(filepath.Join("emergency.go", "audit.log"))
+	e.Args = io.Args{User: "oncall.user", Command: "emergency", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "INC-123"}
+	_, err := run(&e)
+	assert.EqualError(err, "Failed to write the audit log, emergency access can't be given without it, please contact support")
+	fp4.AssertNotCalled(t, "Input", mock.Anything, mock.Anything)
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(err)
+	assert.Empty(saved)
+	assert.Empty(fn.events)
+}
+
+type emergencyTest struct {
+	user      string
+	params    []string
+	msg       string
+	breakPerm string
+	err       error
+}
+
+var emergencyTests = []emergencyTest{
+	{"a.user", []string{"write", "//depot/Jam/MAIN/..."}, "INC-123", "write",
+		errors.New("Only members of G_oncall can use emergency access")},
+	{"oncall.user", []string{"write", "//depot/Jam/MAIN/..."}, "", "write",
+		errors.New("A reason is required, add -m \"the incident\"\nUsage: p4 access emergency [-j] <read|write> path -m reason")},
+	{"oncall.user", []string{"super", "//depot/Jam/MAIN/..."}, "INC-123", "write",
+		errors.New("Unknown access level 'super', must be read or write\nUsage: p4 access emergency [-j] <read|write> path -m reason")},
+	{"oncall.user", []string{"write", "//depot/Jam/MAIN/..."}, "INC-123", "read",
+		errors.New("G_breakglass doesn't give write access to //depot/Jam/MAIN/..., please contact support")},
+}
+
+func TestEmergencyRefused(t *testing.T) {
+	for _, tst := range emergencyTests {
+		fp4 := &FakeP4Runner{}
+		FakeEmergency(fp4, tst.breakPerm)
+		fn := &FakeNotifier{}
+		e := emergencyConfig()
+		e.P4, e.Notify = fp4, fn
+		e.Args = io.Args{User: tst.user, Command: "emergency", Params: tst.params, Message: tst.msg}
+		_, err := run(&e)
+		assert.EqualError(t, err, tst.err.Error())
+		fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, "Group: G_breakglass\n\nOwners:\n\tsecurity\n\nUsers:\n\toncall.user\n\n")
+		assert.Empty(t, fn.events)
+	}
+
+	// Only for yourself
+	fp4 := &FakeP4Runner{}
+	FakeEmergency(fp4, "write")
+	e := emergencyConfig()
+	e.P4 = fp4
+	e.Args = io.Args{User: "oncall.user", As: "a.user", Command: "emergency", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "INC-123"}
+	_, err := run(&e)
+	assert.EqualError(t, err, "-u can't be used, emergency access is only given to yourself\nUsage: p4 access emergency [-j] <read|write> path -m reason")
+	fp4.AssertNotCalled(t, "Input", mock.Anything, mock.Anything)
+
+	// Not set up
+	e = Env{Config: testConfig(), P4: &FakeP4Runner{}, Mode: io.Broker,
+		Args: io.Args{User: "oncall.user", Command: "emergency", Params: []string{"write", "//depot/..."}, Message: "INC-123"}}
+	_, err = run(&e)
+	assert.EqualError(t, err, "Emergency access isn't set up, P4ACCESS_ONCALL and P4ACCESS_BREAKGLASS are needed")
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "expire",
+		Usage:   "expire [-j]",
+		Summary: "take people out of groups once their approved time is up",
+		Help: `Finds the approvals given with -e that have lapsed, removes each user from
+the group with 'p4 group -i' and tells them and the group's owners.
+Run it from cron, it exits non-zero if any of them couldn't be removed.
+
+-j  Respond with json instead of text`,
+		Direct: true,
+		Run:    expire,
+	})
+}
+
+// expireInfo is fed to expire.go.tpl
+type expireInfo struct {
+	Expired []*requests.Request `json:"expired"`
+	Failed  []*requests.Request `json:"failed"`
+}
+
+// expire removes lapsed approvals for 'p4access expire'
+func expire(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) != 0 {
+		return c.UsageError(e, fmt.Sprintf("Too many arguments, expected none, got %d", len(a.Params)))
+	}
+	rs, err := e.Store.List(requests.Filter{State: requests.Approved})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	out := expireInfo{[]*requests.Request{}, []*requests.Request{}}
+	for _, r := range rs {
+		if !r.Lapsed(now) {
+			continue
+		}
+		expired, err := e.expireOne(r, now)
+		if err != nil {
+			log.Printf("Failed to expire %s, %v", r.ID, err)
+			out.Failed = append(out.Failed, r)
+			continue
+		}
+		if expired != nil {
+			out.Expired = append(out.Expired, expired)
+		}
+	}
+	if err := io.Output(e.Config, a, "expire", out); err != nil {
+		return err
+	}
+	if len(out.Failed) > 0 {
+		ids := []string{}
+		for _, r := range out.Failed {
+			ids = append(ids, r.ID)
+		}
+		return fmt.Errorf("Failed to expire %s, see the log", strings.Join(ids, ", "))
+	}
+	return nil
+}
+
+// expireOne takes the user out of the group and records it
+// It returns nil if another run has already expired the request
+func (e *Env) expireOne(r *requests.Request, now time.Time) (*requests.Request, error) {
+	// Another approval may still be keeping them in the group, e.g. a second emergency
+	others, err := e.Store.List(requests.Filter{User: r.User, Group: r.Info.Group, State: requests.Approved})
+	if err != nil {
+		return nil, err
+	}
+	keep := false
+	for _, o := range others {
+		if o.ID != r.ID && !o.Removal && !o.Lapsed(now) {
+			keep = true
+		}
+	}
+	// Claimed inside the store's lock, so overlapping runs can't both
+	// expire it and nothing that changed since the List is lost
+	var expired *requests.Request
+	err = e.Store.Modify(r.ID, func(saved *requests.Request) error {
+		if !saved.Lapsed(now) {
+			return nil
+		}
+		expired = saved
+		return saved.Expire(now)
+	})
+	if err != nil || expired == nil {
+		return nil, err
+	}
+	r = expired
+	if !keep {
+		if err := prots.RemoveUser(e.P4, r.Info.Group, r.User); err != nil {
+			// Put back so the next run tries again
+			uerr := e.Store.Modify(r.ID, func(saved *requests.Request) error {
+				saved.State = requests.Approved
+				saved.Removed = nil
+				return nil
+			})
+			if uerr != nil {
+				log.Printf("Failed to put %s back to approved, %v", r.ID, uerr)
+			}
+			return nil, err
+		}
+	}
+	e.record("expire", map[string]string{
+		"id":    r.ID,
+		"for":   r.User,
+		"group": r.Info.Group,
+	})
+	people := []prots.Owner{}
+	if u, err := prots.GetUser(e.P4, r.User); err == nil {
+		people = append(people, u)
+	} else {
+		log.Printf("Failed to find %s to tell them %s has expired, %v", r.User, r.ID, err)
+	}
+	if owners, err := prots.Owners(e.P4, r.Info.Group); err == nil {
+		people = append(people, owners...)
+	} else {
+		log.Printf("Failed to find the owners of %s to tell them %s has expired, %v", r.Info.Group, r.ID, err)
+	}
+	e.notify("expire", r, emails(people),
+		fmt.Sprintf("Access request %s: %s's access through %s has expired", r.ID, r.User, r.Info.Group))
+	e.hook(hookPayload{Event: "expire", Access: r.Info.Access, Path: r.Info.Path, Groups: []prots.Info{r.Info}, Request: r})
+	return r, nil
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// FakeExpiries returns a store where R00000001 has lapsed, R00000002 lasts
+// until 2099, R00000003 never expires and R00000004 has lapsed for a group
+// that has since been deleted
+func FakeExpiries(t *testing.T) *requests.FileStore {
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	lapsed := time.Date(2021, 3, 16, 10, 0, 0, 0, time.UTC)
+	later := time.Date(2099, 3, 16, 10, 0, 0, 0, time.UTC)
+	for _, r := range []*requests.Request{
+		{ID: "R00000001", User: "a.user", Expires: &lapsed, Info: prots.Info{Group: "P_jam_main"}},
+		{ID: "R00000002", User: "b.user", Expires: &later, Info: prots.Info{Group: "P_jam_main"}},
+		{ID: "R00000003", User: "c.user", Info: prots.Info{Group: "P_jam_main"}},
+		{ID: "R00000004", User: "d.user", Expires: &lapsed, Info: prots.Info{Group: "P_gone"}},
+	} {
+		r.State = requests.Approved
+		r.Approver = "main.owner"
+		r.Info.Path = "//depot/Jam/MAIN/..."
+		r.Info.Access = "write"
+		r.Created = time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)
+		assert.Nil(t, st.Add(r))
+	}
+	return st
+}
+
+func TestExpire(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_jam_main"}).Return([]map[interface{}]interface{}{{
+		"Group":   "P_jam_main",
+		"Owners0": "main.owner",
+		"Users0":  "a.user",
+		"Users1":  "b.user",
+	}}, nil).
+		On("Input", []string{"group", "-i"}, "Group: P_jam_main\n\nOwners:\n\tmain.owner\n\nUsers:\n\tb.user\n\n").
+		Return("Group P_jam_main updated.", nil).
+		On("Run", []string{"group", "-o", "P_gone"}).Return([]map[interface{}]interface{}{}, nil)
+	for _, u := range []string{"a.user", "main.owner"} {
+		fp4.On("Run", []string{"user", "-o", u}).Return(
+			[]map[interface{}]interface{}{{"User": u, "Email": u + "@email.com", "FullName": u}}, nil)
+	}
+	fn := &FakeNotifier{}
+	st := FakeExpiries(t)
+	e := &Env{Config: testConfig(), P4: fp4, Args: io.Args{Command: "expire"}, Notify: fn, Store: st, Mode: io.Direct}
+	res, err := run(e)
+	assert.EqualError(err, "Failed to expire R00000004, see the log")
+	check(t, "expire.txt", res)
+
+	fp4.AssertNumberOfCalls(t, "Input", 1)
+	r, err := st.Get("R00000001")
+	assert.Nil(err)
+	assert.Equal(requests.Expired, r.State)
+	assert.NotNil(r.Removed)
+	for id, state := range map[string]string{"R00000002": requests.Approved, "R00000003": requests.Approved, "R00000004": requests.Approved} {
+		r, err := st.Get(id)
+		assert.Nil(err)
+		assert.Equal(state, r.State, id)
+	}
+
+	assert.Len(fn.events, 1)
+	assert.Equal("expire", fn.events[0].Type)
+	assert.Equal([]string{"a.user@email.com", "main.owner@email.com"}, fn.events[0].To)
+	check(t, "notify_expire.txt", fn.events[0].Body)
+
+	// Nothing left to do the second time
+	res, err = run(e)
+	assert.EqualError(err, "Failed to expire R00000004, see the log")
+	fp4.AssertNumberOfCalls(t, "Input", 1)
+
+	e.Mode = io.Broker
+	_, err = run(e)
+	assert.EqualError(err, errors.New("Unknown command 'expire'\n"+io.Usage).Error())
+}
+
+func TestExpireKeepsActive(t *testing.T) {
+	assert := assert.New(t)
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	lapsed := time.Date(2021, 3, 16, 10, 0, 0, 0, time.UTC)
+	later := time.Date(2099, 3, 16, 10, 0, 0, 0, time.UTC)
+	// A second emergency while the first was still running
+	for _, r := range []*requests.Request{
+		{ID: "R00000001", User: "oncall.user", Expires: &lapsed},
+		{ID: "R00000002", User: "oncall.user", Expires: &later},
+	} {
+		r.State = requests.Approved
+		r.Emergency = true
+		r.Info = prots.Info{Group: "G_breakglass"}
+		assert.Nil(st.Add(r))
+	}
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"user", "-o", "oncall.user"}).Return([]map[interface{}]interface{}{{"User": "oncall.user"}}, nil).
+		On("Run", []string{"group", "-o", "G_breakglass"}).Return([]map[interface{}]interface{}{{"Group": "G_breakglass"}}, nil)
+	e := &Env{Config: testConfig(), P4: fp4, Args: io.Args{Command: "expire"}, Store: st, Mode: io.Direct}
+	_, err := run(e)
+	assert.Nil(err)
+	fp4.AssertNotCalled(t, "Input", mock.Anything, mock.Anything)
+	r, err := st.Get("R00000001")
+	assert.Nil(err)
+	assert.Equal(requests.Expired, r.State)
+}
+
+func TestExpireRaced(t *testing.T) {
+	assert := assert.New(t)
+	later := time.Date(2099, 3, 16, 10, 0, 0, 0, time.UTC)
+	// Extended by someone else after it was listed
+	st := racingStore{FileStore: FakeExpiries(t), before: func(r *requests.Request) error {
+		r.Expires = &later
+		return nil
+	}}
+	fp4 := &FakeP4Runner{}
+	fn := &FakeNotifier{}
+	e := &Env{Config: testConfig(), P4: fp4, Args: io.Args{Command: "expire"}, Notify: fn, Store: st, Mode: io.Direct}
+	_, err := run(e)
+	assert.Nil(err)
+	fp4.AssertNotCalled(t, "Input", mock.Anything, mock.Anything)
+	assert.Empty(fn.events)
+	for _, id := range []string{"R00000001", "R00000004"} {
+		r, err := st.Get(id)
+		assert.Nil(err)
+		assert.Equal(requests.Approved, r.State, id)
+		assert.Equal(&later, r.Expires, id)
+	}
+}
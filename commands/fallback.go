@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// fallback goes through P4ACCESS_OWNERFALLBACK for someone to ask about a
+// group that has no owners who can approve, returning where they came from
+// and who they are, or nothing when every step comes up empty
+func (e *Env) fallback(info prots.Info) (string, []prots.Owner, error) {
+	var depot *prots.Depot
+	depotOf := func() (prots.Depot, error) {
+		if depot == nil {
+			d, err := prots.DepotOf(e.P4, info.Path)
+			if err != nil {
+				return d, err
+			}
+			depot = &d
+		}
+		return *depot, nil
+	}
+	for _, step := range e.Config.OwnerFallback {
+		switch step {
+		case "depot":
+			d, err := depotOf()
+			if err != nil {
+				return "", nil, err
+			}
+			ows, err := e.usableUser(d.Owner)
+			if err != nil || len(ows) > 0 {
+				return "the owner of depot //" + d.Name, ows, err
+			}
+		case "stream":
+			d, err := depotOf()
+			if err != nil {
+				return "", nil, err
+			}
+			s := d.Stream(info.Path)
+			if s == "" {
+				continue
+			}
+			owner, err := prots.StreamOwner(e.P4, s)
+			if err != nil {
+				return "", nil, err
+			}
+			ows, err := e.usableUser(owner)
+			if err != nil || len(ows) > 0 {
+				return "the owner of stream " + s, ows, err
+			}
+		case "parents":
+			parents, err := prots.ParentGroups(e.P4, info.Group)
+			if err != nil {
+				return "", nil, err
+			}
+			for _, p := range parents {
+				ows, err := prots.Owners(e.P4, p)
+				if err != nil {
+					return "", nil, err
+				}
+				accounts, err := e.accounts(ows)
+				if err != nil {
+					return "", nil, err
+				}
+				ows, _ = e.checkOwners(ows, accounts)
+				if usable := prots.UsableOwners(ows); len(usable) > 0 {
+					return "the owners of parent group " + p, usable, nil
+				}
+			}
+		case "contacts":
+			if o, ok := e.Contacts.Find(info.Path); ok {
+				return o.FullName, []prots.Owner{o}, nil
+			}
+		case "support":
+			if e.Config.Support != "" {
+				return "support", []prots.Owner{{FullName: "Support", Email: e.Config.Support}}, nil
+			}
+		default:
+			return "", nil, fmt.Errorf("Unknown owner fallback '%s', expected depot, stream, parents, contacts or support", step)
+		}
+	}
+	return "", nil, nil
+}
+
+// usableUser looks up a user named in a spec, nothing if there isn't one
+// or they can't approve
+func (e *Env) usableUser(user string) ([]prots.Owner, error) {
+	if user == "" {
+		return nil, nil
+	}
+	o, err := prots.GetUser(e.P4, user)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := e.accounts([]prots.Owner{o})
+	if err != nil {
+		return nil, err
+	}
+	ows, _ := e.checkOwners([]prots.Owner{o}, accounts)
+	return prots.UsableOwners(ows), nil
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/contacts"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeFallback mocks p4r for FakeRequest where main.owner is a service user,
+// //depot is owned by depot.owner and P_jam_main is a subgroup of P_jam
+func FakeFallback(fp4 *FakeP4Runner) {
+	FakeRequest(fp4, "a.user")
+	FakeAccounts(fp4, map[string]string{"jam.owner": "standard", "main.owner": "service", "depot.owner": "standard"})
+	fp4.On("Run", []string{"depot", "-o", "depot"}).Return([]map[interface{}]interface{}{
+		{"Depot": "depot", "Owner": "depot.owner", "Type": "local", "Date": "2021/03/09 10:00:00"},
+	}, nil).
+		On("Run", []string{"user", "-o", "depot.owner"}).Return(
+		[]map[interface{}]interface{}{{"User": "depot.owner", "Email": "depot.owner@email.com", "FullName": "depot.owner"}}, nil).
+		On("Run", []string{"groups", "-i", "-g", "P_jam_main"}).Return(
+		[]map[interface{}]interface{}{{"group": "P_jam"}}, nil)
+}
+
+// testContacts writes rules to a contacts file and loads it
+func testContacts(t *testing.T, rules string) *contacts.Contacts {
+	f := filepath.Join(t.TempDir(), "contacts.json")
+	if err := ioutil.WriteFile(f, []byte(`{"rules": [`+rules+`]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := contacts.Load(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type fallbackTest struct {
+	steps []string
+	want  string
+	err   error
+}
+
+var fallbackTests = []fallbackTest{
+	{[]string{"depot", "parents"}, "ask the owner of depot //depot instead: \n     \n        depot.owner: depot.owner@email.com", nil},
+	{[]string{"stream", "parents"}, "ask the owners of parent group P_jam instead: \n     \n        jam.owner: jam.owner@email.com", nil},
+	{[]string{"contacts", "support"}, "ask the Jam team instead: \n     \n        the Jam team: jam@email.com", nil},
+	{[]string{"support"}, "ask support instead: \n     \n        Support: support@email.com", nil},
+	{[]string{"owners"}, "", errors.New("Unknown owner fallback 'owners', expected depot, stream, parents, contacts or support")},
+}
+
+func TestAccessFallback(t *testing.T) {
+	for _, tst := range fallbackTests {
+		fp4 := &FakeP4Runner{}
+		FakeFallback(fp4)
+		c := testConfig()
+		c.OwnerCheck = "demote"
+		c.StaleAfter = 90 * 24 * time.Hour
+		c.OwnerFallback = tst.steps
+		c.Support = "support@email.com"
+		e := &Env{
+			Config:   c,
+			P4:       fp4,
+			Args:     io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}},
+			Contacts: testContacts(t, `{"name": "the Jam team", "paths": ["//depot/Jam/..."], "email": "jam@email.com"}`),
+			Mode:     io.Broker,
+		}
+		res, err := run(e)
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Contains(t, res, tst.want)
+	}
+}
+
+func TestAccessFallbackGolden(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeFallback(fp4)
+	c := testConfig()
+	c.OwnerCheck = "demote"
+	c.StaleAfter = 90 * 24 * time.Hour
+	c.OwnerFallback = []string{"depot"}
+	e := &Env{
+		Config: c,
+		P4:     fp4,
+		Args:   io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}, All: true},
+		Mode:   io.Broker,
+	}
+	res, err := run(e)
+	assert.Nil(t, err)
+	check(t, "access_fallback.txt", res)
+}
+
+func TestAccessFallbackOwnerless(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeProtects(fp4, "a.user")
+	FakeGroup(fp4, "P_jam", "jam.owner")
+	// Nobody owns P_jam_main and no fallback is configured, so it is left out
+	FakeGroup(fp4, "P_jam_main")
+	e := &Env{
+		Config: testConfig(),
+		P4:     fp4,
+		Args:   io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}, All: true},
+		Mode:   io.Broker,
+	}
+	res, err := run(e)
+	assert.Nil(t, err)
+	assert.NotContains(t, res, "P_jam_main")
+
+	// With support to fall back on it is shown
+	e.Config.OwnerFallback = []string{"support"}
+	e.Config.Support = "support@email.com"
+	res, err = run(e)
+	assert.Nil(t, err)
+	assert.Contains(t, res, "Group P_jam_main grants write access")
+	assert.Contains(t, res, "ask support instead")
+}
+
+func TestRequestFallback(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeFallback(fp4)
+	c := testConfig()
+	c.OwnerCheck = "demote"
+	c.StaleAfter = 90 * 24 * time.Hour
+	c.OwnerFallback = []string{"depot"}
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	e := &Env{Config: c, P4: fp4, Notify: fn, Store: st, Mode: io.Broker}
+
+	// Nobody can approve P_jam_main, so the request goes to P_jam
+	e.Args = io.Args{User: "a.user", Command: "request", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "Because"}
+	_, err := run(e)
+	assert.Nil(err)
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(err)
+	assert.Len(saved, 1)
+	assert.Equal("P_jam", saved[0].Info.Group)
+	assert.Equal([]string{"jam.owner@email.com"}, fn.events[0].To)
+
+	e.Args.Params = []string{"write", "//depot/Jam/MAIN/...", "P_jam_main"}
+	_, err = run(e)
+	assert.EqualError(err, "None of the owners of P_jam_main can approve requests, ask the owner of depot //depot instead: depot.owner@email.com")
+
+	// Delegates can still approve
+	e.Approvers = twoApprovers
+	fp4.On("Run", []string{"user", "-o", "jam.lead"}).Return(
+		[]map[interface{}]interface{}{{"User": "jam.lead", "Email": "jam.lead@email.com", "FullName": "jam.lead"}}, nil)
+	_, err = run(e)
+	assert.Nil(err)
+}
+
+func TestOwnersFallback(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"groups", "-i", "-u", "a.user"}).Return([]map[interface{}]interface{}{}, nil).
+		On("Run", []string{"groups", "-i", "-g", "P_jam_main_rw"}).Return([]map[interface{}]interface{}{}, nil)
+	fp4.On("Run", []string{"group", "-o", "P_jam_main_rw"}).Return(
+		[]map[interface{}]interface{}{{"Group": "P_jam_main_rw", "Users0": "some.user"}}, nil)
+	c := testConfig()
+	c.OwnerFallback = []string{"parents", "support"}
+	c.Support = "support@email.com"
+	args := io.Args{User: "a.user", Command: "owners", Params: []string{"P_jam_main_rw"}}
+	res, err := run(&Env{Config: c, P4: fp4, Args: args, Mode: io.Broker})
+	assert.Nil(t, err)
+	check(t, "owners_fallback.txt", res)
+}
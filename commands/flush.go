@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/notify"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "flush",
+		Usage:   "flush",
+		Summary: "send the notifications and webhooks queued while they were down",
+		Help: `Tries again to send every notification and webhook in the spool
+(P4ACCESS_SPOOL), those that still fail stay queued for next time.
+Run it from cron.`,
+		Direct: true,
+		Run:    flush,
+	})
+}
+
+// flush sends queued notifications for 'p4access flush'
+func flush(e *Env) error {
+	c, _ := Lookup(e.Args.Command)
+	if len(e.Args.Params) != 0 {
+		return c.UsageError(e, fmt.Sprintf("Too many arguments, expected none, got %d", len(e.Args.Params)))
+	}
+	s, ok := e.Notify.(*notify.Spool)
+	if !ok && !e.Webhook.Queues() {
+		io.Respond("Notifications aren't queued, set P4ACCESS_SMTPHOST or P4ACCESS_NOTIFYCMD and P4ACCESS_SPOOL")
+		return nil
+	}
+	details := map[string]string{}
+	out := []string{}
+	if ok {
+		sent, left, err := s.Flush()
+		if err != nil {
+			return err
+		}
+		details["sent"], details["left"] = fmt.Sprint(sent), fmt.Sprint(left)
+		out = append(out, fmt.Sprintf("Sent %d queued notifications, %d still queued", sent, left))
+	}
+	if e.Webhook.Queues() {
+		sent, left, err := e.Webhook.Flush()
+		if err != nil {
+			return err
+		}
+		details["hooksSent"], details["hooksLeft"] = fmt.Sprint(sent), fmt.Sprint(left)
+		out = append(out, fmt.Sprintf("Posted %d queued webhooks, %d still queued", sent, left))
+	}
+	e.record("flush", details)
+	io.Respond(strings.Join(out, "\n"))
+	return nil
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlush(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	// A notification queued while mail was down
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "20210309T101801.000000000-00000001.json"),
+		[]byte(`{"type": "request", "to": ["main.owner@email.com"], "subject": "Access request R00000001", "body": "hi"}`), 0666))
+	fn := &FakeNotifier{}
+	e := &Env{Config: testConfig(), Args: io.Args{Command: "flush"}, Notify: &notify.Spool{Notifier: fn, Dir: dir}, Mode: io.Direct}
+	res, err := run(e)
+	assert.Nil(err)
+	assert.Equal("Sent 1 queued notifications, 0 still queued\n", res)
+	assert.Equal([]notify.Event{{Type: "request", To: []string{"main.owner@email.com"}, Subject: "Access request R00000001", Body: "hi"}}, fn.events)
+
+	// Webhooks queue alongside them, this one is down for the first post
+	posts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if posts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	e.Webhook = &notify.Webhook{URLs: []string{srv.URL}, Spool: filepath.Join(dir, "webhooks")}
+	assert.Nil(e.Webhook.Post("query", []byte(`{"event": "query"}`)))
+	res, err = run(e)
+	assert.Nil(err)
+	assert.Equal("Sent 0 queued notifications, 0 still queued\nPosted 1 queued webhooks, 0 still queued\n", res)
+	assert.Equal(2, posts)
+
+	e.Notify, e.Webhook = notify.Log{}, nil
+	res, err = run(e)
+	assert.Nil(err)
+	assert.Equal("Notifications aren't queued, set P4ACCESS_SMTPHOST or P4ACCESS_NOTIFYCMD and P4ACCESS_SPOOL\n", res)
+
+	// Only from a shell
+	e.Mode = io.Broker
+	_, err = run(e)
+	assert.EqualError(err, errors.New("Unknown command 'flush'\n"+io.Usage).Error())
+}
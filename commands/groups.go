@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "groups",
+		Usage:   "groups [-j -u user] [path]",
+		Summary: "show your groups and what each of them grants",
+		Help: `Lists every group you are in, directly or through a subgroup, along with
+the protections lines that name each group: the permission, path and host.
+Lines starting with - are exclusions.
+
+Give a path to only see the lines that apply to it.
+
+-j       Respond with json instead of text
+-u user  Show the groups of another user, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    groups,
+	})
+}
+
+// userGroup is one of the user's groups and what it grants
+type userGroup struct {
+	Group  string      `json:"group"`
+	Direct bool        `json:"direct"`
+	Prots  prots.Prots `json:"prots"`
+}
+
+// groupsInfo is fed to groups.go.tpl
+type groupsInfo struct {
+	User   string      `json:"user"`
+	Path   string      `json:"path,omitempty"`
+	Groups []userGroup `json:"groups"`
+}
+
+// groups lists the user's groups for 'p4 access groups [path]'
+func groups(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) > 1 {
+		return c.UsageError(e, fmt.Sprintf("Too many arguments, expected at most one path, got %d", len(a.Params)))
+	}
+	cl, err := e.client()
+	if err != nil {
+		return err
+	}
+	path := ""
+	if len(a.Params) == 1 {
+		path, err = prots.Where(e.P4, cl, a.Params[0])
+		if err != nil {
+			return err
+		}
+	}
+	all, err := prots.UserGroups(e.P4, a.Target())
+	if err != nil {
+		return err
+	}
+	direct, err := prots.DirectGroups(e.P4, a.Target())
+	if err != nil {
+		return err
+	}
+	ps, err := prots.Protections(e.P4, path)
+	if err != nil {
+		return err
+	}
+	out := groupsInfo{a.Target(), path, []userGroup{}}
+	for _, g := range all {
+		out.Groups = append(out.Groups, userGroup{g, contains(direct, g), ps.ForGroup(g)})
+	}
+	return io.Output(e.Config, a, "groups", out)
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+)
+
+var groupsProtects = []map[interface{}]interface{}{
+	{"perm": "write", "host": "*", "user": "P_jam_rw", "isgroup": "", "line": "3", "depotFile": "//depot/Jam/..."},
+	{"perm": "read", "host": "*", "user": "P_all_ro", "isgroup": "", "line": "4", "depotFile": "//depot/..."},
+	{"perm": "write", "host": "*", "user": "a.user", "line": "5", "depotFile": "//depot/Jam/..."},
+	{"perm": "write", "host": "10.0.0.*", "user": "P_jam_rw", "isgroup": "", "line": "6", "depotFile": "//depot/Jam/REL/...", "unmap": ""},
+}
+
+type groupsTest struct {
+	args    io.Args
+	protect []string
+	want    string
+}
+
+var groupsTests = []groupsTest{
+	{
+		io.Args{User: "a.user", Command: "groups"},
+		[]string{"protects", "-a"},
+		"groups.txt",
+	},
+	{
+		io.Args{User: "a.user", Command: "groups", Params: []string{"//depot/Jam/MAIN/..."}},
+		[]string{"protects", "-a", "//depot/Jam/MAIN/..."},
+		"groups_path.txt",
+	},
+	{
+		io.Args{User: "a.user", Command: "groups", JSON: true},
+		[]string{"protects", "-a"},
+		"groups.json",
+	},
+}
+
+func TestGroups(t *testing.T) {
+	for _, tst := range groupsTests {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"groups", "-i", "-u", "a.user"}).Return(
+			[]map[interface{}]interface{}{{"group": "P_jam_rw"}, {"group": "P_all_ro"}, {"group": "P_nothing"}}, nil).
+			On("Run", []string{"groups", "-u", "a.user"}).Return(
+			[]map[interface{}]interface{}{{"group": "P_jam_rw"}, {"group": "P_nothing"}}, nil).
+			On("Run", tst.protect).Return(groupsProtects, nil)
+		res, err := run(&Env{Config: testConfig(), P4: fp4, Args: tst.args, Mode: io.Direct})
+		assert.Nil(t, err)
+		check(t, tst.want, res)
+	}
+}
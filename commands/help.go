@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brettbates/p4access/io"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "help",
+		Usage:   "help [command]",
+		Summary: "show help for p4 access or one of its commands",
+		Help:    "With no command, lists every command. Otherwise shows the usage and help for that command.",
+		Broker:  true,
+		Direct:  true,
+		Run:     help,
+	})
+}
+
+func help(e *Env) error {
+	switch len(e.Args.Params) {
+	case 0:
+		io.Respond(generalHelp(e))
+		return nil
+	case 1:
+		c, ok := Lookup(e.Args.Params[0])
+		if !ok || !c.allowed(e.Mode) {
+			return fmt.Errorf("No such command '%s', see 'p4 access help' for the list of commands", e.Args.Params[0])
+		}
+		io.Respond(c.helpText(e.Mode))
+		return nil
+	}
+	c, _ := Lookup("help")
+	return c.UsageError(e, "Too many arguments")
+}
+
+// generalHelp is the help file followed by the commands we can run
+func generalHelp(e *Env) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(io.HelpText(e.Config), "\n"))
+	b.WriteString("\n\nCommands:\n\n")
+	for _, c := range List() {
+		if c.allowed(e.Mode) {
+			fmt.Fprintf(&b, "    %-10s %s\n", c.Name, c.Summary)
+		}
+	}
+	b.WriteString("\nSee 'p4 access help <command>' for more on each command.\n")
+	return b.String()
+}
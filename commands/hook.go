@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+// hookPayload is posted to the webhooks as json, or fed to webhook_<event>.go.tpl
+type hookPayload struct {
+	Event   string            `json:"event"` // query, request, approve or deny
+	Time    time.Time         `json:"time"`
+	User    string            `json:"user"` // Who ran the command
+	From    io.Request        `json:"from"`
+	Access  string            `json:"access,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Context string            `json:"context,omitempty"`
+	Groups  []prots.Info      `json:"groups,omitempty"`
+	Request *requests.Request `json:"request,omitempty"`
+}
+
+// hook posts the event to the webhooks that want it
+// Like notifications, failures are logged rather than failing the command
+func (e *Env) hook(p hookPayload) {
+	if !e.Webhook.Wants(p.Event) {
+		return
+	}
+	p.Time = time.Now().UTC()
+	p.User = e.Args.User
+	p.From = e.Args.Request
+	body, err := e.hookBody(p)
+	if err != nil {
+		log.Printf("Failed to make %s webhook, %v", p.Event, err)
+		return
+	}
+	if err := e.Webhook.Post(p.Event, body); err != nil {
+		log.Printf("Failed to post %s webhook, %v", p.Event, err)
+	}
+}
+
+// hookBody renders webhook_<event>.go.tpl if there is one, e.g. to suit a chat bot,
+// otherwise it is the payload as json
+func (e *Env) hookBody(p hookPayload) ([]byte, error) {
+	name := "webhook_" + p.Event
+	if _, err := os.Stat(filepath.Join(e.Config.Templates, name+".go.tpl")); err == nil {
+		out, err := io.Render(e.Config, name, p)
+		return []byte(out), err
+	}
+	return json.Marshal(p)
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/notify"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// hookServer keeps the payloads posted to it
+func hookServer(t *testing.T, posted *[]hookPayload, sigs *[]bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var p hookPayload
+		assert.Nil(t, json.Unmarshal(b, &p))
+		*posted = append(*posted, p)
+		*sigs = append(*sigs, r.Header.Get("X-P4Access-Signature") == notify.Sign("secret", b))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRequestHook(t *testing.T) {
+	assert := assert.New(t)
+	var posted []hookPayload
+	var signed []bool
+	srv := hookServer(t, &posted, &signed)
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	args := io.Args{Request: io.Request{User: "a.user", ClientHost: "a-host"}, User: "a.user", Command: "request",
+		Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "Fixing bug 123"}
+	wh := &notify.Webhook{URLs: []string{srv.URL}, Secret: "secret", Events: []string{"request", "approve"}}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Webhook: wh, Store: st, Mode: io.Broker})
+	assert.Nil(err)
+
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(err)
+	assert.Len(posted, 1)
+	assert.Equal([]bool{true}, signed)
+	p := posted[0]
+	assert.Equal("request", p.Event)
+	assert.Equal("a.user", p.User)
+	assert.Equal("a-host", p.From.ClientHost)
+	assert.Equal("write", p.Access)
+	assert.Equal("//depot/Jam/MAIN/...", p.Path)
+	assert.Equal(saved[0].ID, p.Request.ID)
+	assert.Equal("P_jam_main", p.Groups[0].Group)
+	assert.Equal([]prots.Owner{{User: "main.owner", FullName: "main.owner", Email: "main.owner@email.com"}}, p.Groups[0].Owners)
+	assert.False(p.Time.IsZero())
+}
+
+func TestQueryHook(t *testing.T) {
+	assert := assert.New(t)
+	var posted []hookPayload
+	var signed []bool
+	srv := hookServer(t, &posted, &signed)
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	args := io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}}
+	e := &Env{Config: testConfig(), P4: fp4, Args: args, Mode: io.Broker}
+	e.Webhook = &notify.Webhook{URLs: []string{srv.URL}, Events: []string{"request"}}
+	_, err := run(e)
+	assert.Nil(err)
+	assert.Empty(posted)
+
+	e.Webhook.Events = []string{"query"}
+	_, err = run(e)
+	assert.Nil(err)
+	assert.Len(posted, 1)
+	assert.Equal("query", posted[0].Event)
+	assert.Nil(posted[0].Request)
+	assert.Equal("P_jam_main", posted[0].Groups[0].Group)
+	// Not signed without a secret
+	assert.Equal([]bool{false}, signed)
+}
+
+func TestHookTemplate(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "webhook_query.go.tpl"),
+		[]byte(`{"text": {{ json (printf "%s asked about %s \"%s\"" .User .Access .Path) }}}`), 0666))
+	c := testConfig()
+	c.Templates = dir
+	e := &Env{Config: c}
+	p := hookPayload{Event: "query", User: "a.user", Access: "write", Path: "//depot/..."}
+	b, err := e.hookBody(p)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"text": "a.user asked about write \"//depot/...\""}`, string(b))
+
+	// Without a template it's the payload as json
+	p.Event = "request"
+	b, err = e.hookBody(p)
+	assert.Nil(t, err)
+	var got hookPayload
+	assert.Nil(t, json.Unmarshal(b, &got))
+	assert.Equal(t, p, got)
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/policy"
+	"github.com/brettbates/p4access/prots"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "join",
+		Usage:   "join [-j -u user] group",
+		Summary: "add yourself to a self-service group",
+		Help: `Adds you to the group straight away, without asking its owners. Only groups
+that P4ACCESS_ANNOTATIONS marks as self-service, and P4ACCESS_POLICY doesn't
+hide or block for any path the group gives access to, can be joined this
+way, for anything else use 'p4 access request'. The group's owners are told.
+
+-j       Respond with json instead of text
+-u user  Add another user, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    join,
+	})
+	Register(&Command{
+		Name:    "leave",
+		Usage:   "leave [-j -u user] group",
+		Summary: "take yourself out of a self-service group",
+		Help: `Takes you out of the group straight away. Only groups that
+P4ACCESS_ANNOTATIONS marks as self-service can be left this way, and only if
+you are named in the group rather than one of its subgroups. The group's
+owners are told.
+
+-j       Respond with json instead of text
+-u user  Take another user out, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    join,
+	})
+}
+
+// joinInfo is fed to join.go.tpl and leave.go.tpl, and their notify_ templates
+type joinInfo struct {
+	User  string `json:"user"`
+	Group string `json:"group"`
+	By    string `json:"by"` // Who ran the command, an admin when it isn't User
+}
+
+// join adds or removes the user for 'p4 access join|leave group'
+func join(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) != 1 {
+		return c.UsageError(e, fmt.Sprintf("Expected one group, got %d", len(a.Params)))
+	}
+	if _, err := e.client(); err != nil {
+		return err
+	}
+	ann := e.Annotations.Find(a.Params[0])
+	if ann == nil || !ann.SelfService {
+		return fmt.Errorf("%s isn't self-service, see 'p4 access owners %s' for who to ask", a.Params[0], a.Params[0])
+	}
+	g, err := prots.GetGroup(e.P4, a.Params[0])
+	if err != nil {
+		return err
+	}
+	if g.Ldap != nil {
+		e.ldap(g.Ldap)
+		return ldapError(g.Name, g.Ldap)
+	}
+	m, err := g.Member(e.P4, a.Target())
+	if err != nil {
+		return err
+	}
+	user := a.Target()
+	if a.Command == "join" {
+		if m.Direct {
+			return fmt.Errorf("%s is already a member of %s", user, g.Name)
+		}
+		// The policy applies as it does to requests, to every path the group
+		// gives access to, a group hidden for any of them is refused the same
+		// way as one that isn't self-service
+		if e.Policy != nil && len(e.Policy.Rules) > 0 {
+			var infos, shown []prots.Info
+			var blocked map[string]bool
+			infos, err = e.grants(g.Name)
+			if err != nil {
+				return err
+			}
+			shown, blocked, err = e.applyPolicy(user, infos)
+			if err == errNoGroups || (err == nil && len(shown) < len(infos)) {
+				return fmt.Errorf("%s isn't self-service, see 'p4 access owners %s' for who to ask", g.Name, g.Name)
+			} else if err != nil {
+				return err
+			}
+			if blocked[g.Name] {
+				for _, i := range shown {
+					if strings.HasPrefix(i.Note, policy.BlockedNote) {
+						return errors.New(i.Note)
+					}
+				}
+			}
+		}
+		err = prots.AddUser(e.P4, g.Name, user)
+	} else {
+		if !m.Direct {
+			if len(m.Via) > 0 {
+				return fmt.Errorf("%s is in %s through subgroup %s, leave that instead", user, g.Name, m.Via[0])
+			}
+			return fmt.Errorf("%s isn't a member of %s", user, g.Name)
+		}
+		err = prots.RemoveUser(e.P4, g.Name, user)
+	}
+	if err != nil {
+		log.Printf("Failed to %s %s for %s, %v", a.Command, g.Name, user, err)
+		return fmt.Errorf("Failed to %s %s, please contact support", a.Command, g.Name)
+	}
+	e.record(a.Command, map[string]string{
+		"group": g.Name,
+		"for":   user,
+	})
+
+	out := joinInfo{user, g.Name, a.User}
+	owners, err := prots.Owners(e.P4, g.Name)
+	if err != nil {
+		// The change is made, the audit log still records it
+		log.Printf("Failed to find the owners of %s, %v", g.Name, err)
+	}
+	verb := map[string]string{"join": "joined", "leave": "left"}[a.Command]
+	e.send(a.Command, g.Name, out, emails(owners), fmt.Sprintf("%s %s %s", user, verb, g.Name))
+	e.hook(hookPayload{Event: a.Command, Groups: []prots.Info{{Group: g.Name, Owners: owners, Annotation: ann}}})
+	return io.Output(e.Config, a, a.Command, out)
+}
+
+// grants lists what the group gives access to for checking against the policy,
+// one Info for each path read or written through it, or just the group if it
+// isn't in the protections table
+func (e *Env) grants(group string) ([]prots.Info, error) {
+	ps, err := prots.Protections(e.P4, "")
+	if err != nil {
+		return nil, err
+	}
+	gps, err := ps.Paths(e.P4, group)
+	if err != nil {
+		return nil, err
+	}
+	infos := []prots.Info{}
+	for _, gp := range gps {
+		access := "read"
+		if prots.CanWrite(gp.Perm) {
+			access = "write"
+		}
+		infos = append(infos, prots.Info{Group: group, Path: gp.Prot.DepotFile, Access: access})
+	}
+	if len(infos) == 0 {
+		infos = append(infos, prots.Info{Group: group})
+	}
+	return infos, nil
+}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/audit"
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+)
+
+const selfServiceTools = `{"groups": ["P_tools*"], "selfService": true}`
+
+// FakeJoin mocks p4r with P_tools, owned by tools.owner with a.user in it
+// and c.user in it through P_tools_devs, and P_jam which isn't self-service
+// Adding b.user and taking a.user out both work
+func FakeJoin(fp4 *FakeP4Runner) {
+	FakeGroup(fp4, "P_jam", "jam.owner")
+	fp4.On("Run", []string{"group", "-o", "P_tools"}).Return([]map[interface{}]interface{}{{
+		"Group":      "P_tools",
+		"Owners0":    "tools.owner",
+		"Users0":     "a.user",
+		"Subgroups0": "P_tools_devs",
+	}}, nil).
+		On("Run", []string{"user", "-o", "tools.owner"}).Return(
+		[]map[interface{}]interface{}{{"User": "tools.owner", "Email": "tools.owner@email.com", "FullName": "tools.owner"}}, nil).
+		On("Input", []string{"group", "-i"}, "Group: P_tools\n\nOwners:\n\ttools.owner\n\nSubgroups:\n\tP_tools_devs\n\nUsers:\n\ta.user\n\tb.user\n\n").
+		Return("Group P_tools updated.", nil).
+		On("Input", []string{"group", "-i"}, "Group: P_tools\n\nOwners:\n\ttools.owner\n\nSubgroups:\n\tP_tools_devs\n\n").
+		Return("Group P_tools updated.", nil)
+	for _, u := range []string{"a.user", "b.user"} {
+		fp4.On("Run", []string{"groups", "-i", "-u", u}).Return([]map[interface{}]interface{}{}, nil)
+	}
+	fp4.On("Run", []string{"groups", "-i", "-u", "c.user"}).Return(
+		[]map[interface{}]interface{}{{"group": "P_tools_devs"}, {"group": "P_tools"}}, nil)
+	// P_tools can write to //depot/Tools/... and read //depot/Secret/...
+	fp4.On("Run", []string{"protects", "-a"}).Return([]map[interface{}]interface{}{
+		{"perm": "write", "host": "*", "user": "P_tools", "isgroup": "", "line": "1", "depotFile": "//depot/Tools/..."},
+		{"perm": "read", "host": "*", "user": "P_tools", "isgroup": "", "line": "2", "depotFile": "//depot/Secret/..."},
+	}, nil).
+		On("Run", []string{"groups", "-i", "-g", "P_tools"}).Return([]map[interface{}]interface{}{}, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_tools", "//depot/Tools/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_tools", "//depot/Secret/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "read"}}, nil)
+}
+
+type joinTest struct {
+	user   string
+	params []string
+	want   string
+	err    error
+}
+
+var joinTests = []joinTest{
+	{"b.user", []string{"join", "P_tools"}, "b.user is now a member of P_tools", nil},
+	{"a.user", []string{"leave", "P_tools"}, "a.user is no longer a member of P_tools", nil},
+	{"a.user", []string{"join", "P_tools"}, "", errors.New("a.user is already a member of P_tools")},
+	{"b.user", []string{"leave", "P_tools"}, "", errors.New("b.user isn't a member of P_tools")},
+	{"c.user", []string{"leave", "P_tools"}, "", errors.New("c.user is in P_tools through subgroup P_tools_devs, leave that instead")},
+	{"b.user", []string{"join", "P_jam"}, "", errors.New("P_jam isn't self-service, see 'p4 access owners P_jam' for who to ask")},
+	{"b.user", []string{"join"}, "", errors.New("Expected one group, got 0\nUsage: p4 access join [-j -u user] group")},
+}
+
+func TestJoin(t *testing.T) {
+	for _, tst := range joinTests {
+		fp4 := &FakeP4Runner{}
+		FakeJoin(fp4)
+		fn := &FakeNotifier{}
+		al := filepath.Join(t.TempDir(), "audit.log")
+		args := io.Args{User: tst.user, Command: tst.params[0], Params: tst.params[1:]}
+		res, err := run(&Env{
+			Config:      testConfig(),
+			P4:          fp4,
+			Args:        args,
+			Audit:       audit.New(al),
+			Notify:      fn,
+			Annotations: testAnnotations(t, selfServiceTools),
+			Mode:        io.Broker,
+		})
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			assert.Empty(t, fn.events)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Contains(t, res, tst.want)
+		assert.Len(t, fn.events, 1)
+		assert.Equal(t, tst.params[0], fn.events[0].Type)
+		assert.Equal(t, []string{"tools.owner@email.com"}, fn.events[0].To)
+		b, err := ioutil.ReadFile(al)
+		assert.Nil(t, err)
+		assert.Contains(t, string(b), `"event":"`+tst.params[0]+`"`)
+		assert.Contains(t, string(b), `"for":"`+tst.user+`"`)
+	}
+}
+
+func TestJoinAdmin(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeJoin(fp4)
+	FakeAdmin(fp4, "admin")
+	fn := &FakeNotifier{}
+	args := io.Args{User: "admin", As: "b.user", Command: "join", Params: []string{"P_tools"}}
+	res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: fn, Annotations: testAnnotations(t, selfServiceTools), Mode: io.Broker})
+	assert.Nil(t, err)
+	assert.Contains(t, res, "b.user is now a member of P_tools")
+	assert.Equal(t, "b.user has joined P_tools, which you own. admin added them.\n\n"+
+		"The group is self-service, so nobody had to approve it. If they shouldn't be\n"+
+		"in it, take them out and ask for the group to stop being self-service.\n", fn.events[0].Body)
+}
+
+func TestJoinPolicy(t *testing.T) {
+	type policyTest struct {
+		rule   string
+		action string
+		want   string
+	}
+	for _, tst := range []policyTest{
+		{`"groups": ["P_tools"]`, "block", "This group can't be requested, ask the tools team"},
+		{`"groups": ["P_tools"]`, "hide", "P_tools isn't self-service, see 'p4 access owners P_tools' for who to ask"},
+		// Rules about what the group gives access to apply to joining it too
+		{`"paths": ["//depot/Secret/..."]`, "block", "This group can't be requested, ask the tools team"},
+		{`"paths": ["//depot/Secret/..."]`, "hide", "P_tools isn't self-service, see 'p4 access owners P_tools' for who to ask"},
+		{`"paths": ["//depot/..."], "access": ["write"]`, "block", "This group can't be requested, ask the tools team"},
+	} {
+		fp4 := &FakeP4Runner{}
+		FakeJoin(fp4)
+		fn := &FakeNotifier{}
+		al := filepath.Join(t.TempDir(), "audit.log")
+		args := io.Args{User: "b.user", Command: "join", Params: []string{"P_tools"}}
+		_, err := run(&Env{
+			Config:      testConfig(),
+			P4:          fp4,
+			Args:        args,
+			Audit:       audit.New(al),
+			Notify:      fn,
+			Annotations: testAnnotations(t, selfServiceTools),
+			Policy:      testPolicy(t, `{"name": "tools", `+tst.rule+`, "action": "`+tst.action+`", "explain": "ask the tools team"}`),
+			Mode:        io.Broker,
+		})
+		assert.EqualError(t, err, tst.want, tst.rule)
+		fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, "Group: P_tools\n\nOwners:\n\ttools.owner\n\nSubgroups:\n\tP_tools_devs\n\nUsers:\n\ta.user\n\tb.user\n\n")
+		assert.Empty(t, fn.events)
+		// The decision is recorded like any other
+		b, err := ioutil.ReadFile(al)
+		assert.Nil(t, err)
+		assert.Contains(t, string(b), `"event":"policy"`)
+		assert.Contains(t, string(b), `"action":"`+tst.action+`"`)
+	}
+}
+
+func TestJoinPolicyOtherPath(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeJoin(fp4)
+	args := io.Args{User: "b.user", Command: "join", Params: []string{"P_tools"}}
+	res, err := run(&Env{
+		Config:      testConfig(),
+		P4:          fp4,
+		Args:        args,
+		Notify:      &FakeNotifier{},
+		Annotations: testAnnotations(t, selfServiceTools),
+		Policy:      testPolicy(t, `{"name": "export", "paths": ["//depot/Export/..."], "action": "block", "explain": "ask legal"}`),
+		Mode:        io.Broker,
+	})
+	assert.Nil(t, err)
+	assert.Contains(t, res, "b.user is now a member of P_tools")
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// ldap fills in who to ask about joining a group synchronised from LDAP
+func (e *Env) ldap(l *prots.Ldap) {
+	if l != nil {
+		l.Contact, l.URL = e.Config.LdapContact, e.Config.LdapURL
+	}
+}
+
+// ldapError explains that a group synchronised from LDAP can't be changed
+// in Perforce, 'p4 ldapsync' would undo it
+func ldapError(group string, l *prots.Ldap) error {
+	to := "your LDAP administrators"
+	if l.URL != "" {
+		to = l.URL
+	} else if l.Contact != "" {
+		to = l.Contact
+	}
+	return fmt.Errorf("The members of %s come from %s, changing it in Perforce wouldn't last, ask %s instead",
+		group, l.Source(), to)
+}
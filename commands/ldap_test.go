@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// FakeLdap mocks p4r for FakeProtects where 'p4 ldapsync' fills P_jam_main
+// from the corp LDAP configuration, it still names main.owner as an owner
+func FakeLdap(fp4 *FakeP4Runner) {
+	FakeProtects(fp4, "a.user")
+	FakeGroup(fp4, "P_jam", "jam.owner")
+	fp4.On("Run", []string{"group", "-o", "P_jam_main"}).Return([]map[interface{}]interface{}{{
+		"Group":           "P_jam_main",
+		"Owners0":         "main.owner",
+		"LdapConfig":      "corp",
+		"LdapSearchQuery": "(memberOf=cn=jam-devs,ou=groups,dc=example,dc=com)",
+	}}, nil).
+		On("Run", []string{"user", "-o", "main.owner"}).Return(
+		[]map[interface{}]interface{}{{"User": "main.owner", "Email": "main.owner@email.com", "FullName": "main.owner"}}, nil)
+}
+
+func TestAccessLdap(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeLdap(fp4)
+	c := testConfig()
+	c.LdapURL = "https://idm.example.com/groups"
+	e := &Env{
+		Config: c,
+		P4:     fp4,
+		Args:   io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}, All: true},
+		Mode:   io.Broker,
+	}
+	res, err := run(e)
+	assert.Nil(t, err)
+	check(t, "access_ldap.txt", res)
+}
+
+type requestLdapTest struct {
+	params []string
+	group  string
+	err    error
+}
+
+var requestLdapTests = []requestLdapTest{
+	{ // P_jam_main is best but LDAP fills it, so P_jam is requested
+		[]string{"write", "//depot/Jam/MAIN/..."},
+		"P_jam",
+		nil,
+	},
+	{
+		[]string{"write", "//depot/Jam/MAIN/...", "P_jam_main"},
+		"",
+		errors.New("The members of P_jam_main come from the search (memberOf=cn=jam-devs,ou=groups,dc=example,dc=com) " +
+			"on LDAP configuration corp, changing it in Perforce wouldn't last, ask idm@email.com instead"),
+	},
+}
+
+func TestRequestLdap(t *testing.T) {
+	for _, tst := range requestLdapTests {
+		fp4 := &FakeP4Runner{}
+		FakeLdap(fp4)
+		fn := &FakeNotifier{}
+		st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+		c := testConfig()
+		c.LdapContact = "idm@email.com"
+		args := io.Args{User: "a.user", Command: "request", Params: tst.params, Message: "Because"}
+		_, err := run(&Env{Config: c, P4: fp4, Args: args, Notify: fn, Store: st, Mode: io.Broker})
+		saved, lerr := st.List(requests.Filter{})
+		assert.Nil(t, lerr)
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			assert.Empty(t, saved)
+			assert.Empty(t, fn.events)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tst.group, saved[0].Info.Group)
+	}
+}
+
+func TestOwnersLdap(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeLdap(fp4)
+	fp4.On("Run", []string{"groups", "-i", "-u", "a.user"}).Return([]map[interface{}]interface{}{}, nil)
+	args := io.Args{User: "a.user", Command: "owners", Params: []string{"P_jam_main"}}
+	res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Mode: io.Broker})
+	assert.Nil(t, err)
+	check(t, "owners_ldap.txt", res)
+}
+
+func TestJoinLdap(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeLdap(fp4)
+	c := testConfig()
+	c.LdapURL = "https://idm.example.com/groups"
+	args := io.Args{User: "a.user", Command: "join", Params: []string{"P_jam_main"}}
+	_, err := run(&Env{Config: c, P4: fp4, Args: args, Annotations: testAnnotations(t, `{"groups": ["P_jam*"], "selfService": true}`), Mode: io.Broker})
+	assert.EqualError(t, err, "The members of P_jam_main come from the search (memberOf=cn=jam-devs,ou=groups,dc=example,dc=com) "+
+		"on LDAP configuration corp, changing it in Perforce wouldn't last, ask https://idm.example.com/groups instead")
+	fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, "Group: P_jam_main\n\nLdapConfig: corp\n\nLdapSearchQuery: (memberOf=cn=jam-devs,ou=groups,dc=example,dc=com)\n\nOwners:\n\tmain.owner\n\nUsers:\n\ta.user\n\n")
+}
+
+func TestDecideLdap(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeLdap(fp4)
+	FakeDecide(fp4, nil)
+	c := testConfig()
+	c.LdapURL = "https://idm.example.com/groups"
+	st := FakeStore(t)
+	fn := &FakeNotifier{}
+	args := io.Args{User: "main.owner", Command: "approve", Params: []string{"R00000001"}}
+	_, err := run(&Env{Config: c, P4: fp4, Args: args, Notify: fn, Store: st, Mode: io.Broker})
+	assert.EqualError(t, err, "The members of P_jam_main come from the search (memberOf=cn=jam-devs,ou=groups,dc=example,dc=com) "+
+		"on LDAP configuration corp, changing it in Perforce wouldn't last, ask https://idm.example.com/groups instead")
+	fp4.AssertNotCalled(t, "Input", mock.Anything, mock.Anything)
+	assert.Empty(t, fn.events)
+	r, err := st.Get("R00000001")
+	assert.Nil(t, err)
+	assert.Equal(t, requests.Pending, r.State)
+	assert.Empty(t, r.Approvals)
+
+	// It can still be denied, that changes nothing in Perforce
+	args = io.Args{User: "main.owner", Command: "deny", Params: []string{"R00000001"}, Message: "Ask IDM"}
+	_, err = run(&Env{Config: c, P4: fp4, Args: args, Notify: fn, Store: st, Mode: io.Broker})
+	assert.Nil(t, err)
+	r, err = st.Get("R00000001")
+	assert.Nil(t, err)
+	assert.Equal(t, requests.Denied, r.State)
+}
+
+func TestStaleLdap(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_jam"}).Return([]map[interface{}]interface{}{{
+		"Group":      "P_jam",
+		"Owners0":    "jam.owner",
+		"LdapConfig": "corp",
+		"Users0":     "a.user",
+		"Users1":     "b.user",
+		"Users2":     "c.user",
+		"Users3":     "d.user",
+		"Users4":     "e.user",
+	}}, nil)
+	FakeStale(fp4)
+	c := testConfig()
+	c.StaleAfter = 90 * 24 * time.Hour
+	c.LdapContact = "idm@email.com"
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	e := &Env{Config: c, P4: fp4, Args: io.Args{Command: "stale", Params: []string{"suggest"}}, Notify: fn, Store: st, Mode: io.Direct}
+	res, err := run(e)
+	assert.Nil(err)
+	// Still reported, but with where to take them out rather than a removal
+	assert.Contains(res, "P_jam:\n    The members come from LDAP configuration corp, remove them through idm@email.com\n    b.user ")
+	assert.NotContains(res, "removal")
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(err)
+	assert.Empty(saved)
+	assert.Empty(fn.events)
+}
+
+func TestRecertifyLdap(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_jam"}).Return([]map[interface{}]interface{}{{
+		"Group":      "P_jam",
+		"Owners0":    "jam.owner",
+		"LdapConfig": "corp",
+		"Users0":     "a.user",
+	}}, nil)
+	FakeRecertify(fp4)
+	c := testConfig()
+	c.RecertifyFor = 14 * 24 * time.Hour
+	c.RecertifyUnconfirmed = requests.Remove
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	e := &Env{Config: c, P4: fp4, Args: io.Args{Command: "recertify"}, Notify: fn, Store: st, Mode: io.Direct}
+	res, err := run(e)
+	assert.Nil(err)
+	// Its owners aren't asked, so closing can't take anyone out of it
+	assert.Contains(res, "of 0 group(s)")
+	cps, err := st.Campaigns()
+	assert.Nil(err)
+	assert.Empty(cps[0].Reviews)
+	assert.Empty(fn.events)
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "owners",
+		Usage:   "owners [-j -u user] group",
+		Summary: "show who owns a group and whether you are already in it",
+		Help: `Lists the owners of group, with their names and emails, so you know who to ask for access.
+Also shows the group's description and whether you are already a member,
+either directly or through one of its subgroups.
+
+-j       Respond with json instead of text
+-u user  Check membership for another user, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    owners,
+	})
+}
+
+// ownersInfo is fed to owners.go.tpl
+type ownersInfo struct {
+	Group       string            `json:"group"`
+	Description string            `json:"description,omitempty"`
+	Owners      []prots.Owner     `json:"owners"`
+	User        string            `json:"user"`
+	Membership  prots.Membership  `json:"membership"`
+	Unowned     bool              `json:"unowned,omitempty"` // None of the owners can approve
+	Fallback    string            `json:"fallback,omitempty"`
+	Contacts    []prots.Owner     `json:"contacts,omitempty"` // Who to ask instead, from Fallback
+	Annotation  *prots.Annotation `json:"annotation,omitempty"`
+	Ldap        *prots.Ldap       `json:"ldap,omitempty"` // Set when 'p4 ldapsync' fills the group
+}
+
+// owners shows the owners of a group for 'p4 access owners group'
+func owners(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) != 1 {
+		return c.UsageError(e, fmt.Sprintf("Expected one group, got %d", len(a.Params)))
+	}
+	if _, err := e.client(); err != nil {
+		return err
+	}
+	g, err := prots.GetGroup(e.P4, a.Params[0])
+	if err != nil {
+		return err
+	}
+	ows, err := prots.Owners(e.P4, g.Name)
+	if err != nil {
+		return err
+	}
+	accounts, err := e.accounts(ows)
+	if err != nil {
+		return err
+	}
+	out := ownersInfo{Group: g.Name, Description: g.Description, User: a.Target(), Annotation: e.Annotations.Find(g.Name)}
+	// Most specs have no description, the annotations file can give one
+	if out.Description == "" && out.Annotation != nil {
+		out.Description = out.Annotation.Description
+	}
+	out.Owners, out.Unowned = e.checkOwners(ows, accounts)
+	out.Ldap = g.Ldap
+	e.ldap(out.Ldap)
+	if out.Ldap == nil && len(prots.UsableOwners(out.Owners)) == 0 {
+		info := prots.Info{Group: g.Name, Owners: out.Owners}
+		if out.Fallback, out.Contacts, err = e.fallback(info); err != nil {
+			return err
+		}
+	}
+	m, err := g.Member(e.P4, a.Target())
+	if err != nil {
+		return err
+	}
+	out.Membership = m
+	return io.Output(e.Config, a, "owners", out)
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type ownersTest struct {
+	args   io.Args
+	groups []map[interface{}]interface{}
+	want   string
+	err    error
+}
+
+var ownersTests = []ownersTest{
+	{ // Not a member
+		io.Args{User: "a.user", Command: "owners", Params: []string{"P_jam_main_rw"}},
+		[]map[interface{}]interface{}{},
+		"owners.txt",
+		nil,
+	},
+	{ // Member through a subgroup
+		io.Args{User: "a.user", Command: "owners", Params: []string{"P_jam_main_rw"}},
+		[]map[interface{}]interface{}{{"group": "P_jam_devs"}},
+		"owners_member.txt",
+		nil,
+	},
+	{
+		io.Args{User: "a.user", Command: "owners", Params: []string{"P_jam_main_rw"}, JSON: true},
+		[]map[interface{}]interface{}{{"group": "P_jam_devs"}},
+		"owners.json",
+		nil,
+	},
+	{
+		io.Args{User: "a.user", Command: "owners"},
+		nil,
+		"",
+		errors.New("Expected one group, got 0\nUsage: p4 access owners [-j -u user] group"),
+	},
+}
+
+func TestOwners(t *testing.T) {
+	for _, tst := range ownersTests {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"group", "-o", "P_jam_main_rw"}).Return(
+			[]map[interface{}]interface{}{{
+				"Group":       "P_jam_main_rw",
+				"Description": "Write access to \"jam\" MAIN",
+				"Owners0":     "owner.first",
+				"Owners1":     "owner.second",
+				"Users0":      "some.user",
+				"Subgroups0":  "P_jam_devs",
+			}}, nil).
+			On("Run", []string{"groups", "-i", "-u", "a.user"}).Return(tst.groups, nil)
+		for o, name := range map[string]string{"owner.first": "Owner First", "owner.second": "Owner Second"} {
+			fp4.On("Run", []string{"user", "-o", o}).Return(
+				[]map[interface{}]interface{}{{"User": o, "Email": o + "@email.com", "FullName": name}}, nil)
+		}
+		res, err := run(&Env{Config: testConfig(), P4: fp4, Args: tst.args, Mode: io.Broker})
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			continue
+		}
+		assert.Nil(t, err)
+		check(t, tst.want, res)
+	}
+}
+
+// FakeAccounts mocks 'p4 users -a' with the users and their types, standard
+// users last used the server an hour ago unless they are in stale
+// Every user is given back whoever is asked for
+func FakeAccounts(fp4 *FakeP4Runner, types map[string]string, stale ...string) {
+	res := []map[interface{}]interface{}{}
+	recent := fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+	for u, t := range types {
+		r := map[interface{}]interface{}{"User": u, "Email": u + "@email.com", "FullName": u, "Type": t, "Access": recent}
+		if contains(stale, u) {
+			r["Access"] = "1615284000"
+		}
+		res = append(res, r)
+	}
+	fp4.On("Run", mock.MatchedBy(func(args []string) bool {
+		return len(args) > 2 && args[0] == "users" && args[1] == "-a"
+	})).Return(res, nil)
+}
+
+type ownersCheckTest struct {
+	check string
+	types map[string]string
+	stale []string
+	want  string
+}
+
+var ownersCheckTests = []ownersCheckTest{
+	{ // The stale owner is listed after the active one
+		"demote",
+		map[string]string{"owner.first": "standard", "owner.second": "standard"},
+		[]string{"owner.first"},
+		"owners_demote.txt",
+	},
+	{ // Nobody is left to ask
+		"hide",
+		map[string]string{"owner.second": "service"},
+		nil,
+		"owners_unowned.txt",
+	},
+}
+
+func TestOwnersCheck(t *testing.T) {
+	for _, tst := range ownersCheckTests {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"groups", "-i", "-u", "a.user"}).Return([]map[interface{}]interface{}{}, nil)
+		FakeGroup(fp4, "P_jam_main_rw", "owner.first", "owner.second")
+		FakeAccounts(fp4, tst.types, tst.stale...)
+		c := testConfig()
+		c.OwnerCheck = tst.check
+		c.StaleAfter = 90 * 24 * time.Hour
+		args := io.Args{User: "a.user", Command: "owners", Params: []string{"P_jam_main_rw"}}
+		res, err := run(&Env{Config: c, P4: fp4, Args: args, Mode: io.Broker})
+		assert.Nil(t, err)
+		check(t, tst.want, res)
+		// Only the owners are looked up, not every user on the server
+		fp4.AssertCalled(t, "Run", []string{"users", "-a", "owner.first", "owner.second"})
+	}
+}
+
+func TestAccessUnowned(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	FakeAccounts(fp4, map[string]string{"jam.owner": "standard", "main.owner": "operator"})
+	c := testConfig()
+	c.OwnerCheck = "demote"
+	c.StaleAfter = 90 * 24 * time.Hour
+	e := &Env{
+		Config: c,
+		P4:     fp4,
+		Args:   io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}, All: true},
+		Mode:   io.Broker,
+	}
+	res, err := run(e)
+	assert.Nil(t, err)
+	check(t, "access_unowned.txt", res)
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "paths",
+		Usage:   "paths [-j] group",
+		Summary: "show everything a group grants",
+		Help: `Lists every protections line that gives group access, including lines for the
+groups it is a subgroup of. For each path it shows the permission and host, the
+exclusions that take part of it away again and the highest permission left.
+
+Use this before adding someone to a group, to see everything they would get.
+Depending on how p4 access is set up, this is only available to admins,
+or to admins and the owners of the group.
+
+-j       Respond with json instead of text`,
+		Broker: true,
+		Direct: true,
+		Run:    paths,
+	})
+}
+
+// pathsInfo is fed to paths.go.tpl
+type pathsInfo struct {
+	Group string            `json:"group"`
+	Paths []prots.GroupPath `json:"paths"`
+}
+
+// paths lists what a group grants for 'p4 access paths group'
+func paths(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) != 1 {
+		return c.UsageError(e, fmt.Sprintf("Expected one group, got %d", len(a.Params)))
+	}
+	// The protections table is only for super users, so this is limited
+	// the same way as 'p4 access who'
+	admin, err := e.whoAdmin()
+	if err != nil {
+		return err
+	}
+	g, err := prots.GetGroup(e.P4, a.Params[0])
+	if err != nil {
+		return err
+	}
+	if e.Config.Who == "owners" && !admin && !contains(g.Owners, a.User) {
+		return fmt.Errorf("Only admins and owners of %s can use 'p4 access paths'", g.Name)
+	}
+	ps, err := prots.Protections(e.P4, "")
+	if err != nil {
+		return err
+	}
+	gps, err := ps.Paths(e.P4, g.Name)
+	if err != nil {
+		return err
+	}
+	return io.Output(e.Config, a, "paths", pathsInfo{g.Name, gps})
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaths(t *testing.T) {
+	for _, json := range []bool{false, true} {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"group", "-o", "P_jam_rw"}).Return(
+			[]map[interface{}]interface{}{{"Owners0": "owner.first", "Users0": "a.user"}}, nil).
+			On("Run", []string{"protects", "-a"}).Return([]map[interface{}]interface{}{
+			{"perm": "write", "host": "*", "user": "P_jam_rw", "isgroup": "", "line": "1", "depotFile": "//depot/Jam/..."},
+			{"perm": "read", "host": "*", "user": "P_all", "isgroup": "", "line": "2", "depotFile": "//depot/..."},
+			{"perm": "read", "host": "*", "user": "*", "line": "3", "depotFile": "//depot/secret/...", "unmap": ""},
+			{"perm": "write", "host": "*", "user": "P_jam_rw", "isgroup": "", "line": "4", "depotFile": "//depot/Jam/REL/...", "unmap": ""},
+			{"perm": "write", "host": "*", "user": "P_other", "isgroup": "", "line": "5", "depotFile": "//depot/Jam/..."},
+		}, nil).
+			On("Run", []string{"groups", "-i", "-g", "P_jam_rw"}).Return(
+			[]map[interface{}]interface{}{{"group": "P_all"}}, nil).
+			On("Run", []string{"protects", "-M", "-g", "P_jam_rw", "//depot/Jam/..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+			On("Run", []string{"protects", "-M", "-g", "P_jam_rw", "//depot/..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": "write"}}, nil)
+
+		name := "paths.txt"
+		if json {
+			name = "paths.json"
+		}
+		args := io.Args{User: "owner.first", Command: "paths", Params: []string{"P_jam_rw"}, JSON: json}
+		fp4.On("Run", []string{"protects", "-M", "-u", "owner.first", "//..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": "write"}}, nil)
+		c := testConfig()
+		c.Who = "owners"
+		res, err := run(&Env{Config: c, P4: fp4, Args: args, Mode: io.Direct})
+		assert.Nil(t, err)
+		check(t, name, res)
+	}
+}
+
+func TestPathsRefused(t *testing.T) {
+	for who, want := range map[string]string{
+		"owners": "Only admins and owners of P_jam_rw can use 'p4 access paths'",
+		"admins": "Only admins can use 'p4 access paths'",
+	} {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"group", "-o", "P_jam_rw"}).Return(
+			[]map[interface{}]interface{}{{"Owners0": "owner.first", "Users0": "a.user"}}, nil).
+			On("Run", []string{"protects", "-M", "-u", "a.user", "//..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": "write"}}, nil)
+		c := testConfig()
+		c.Who = who
+		args := io.Args{User: "a.user", Command: "paths", Params: []string{"P_jam_rw"}}
+		_, err := run(&Env{Config: c, P4: fp4, Args: args, Mode: io.Broker})
+		assert.EqualError(t, err, want)
+		// The protections table is never read
+		fp4.AssertNotCalled(t, "Run", []string{"protects", "-a"})
+	}
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "pending",
+		Usage:   "pending [-j -u user] [group]",
+		Summary: "show the requests waiting for you to approve or deny",
+		Help: `Lists the pending requests to join the groups you own or approve for,
+oldest first, along with who made them and why. Requests you have
+already approved that still need others to are left out. Give a group to only see its requests.
+
+Decide on them with 'p4 access approve <id>' or 'p4 access deny <id> -m reason'.
+
+-j       Respond with json instead of text
+-u user  Show the queue of another owner, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    pending,
+	})
+}
+
+// pendingInfo is fed to pending.go.tpl
+type pendingInfo struct {
+	User     string              `json:"user"`
+	Group    string              `json:"group,omitempty"`
+	Requests []*requests.Request `json:"requests"`
+}
+
+// pending lists the requests an owner can decide on for 'p4 access pending [group]'
+func pending(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) > 1 {
+		return c.UsageError(e, fmt.Sprintf("Too many arguments, expected at most one group, got %d", len(a.Params)))
+	}
+	if _, err := e.client(); err != nil {
+		return err
+	}
+	f := requests.Filter{State: requests.Pending}
+	if len(a.Params) == 1 {
+		f.Group = a.Params[0]
+	}
+	rs, err := e.Store.List(f)
+	if err != nil {
+		return err
+	}
+	// Approvers are looked up now rather than trusting the request, they may have changed
+	can := map[string]bool{}
+	out := pendingInfo{a.Target(), f.Group, []*requests.Request{}}
+	for _, r := range rs {
+		g := r.Info.Group
+		if _, ok := can[g]; !ok {
+			set, err := e.Approvers.Resolve(e.P4, g)
+			if err != nil {
+				return err
+			}
+			can[g] = set.Can(a.Target())
+		}
+		if can[g] && !approved(r, a.Target()) {
+			out.Requests = append(out.Requests, r)
+		}
+	}
+	return io.Output(e.Config, a, "pending", out)
+}
+
+// approved checks whether the user has already given the request their approval
+func approved(r *requests.Request, user string) bool {
+	for _, ap := range r.Approvals {
+		if ap.User == user {
+			return true
+		}
+	}
+	return false
+}
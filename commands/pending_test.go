@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+)
+
+type pendingTest struct {
+	user   string
+	as     string
+	params []string
+	json   bool
+	want   string
+	err    error
+}
+
+var pendingTests = []pendingTest{
+	{"main.owner", "", []string{}, false, "pending.txt", nil},
+	{"main.owner", "", []string{}, true, "pending.json", nil},
+	// jam.owner owns both groups in the fake protections
+	{"jam.owner", "", []string{}, false, "pending_both.txt", nil},
+	{"jam.owner", "", []string{"P_jam"}, false, "pending_group.txt", nil},
+	{"a.user", "", []string{}, false, "pending_none.txt", nil},
+	{"admin", "main.owner", []string{}, false, "pending.txt", nil},
+	{"a.user", "main.owner", []string{}, false, "", errors.New("Only admins can use -u, user a.user is not an admin")},
+	{"main.owner", "", []string{"P_jam", "P_jam_main"}, false, "",
+		errors.New("Too many arguments, expected at most one group, got 2\nUsage: p4 access pending [-j -u user] [group]")},
+}
+
+func TestPending(t *testing.T) {
+	for _, tst := range pendingTests {
+		fp4 := &FakeP4Runner{}
+		FakeAdmin(fp4, "admin", "a.user")
+		FakeGroup(fp4, "P_jam", "jam.owner")
+		FakeGroup(fp4, "P_jam_main", "main.owner", "jam.owner")
+		args := io.Args{User: tst.user, As: tst.as, Command: "pending", Params: tst.params, JSON: tst.json}
+		res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Store: FakeRequests(t), Mode: io.Broker})
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			continue
+		}
+		assert.Nil(t, err)
+		check(t, tst.want, updated.ReplaceAllString(res, "${1}UPDATED"))
+	}
+}
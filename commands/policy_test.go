@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/audit"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/policy"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPolicy writes rules to a policy file and loads it
+func testPolicy(t *testing.T, rules string) *policy.Policy {
+	f := filepath.Join(t.TempDir(), "policy.json")
+	if err := ioutil.WriteFile(f, []byte(`{"rules": [`+rules+`]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.Load(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const annotateJam = `{"name": "jam", "groups": ["P_jam"], "action": "annotate", "explain": "only for the Jam team"}`
+
+func TestAccessPolicy(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	al := filepath.Join(t.TempDir(), "audit.log")
+	e := &Env{
+		Config: testConfig(),
+		P4:     fp4,
+		Args:   io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}, All: true},
+		Audit:  audit.New(al),
+		Policy: testPolicy(t, annotateJam),
+		Mode:   io.Broker,
+	}
+	res, err := run(e)
+	assert.Nil(t, err)
+	check(t, "access_policy.txt", res)
+	b, err := ioutil.ReadFile(al)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"event":"policy"`)
+	assert.Contains(t, string(b), `"explain":"only for the Jam team"`)
+}
+
+func TestAccessPolicyHidesAll(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	e := &Env{
+		Config: testConfig(),
+		P4:     fp4,
+		Args:   io.Args{User: "a.user", Command: "write", Params: []string{"//depot/Jam/MAIN/..."}, All: true},
+		Policy: testPolicy(t, `{"name": "jam", "paths": ["//depot/Jam/..."], "action": "hide", "explain": "secret"}`),
+		Mode:   io.Broker,
+	}
+	_, err := run(e)
+	assert.EqualError(t, err, "No matching groups found, try again with a more specific path")
+}
+
+type requestPolicyTest struct {
+	params []string
+	rules  string
+	group  string
+	err    error
+}
+
+var requestPolicyTests = []requestPolicyTest{
+	{ // Annotated groups can still be requested
+		[]string{"write", "//depot/Jam/MAIN/...", "P_jam"},
+		annotateJam,
+		"P_jam",
+		nil,
+	},
+	{ // The best group is blocked, so the next best is requested
+		[]string{"write", "//depot/Jam/MAIN/..."},
+		`{"name": "main", "groups": ["P_jam_main"], "action": "block", "explain": "ask the Jam team lead"}`,
+		"P_jam",
+		nil,
+	},
+	{
+		[]string{"write", "//depot/Jam/MAIN/...", "P_jam_main"},
+		`{"name": "main", "groups": ["P_jam_main"], "action": "block", "explain": "ask the Jam team lead"}`,
+		"",
+		errors.New("This group can't be requested, ask the Jam team lead"),
+	},
+	{
+		[]string{"write", "//depot/Jam/MAIN/..."},
+		`{"name": "jam", "groups": ["P_jam*"], "action": "block", "explain": "ask the Jam team lead"}`,
+		"",
+		errors.New("This group can't be requested, ask the Jam team lead"),
+	},
+	{ // Hidden groups can't be named either
+		[]string{"write", "//depot/Jam/MAIN/...", "P_jam"},
+		`{"name": "jam", "groups": ["P_jam"], "action": "hide", "explain": "secret"}`,
+		"",
+		errors.New("Group P_jam doesn't give write access to //depot/Jam/MAIN/..., " +
+			"see 'p4 access write //depot/Jam/MAIN/...' for the groups that do"),
+	},
+}
+
+func TestRequestPolicy(t *testing.T) {
+	for _, tst := range requestPolicyTests {
+		fp4 := &FakeP4Runner{}
+		FakeRequest(fp4, "a.user")
+		st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+		args := io.Args{User: "a.user", Command: "request", Params: tst.params, Message: "Because"}
+		e := &Env{Config: testConfig(), P4: fp4, Args: args, Notify: &FakeNotifier{}, Store: st,
+			Policy: testPolicy(t, tst.rules), Mode: io.Broker}
+		_, err := run(e)
+		saved, lerr := st.List(requests.Filter{})
+		assert.Nil(t, lerr)
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			assert.Empty(t, saved)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Len(t, saved, 1)
+		assert.Equal(t, tst.group, saved[0].Info.Group)
+	}
+}
@@ -0,0 +1,274 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "recertify",
+		Usage:   "recertify [-j -e deadline] [close [campaign]]",
+		Summary: "ask every group's owners to confirm who should still be in it",
+		Help: `Starts a recertification campaign, a review of every group that has owners
+and members. Each owner is sent the members of their groups and keeps or
+removes them with 'p4 access review <campaign>'. Groups synchronised from
+LDAP are left out, they are reviewed through P4ACCESS_LDAPURL or
+P4ACCESS_LDAPCONTACT instead.
+
+'close' finishes the campaigns whose deadline has passed, or the one given
+even if it hasn't. Members nobody confirmed are removed, or escalated if
+P4ACCESS_RECERTIFYUNCONFIRMED is 'escalate', and the report is sent to
+P4ACCESS_AUDITORS. Run 'recertify close' from cron.
+
+-e deadline  How long owners have, e.g. 14d, instead of P4ACCESS_RECERTIFYFOR
+-j           Respond with json instead of text`,
+		Direct: true,
+		Run:    recertify,
+	})
+}
+
+// reviewsInfo is fed to review.go.tpl and the recertify notifications,
+// the reviews of a campaign one owner can see
+type reviewsInfo struct {
+	ID       string             `json:"id"`
+	Deadline time.Time          `json:"deadline"`
+	Closed   *time.Time         `json:"closed,omitempty"`
+	User     string             `json:"user"`
+	Reviews  []*requests.Review `json:"reviews"`
+}
+
+func newReviewsInfo(cp *requests.Campaign, user string) *reviewsInfo {
+	return &reviewsInfo{cp.ID, cp.Deadline, cp.Closed, user, []*requests.Review{}}
+}
+
+// recertify starts or closes campaigns for 'p4access recertify [close [campaign]]'
+func recertify(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	switch {
+	case len(a.Params) == 0:
+		return e.startCampaign()
+	case a.Params[0] == "close" && len(a.Params) <= 2:
+		return e.closeCampaigns()
+	case a.Params[0] == "close":
+		return c.UsageError(e, fmt.Sprintf("Too many arguments, expected at most a campaign to close, got %d", len(a.Params)-1))
+	}
+	return c.UsageError(e, fmt.Sprintf("Unknown argument '%s', expected nothing or close", a.Params[0]))
+}
+
+// startCampaign reviews every group with owners and members and tells the owners
+func (e *Env) startCampaign() error {
+	switch e.Config.RecertifyUnconfirmed {
+	case requests.Remove, "escalate":
+	default:
+		return fmt.Errorf("P4ACCESS_RECERTIFYUNCONFIRMED must be remove or escalate, not '%s'", e.Config.RecertifyUnconfirmed)
+	}
+	groups, err := prots.AllGroups(e.P4)
+	if err != nil {
+		return err
+	}
+	reviews := []*requests.Review{}
+	for _, name := range groups {
+		g, err := prots.GetGroup(e.P4, name)
+		if err != nil {
+			return err
+		}
+		if len(g.Owners) == 0 || len(g.Users) == 0 {
+			continue
+		}
+		// Removals would be undone by 'p4 ldapsync', LDAP's own review covers them
+		if g.Ldap != nil {
+			e.ldap(g.Ldap)
+			log.Printf("Leaving %s out of the review, %v", name, ldapError(name, g.Ldap))
+			continue
+		}
+		set, err := e.Approvers.Resolve(e.P4, name)
+		if err != nil {
+			return err
+		}
+		r := &requests.Review{Group: name, Owners: set.All(), Members: []*requests.Member{}}
+		for _, u := range g.Users {
+			r.Members = append(r.Members, &requests.Member{User: u})
+		}
+		reviews = append(reviews, r)
+	}
+	length := e.Config.RecertifyFor
+	if e.Args.Expires != 0 {
+		length = e.Args.Expires
+	}
+	cp, err := requests.NewCampaign(reviews, time.Now().Add(length))
+	if err != nil {
+		return err
+	}
+	if err := e.Store.AddCampaign(cp); err != nil {
+		return fmt.Errorf("Failed to save campaign, %v", err)
+	}
+	e.record("recertify", map[string]string{
+		"campaign": cp.ID,
+		"groups":   fmt.Sprint(len(reviews)),
+		"deadline": cp.Deadline.Format(time.RFC3339),
+	})
+	// Each owner gets one email covering all of their groups
+	owners := []prots.Owner{}
+	byOwner := map[string]*reviewsInfo{}
+	for _, r := range reviews {
+		for _, o := range r.Owners {
+			if _, ok := byOwner[o.User]; !ok {
+				owners = append(owners, o)
+				byOwner[o.User] = newReviewsInfo(cp, o.User)
+			}
+			byOwner[o.User].Reviews = append(byOwner[o.User].Reviews, r)
+		}
+	}
+	for _, o := range owners {
+		ri := byOwner[o.User]
+		e.send("recertify", cp.ID, ri, emails([]prots.Owner{o}),
+			fmt.Sprintf("Access review %s: please confirm the members of %d group(s) by %s",
+				cp.ID, len(ri.Reviews), cp.Deadline.Format("2006-01-02")))
+	}
+	return io.Output(e.Config, e.Args, "recertify", cp)
+}
+
+// closeCampaigns closes the campaign given, or every one that is due
+func (e *Env) closeCampaigns() error {
+	now := time.Now()
+	due := []*requests.Campaign{}
+	if len(e.Args.Params) == 2 {
+		cp, err := e.Store.GetCampaign(e.Args.Params[1])
+		if err != nil {
+			return err
+		}
+		if cp.Closed != nil {
+			return fmt.Errorf("Campaign %s was closed on %s", cp.ID, cp.Closed.Format("2006-01-02"))
+		}
+		due = append(due, cp)
+	} else {
+		all, err := e.Store.Campaigns()
+		if err != nil {
+			return err
+		}
+		for _, cp := range all {
+			if cp.Due(now) {
+				due = append(due, cp)
+			}
+		}
+	}
+	failed := []string{}
+	for i, cp := range due {
+		cp, err := e.closeCampaign(cp.ID, now)
+		if err != nil {
+			return fmt.Errorf("Failed to close campaign, %v", err)
+		}
+		due[i] = cp
+		e.send("recertify_report", cp.ID, cp, e.Config.Auditors,
+			fmt.Sprintf("Access review %s: closed, the report", cp.ID))
+		for _, r := range cp.Reviews {
+			for _, m := range r.Members {
+				if m.Outcome == requests.Failed {
+					failed = append(failed, r.Group+" "+m.User)
+				}
+			}
+		}
+	}
+	if err := io.Output(e.Config, e.Args, "recertify_report", due); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to remove %s, see the log", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// closeCampaign settles every member, removing or escalating the unconfirmed
+// The outcomes are settled inside the store's lock so a review made meanwhile
+// counts, the unconfirmed are taken out of their groups after it is released
+// so a slow server can't hold the lock long enough to look stale
+func (e *Env) closeCampaign(id string, now time.Time) (*requests.Campaign, error) {
+	var cp *requests.Campaign
+	type removal struct {
+		group  string
+		member *requests.Member
+	}
+	remove := []removal{}
+	err := e.Store.ModifyCampaign(id, func(saved *requests.Campaign) error {
+		if saved.Closed != nil {
+			return fmt.Errorf("Campaign %s was closed on %s", saved.ID, saved.Closed.Format("2006-01-02"))
+		}
+		cp = saved
+		for _, r := range cp.Reviews {
+			for _, m := range r.Members {
+				switch {
+				case m.Decision == requests.Keep:
+					m.Outcome = requests.Kept
+				case m.Decision == requests.Remove:
+					m.Outcome = requests.Removed
+				case e.Config.RecertifyUnconfirmed == "escalate":
+					m.Outcome = requests.Escalated
+				default:
+					m.Outcome = requests.Removed
+					remove = append(remove, removal{r.Group, m})
+				}
+			}
+		}
+		closed := now.UTC()
+		cp.Closed = &closed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	failed := map[string]bool{}
+	for _, rm := range remove {
+		if err := prots.RemoveUser(e.P4, rm.group, rm.member.User); err != nil {
+			log.Printf("Failed to remove %s from %s for %s, %v", rm.member.User, rm.group, cp.ID, err)
+			rm.member.Outcome = requests.Failed
+			failed[rm.group+" "+rm.member.User] = true
+		}
+	}
+	if len(failed) > 0 {
+		err := e.Store.ModifyCampaign(id, func(saved *requests.Campaign) error {
+			for _, r := range saved.Reviews {
+				for _, m := range r.Members {
+					if failed[r.Group+" "+m.User] {
+						m.Outcome = requests.Failed
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to save the failed removals for %s, %v", cp.ID, err)
+		}
+	}
+	for _, r := range cp.Reviews {
+		escalated := []*requests.Member{}
+		for _, m := range r.Members {
+			if m.Decision == "" {
+				e.record("recertify", map[string]string{
+					"campaign": cp.ID,
+					"group":    r.Group,
+					"for":      m.User,
+					"outcome":  m.Outcome,
+				})
+			}
+			if m.Outcome == requests.Escalated {
+				escalated = append(escalated, m)
+			}
+		}
+		if len(escalated) > 0 {
+			ri := newReviewsInfo(cp, "")
+			ri.Reviews = append(ri.Reviews, &requests.Review{Group: r.Group, Owners: r.Owners, Members: escalated})
+			e.send("recertify_escalate", cp.ID, ri,
+				append(emails(r.Owners), e.Config.Auditors...),
+				fmt.Sprintf("Access review %s: %d member(s) of %s weren't confirmed", cp.ID, len(escalated), r.Group))
+		}
+	}
+	return cp, nil
+}
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeRecertify mocks p4r with three groups, only P_jam has both owners and members
+// Taking b.user out of P_jam works, taking c.user out fails
+func FakeRecertify(fp4 *FakeP4Runner) {
+	fp4.On("Run", []string{"groups"}).Return([]map[interface{}]interface{}{
+		{"group": "P_jam"}, {"group": "P_jam"}, {"group": "P_no_owners"}, {"group": "P_no_members"},
+	}, nil).
+		On("Run", []string{"group", "-o", "P_no_owners"}).Return(
+		[]map[interface{}]interface{}{{"Group": "P_no_owners", "Users0": "a.user"}}, nil).
+		On("Run", []string{"group", "-o", "P_no_members"}).Return(
+		[]map[interface{}]interface{}{{"Group": "P_no_members", "Owners0": "jam.owner"}}, nil).
+		On("Run", []string{"group", "-o", "P_jam"}).Return([]map[interface{}]interface{}{{
+		"Group":   "P_jam",
+		"Owners0": "jam.owner",
+		"Users0":  "a.user",
+		"Users1":  "b.user",
+		"Users2":  "c.user",
+	}}, nil).
+		On("Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tc.user\n\n").
+		Return("Group P_jam updated.", nil).
+		On("Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tb.user\n\n").
+		Return("", errors.New("p4 group -i failed, exit status 1"))
+	fp4.On("Run", []string{"user", "-o", "jam.owner"}).Return(
+		[]map[interface{}]interface{}{{"User": "jam.owner", "Email": "jam.owner@email.com", "FullName": "jam.owner"}}, nil)
+}
+
+func TestRecertify(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeRecertify(fp4)
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	c := testConfig()
+	c.RecertifyFor = 14 * 24 * time.Hour
+	c.RecertifyUnconfirmed = requests.Remove
+	c.Auditors = []string{"audit@email.com"}
+	e := &Env{Config: c, P4: fp4, Args: io.Args{Command: "recertify"}, Notify: fn, Store: st, Mode: io.Direct}
+	res, err := run(e)
+	assert.Nil(err)
+
+	cps, err := st.Campaigns()
+	assert.Nil(err)
+	assert.Len(cps, 1)
+	cp := cps[0]
+	assert.WithinDuration(time.Now().Add(14*24*time.Hour), cp.Deadline, time.Minute)
+	assert.Len(cp.Reviews, 1)
+	assert.Equal("P_jam", cp.Reviews[0].Group)
+	assert.Len(cp.Reviews[0].Members, 3)
+	assert.Contains(res, "Started access review "+cp.ID+" of 1 group(s)")
+	assert.Contains(res, "P_jam: 3 member(s), reviewed by jam.owner")
+	assert.Len(fn.events, 1)
+	assert.Equal("recertify", fn.events[0].Type)
+	assert.Equal([]string{"jam.owner@email.com"}, fn.events[0].To)
+	assert.Contains(fn.events[0].Body, "P_jam:\n    a.user\n    b.user\n    c.user\n")
+
+	// Nothing is due yet
+	e.Args = io.Args{Command: "recertify", Params: []string{"close"}}
+	res, err = run(e)
+	assert.Nil(err)
+	assert.Equal("No access reviews are due\n", res)
+
+	// The owner keeps a.user, b.user and c.user are left for the deadline
+	e.Args = io.Args{User: "jam.owner", Command: "review", Params: []string{cp.ID, "keep", "P_jam", "a.user"}}
+	_, err = run(e)
+	assert.Nil(err)
+
+	fn.events = nil
+	e.Args = io.Args{Command: "recertify", Params: []string{"close", cp.ID}}
+	res, err = run(e)
+	assert.EqualError(err, "Failed to remove P_jam c.user, see the log")
+	assert.Contains(res, "        a.user: kept by jam.owner\n        b.user: removed\n        c.user: failed\n")
+	cp, err = st.GetCampaign(cp.ID)
+	assert.Nil(err)
+	assert.NotNil(cp.Closed)
+	assert.Len(fn.events, 1)
+	assert.Equal("recertify_report", fn.events[0].Type)
+	assert.Equal([]string{"audit@email.com"}, fn.events[0].To)
+	assert.Contains(fn.events[0].Body, "    b.user: removed\n")
+
+	_, err = run(e)
+	assert.EqualError(err, "Campaign "+cp.ID+" was closed on "+cp.Closed.Format("2006-01-02"))
+}
+
+func TestRecertifyCloseUnlocked(t *testing.T) {
+	assert := assert.New(t)
+	st := FakeCampaign(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tc.user\n\n").
+		Run(unlocked(t, st)).Return("Group P_jam updated.", nil)
+	FakeRecertify(fp4)
+	c := testConfig()
+	c.RecertifyUnconfirmed = requests.Remove
+	e := &Env{Config: c, P4: fp4, Args: io.Args{Command: "recertify", Params: []string{"close"}}, Notify: &FakeNotifier{}, Store: st, Mode: io.Direct}
+	_, err := run(e)
+	assert.EqualError(err, "Failed to remove P_jam c.user, see the log")
+	// The failure is saved after the lock is taken again
+	cp, err := st.GetCampaign("C00000001")
+	assert.Nil(err)
+	assert.NotNil(cp.Closed)
+	for user, want := range map[string]string{"a.user": requests.Kept, "b.user": requests.Removed, "c.user": requests.Failed} {
+		m, err := cp.Reviews[0].Member(user)
+		assert.Nil(err)
+		assert.Equal(want, m.Outcome, user)
+	}
+}
+
+func TestRecertifyEscalate(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeRecertify(fp4)
+	fn := &FakeNotifier{}
+	st := FakeCampaign(t)
+	c := testConfig()
+	c.RecertifyUnconfirmed = "escalate"
+	c.Auditors = []string{"audit@email.com"}
+	e := &Env{Config: c, P4: fp4, Args: io.Args{Command: "recertify", Params: []string{"close"}}, Notify: fn, Store: st, Mode: io.Direct}
+	res, err := run(e)
+	assert.Nil(err)
+	assert.Contains(res, "        b.user: escalated\n        c.user: escalated\n")
+	fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tc.user\n\n")
+	assert.Len(fn.events, 2)
+	assert.Equal("recertify_escalate", fn.events[0].Type)
+	assert.Equal([]string{"jam.owner@email.com", "audit@email.com"}, fn.events[0].To)
+	assert.Contains(fn.events[0].Body, "P_jam, reviewed by jam.owner\n    b.user\n    c.user\n")
+	assert.Equal("recertify_report", fn.events[1].Type)
+}
+
+func TestRecertifyUsage(t *testing.T) {
+	e := &Env{Config: testConfig(), Args: io.Args{Command: "recertify", Params: []string{"open"}}, Mode: io.Direct}
+	_, err := run(e)
+	assert.EqualError(t, err, "Unknown argument 'open', expected nothing or close\n"+
+		"Usage: p4access recertify [-j -e deadline] [close [campaign]]")
+}
@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/notify"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "request",
+		Usage:   "request [-j -u user] <read|write> path [group] -m justification",
+		Summary: "ask the owners of a group to give you access",
+		Help: `Files a request to join the best group for read or write access to path,
+or the given group if you already know which one you need. The group's owners
+and any delegated approvers are notified and you are given a request ID to
+follow it up with. Some groups need more than one of them to approve.
+Only one request for a group can be pending at a time.
+
+-m justification  Why you need access, this is required
+-j                Respond with json instead of text
+-u user           Request access for another user, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    request,
+	})
+}
+
+// request files a request for 'p4 access request read|write path [group]'
+func request(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) < 2 || len(a.Params) > 3 {
+		return c.UsageError(e, fmt.Sprintf("Expected an access level, a path and optionally a group, got %d arguments", len(a.Params)))
+	}
+	reqAccess := a.Params[0]
+	if reqAccess != "read" && reqAccess != "write" {
+		return c.UsageError(e, fmt.Sprintf("Unknown access level '%s', must be read or write", reqAccess))
+	}
+	if a.Message == "" {
+		return c.UsageError(e, "A justification is required, add -m \"why you need access\"")
+	}
+	cl, err := e.client()
+	if err != nil {
+		return err
+	}
+	path, err := prots.Where(e.P4, cl, a.Params[1])
+	if err != nil {
+		return err
+	}
+	ps, err := prots.Protections(e.P4, path)
+	if err != nil {
+		return err
+	}
+	adv, err := ps.AdviseAll(e.P4, cl, a.Target(), path, reqAccess)
+	if err != nil {
+		return err
+	}
+	if adv.Context != "" {
+		return fmt.Errorf("%s, there's no need to request it", adv.Context)
+	}
+	infos, err := adv.AllInfo(e.P4, path, reqAccess)
+	if err != nil {
+		return err
+	}
+	infos, err = e.resolveOwners(infos)
+	if err != nil {
+		return err
+	}
+	e.Annotations.Annotate(infos)
+	infos, blocked, err := e.applyPolicy(a.Target(), infos)
+	if err != nil {
+		return err
+	}
+	var info prots.Info
+	if len(a.Params) == 3 {
+		info, err = pick(infos, a.Params[2], reqAccess, path)
+		if err != nil {
+			return err
+		}
+		if blocked[info.Group] {
+			return errors.New(info.Note)
+		}
+	} else {
+		// The best group the policy lets them ask for, passing over
+		// groups nobody can approve or that LDAP fills if there's another
+		for _, i := range infos {
+			if blocked[i.Group] {
+				continue
+			}
+			if info.Group == "" || (!requestable(info) && requestable(i)) {
+				info = i
+			}
+		}
+		if info.Group == "" {
+			return errors.New(infos[0].Note)
+		}
+	}
+
+	if info.Ldap != nil {
+		return ldapError(info.Group, info.Ldap)
+	}
+	set, err := e.Approvers.Resolve(e.P4, info.Group)
+	if err != nil {
+		return err
+	}
+	if !canApprove(info) && len(set.Delegates) == 0 {
+		return unowned(info)
+	}
+	if err := set.Reachable(a.Target()); err != nil {
+		return err
+	}
+	// The owners have already been asked, asking again would only spam them
+	pending, err := e.Store.List(requests.Filter{User: a.Target(), Group: info.Group, State: requests.Pending})
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if !p.Removal {
+			return fmt.Errorf("%s already has request %s pending for %s, see 'p4 access status'", a.Target(), p.ID, info.Group)
+		}
+	}
+	r, err := requests.New(a.Target(), info, a.Message)
+	if err != nil {
+		return err
+	}
+	r.Required = set.Required
+	if err := e.Store.Add(r); err != nil {
+		return fmt.Errorf("Failed to save request, %v", err)
+	}
+	e.record("request", map[string]string{
+		"id":            r.ID,
+		"for":           r.User,
+		"group":         info.Group,
+		"access":        reqAccess,
+		"path":          path,
+		"justification": r.Justification,
+	})
+	e.notify("request", r, emails(set.All()),
+		fmt.Sprintf("Access request %s: %s wants %s access to %s", r.ID, r.User, reqAccess, path))
+	e.hook(hookPayload{Event: "request", Access: reqAccess, Path: path, Groups: []prots.Info{info}, Request: r})
+	return io.Output(e.Config, a, "request", requestInfo{r, set.Delegates})
+}
+
+// requestInfo is fed to request.go.tpl, the request along with the
+// delegates who were told as well as its owners
+type requestInfo struct {
+	*requests.Request
+	Delegates []prots.Owner `json:"delegates,omitempty"`
+}
+
+// canApprove checks whether any of the group's owners can approve requests
+func canApprove(info prots.Info) bool {
+	return len(prots.UsableOwners(info.Owners)) > 0
+}
+
+// requestable checks whether a request to join the group can be approved
+// by its owners and would make a difference
+func requestable(info prots.Info) bool {
+	return info.Ldap == nil && canApprove(info)
+}
+
+// unowned explains that nobody can approve requests to join the group
+func unowned(info prots.Info) error {
+	if len(info.Contacts) == 0 {
+		return fmt.Errorf("None of the owners of %s can approve requests, please contact support", info.Group)
+	}
+	return fmt.Errorf("None of the owners of %s can approve requests, ask %s instead: %s",
+		info.Group, info.Fallback, strings.Join(emails(info.Contacts), ", "))
+}
+
+// pick finds the group the user asked for amongst those that give access
+func pick(infos []prots.Info, group, reqAccess, path string) (prots.Info, error) {
+	for _, i := range infos {
+		if i.Group == group {
+			return i, nil
+		}
+	}
+	return prots.Info{}, fmt.Errorf("Group %s doesn't give %s access to %s, see 'p4 access %s %s' for the groups that do",
+		group, reqAccess, path, reqAccess, path)
+}
+
+// emails collects the owners' email addresses
+func emails(owners []prots.Owner) []string {
+	out := []string{}
+	for _, o := range owners {
+		if o.Email != "" {
+			out = append(out, o.Email)
+		}
+	}
+	return out
+}
+
+// notify renders notify_<event>.go.tpl for the request and sends it
+// A failure to notify is logged, the request itself has still been made
+func (e *Env) notify(event string, r *requests.Request, to []string, subject string) {
+	e.send(event, r.ID, r, to, subject)
+}
+
+// send renders notify_<event>.go.tpl with data and sends it, id is only for the log
+func (e *Env) send(event, id string, data interface{}, to []string, subject string) {
+	if e.Notify == nil || len(to) == 0 {
+		return
+	}
+	body, err := io.Render(e.Config, "notify_"+event, data)
+	if err != nil {
+		log.Printf("Failed to notify %v of %s %s, %v", to, event, id, err)
+		return
+	}
+	err = e.Notify.Notify(notify.Event{Type: event, To: to, Subject: subject, Body: body})
+	if err != nil {
+		log.Printf("Failed to notify %v of %s %s, %v", to, event, id, err)
+	}
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/brettbates/p4access/approvers"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/notify"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeNotifier keeps the events it's sent
+type FakeNotifier struct {
+	events []notify.Event
+}
+
+func (n *FakeNotifier) Notify(ev notify.Event) error {
+	n.events = append(n.events, ev)
+	return nil
+}
+
+// ids are replaced so responses can be compared
+var ids = regexp.MustCompile(`R[0-9a-f]{8}`)
+
+// updated times are set by the store when a request is saved, they are replaced too
+var updated = regexp.MustCompile(`(\\"updated\\": \\")[^\\]*`)
+
+// FakeRequest mocks p4r so that //depot/Jam/MAIN/... can be requested
+// P_jam_main is the best group, P_jam the next best
+func FakeRequest(fp4 *FakeP4Runner, user string) {
+	FakeProtects(fp4, user)
+	FakeGroup(fp4, "P_jam", "jam.owner")
+	FakeGroup(fp4, "P_jam_main", "main.owner")
+}
+
+// FakeProtects mocks p4r so P_jam and P_jam_main give write access to
+// //depot/Jam/MAIN/... and the user has none, without the group specs
+func FakeProtects(fp4 *FakeP4Runner, user string) {
+	path := "//depot/Jam/MAIN/..."
+	pwrite := []map[interface{}]interface{}{{"permMax": "write"}}
+	fp4.On("Run", []string{"protects", "-a", path}).Return([]map[interface{}]interface{}{
+		{"perm": "write", "host": "*", "user": "P_jam", "isgroup": "", "line": "1", "depotFile": "//depot/Jam/..."},
+		{"perm": "write", "host": "*", "user": "P_jam_main", "isgroup": "", "line": "2", "depotFile": "//depot/Jam/MAIN/..."},
+	}, nil).
+		On("Run", []string{"protects", "-M", "-u", user, path}).Return(
+		[]map[interface{}]interface{}{{"permMax": "list"}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_jam", path}).Return(pwrite, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_jam_main", path}).Return(pwrite, nil)
+}
+
+type requestTest struct {
+	params []string
+	msg    string
+	want   string
+	to     []string
+	err    error
+}
+
+var requestTests = []requestTest{
+	{
+		[]string{"write", "//depot/Jam/MAIN/..."},
+		"Fixing \"bug\" 123",
+		"request.txt",
+		[]string{"main.owner@email.com"},
+		nil,
+	},
+	{ // Choosing a less specific group
+		[]string{"write", "//depot/Jam/MAIN/...", "P_jam"},
+		"Fixing bug 123",
+		"request_group.txt",
+		[]string{"jam.owner@email.com"},
+		nil,
+	},
+	{
+		[]string{"write", "//depot/Jam/MAIN/...", "P_other"},
+		"Fixing bug 123",
+		"",
+		nil,
+		errors.New("Group P_other doesn't give write access to //depot/Jam/MAIN/..., " +
+			"see 'p4 access write //depot/Jam/MAIN/...' for the groups that do"),
+	},
+	{
+		[]string{"write", "//depot/Jam/MAIN/..."},
+		"",
+		"",
+		nil,
+		errors.New("A justification is required, add -m \"why you need access\"\n" +
+			"Usage: p4 access request [-j -u user] <read|write> path [group] -m justification"),
+	},
+	{
+		[]string{"super", "//depot/Jam/MAIN/..."},
+		"Because",
+		"",
+		nil,
+		errors.New("Unknown access level 'super', must be read or write\n" +
+			"Usage: p4 access request [-j -u user] <read|write> path [group] -m justification"),
+	},
+}
+
+func TestRequest(t *testing.T) {
+	for _, tst := range requestTests {
+		fp4 := &FakeP4Runner{}
+		FakeRequest(fp4, "a.user")
+		fn := &FakeNotifier{}
+		st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+		args := io.Args{User: "a.user", Command: "request", Params: tst.params, Message: tst.msg}
+		res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: fn, Store: st, Mode: io.Broker})
+		saved, lerr := st.List(requests.Filter{})
+		assert.Nil(t, lerr)
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			assert.Empty(t, fn.events)
+			assert.Empty(t, saved)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Len(t, saved, 1)
+		assert.Equal(t, ids.FindString(res), saved[0].ID)
+		assert.Equal(t, requests.Pending, saved[0].State)
+		check(t, tst.want, ids.ReplaceAllString(res, "RID"))
+		assert.Len(t, fn.events, 1)
+		assert.Equal(t, "request", fn.events[0].Type)
+		assert.Equal(t, tst.to, fn.events[0].To)
+		// The id given to the user is the one the owners see
+		assert.Equal(t, ids.FindString(res), ids.FindString(fn.events[0].Body))
+	}
+}
+
+func TestRequestApprovers(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	fp4.On("Run", []string{"user", "-o", "jam.lead"}).Return(
+		[]map[interface{}]interface{}{{"User": "jam.lead", "Email": "jam.lead@email.com", "FullName": "jam.lead"}}, nil)
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	args := io.Args{User: "a.user", Command: "request", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "Because"}
+	res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: fn, Store: st, Approvers: twoApprovers, Mode: io.Broker})
+	assert.Nil(t, err)
+	check(t, "request_approvers.txt", ids.ReplaceAllString(res, "RID"))
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, saved[0].Required)
+	assert.Equal(t, []string{"main.owner@email.com", "jam.lead@email.com"}, fn.events[0].To)
+	assert.Contains(t, fn.events[0].Body, "It needs 2 approvals")
+}
+
+func TestRequestUnreachable(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	fp4.On("Run", []string{"user", "-o", "jam.lead"}).Return(
+		[]map[interface{}]interface{}{{"User": "jam.lead", "Email": "jam.lead@email.com", "FullName": "jam.lead"}}, nil)
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	three := &approvers.Resolver{Rules: []approvers.Rule{
+		{Name: "main", Groups: []string{"P_jam_*"}, Delegates: []string{"jam.lead"}, Required: 3},
+	}}
+	args := io.Args{User: "a.user", Command: "request", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "Because"}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: fn, Store: st, Approvers: three, Mode: io.Broker})
+	// A request nobody could ever finish approving isn't filed
+	assert.EqualError(t, err, "P_jam_main needs 3 approvals but only 2 people can approve it, please contact support")
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(t, err)
+	assert.Empty(t, saved)
+	assert.Empty(t, fn.events)
+}
+
+func TestRequestTwice(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	FakeRequest(fp4, "a.user")
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	args := io.Args{User: "a.user", Command: "request", Params: []string{"write", "//depot/Jam/MAIN/..."}, Message: "Because"}
+	e := &Env{Config: testConfig(), P4: fp4, Args: args, Notify: fn, Store: st, Mode: io.Broker}
+	_, err := run(e)
+	assert.Nil(t, err)
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(t, err)
+	// The owners are only asked once while it's pending
+	_, err = run(e)
+	assert.EqualError(t, err, "a.user already has request "+saved[0].ID+" pending for P_jam_main, see 'p4 access status'")
+	assert.Len(t, fn.events, 1)
+	saved, err = st.List(requests.Filter{})
+	assert.Nil(t, err)
+	assert.Len(t, saved, 1)
+}
+
+func TestRequestHasAccess(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"protects", "-a", "//depot/..."}).Return([]map[interface{}]interface{}{
+		{"perm": "read", "host": "*", "user": "P_all", "isgroup": "", "line": "1", "depotFile": "//depot/..."},
+	}, nil).
+		On("Run", []string{"protects", "-M", "-u", "a.user", "//depot/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_all", "//depot/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "read"}}, nil)
+	args := io.Args{User: "a.user", Command: "request", Params: []string{"read", "//depot/..."}, Message: "Because"}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Notify: &FakeNotifier{}, Mode: io.Broker})
+	assert.EqualError(t, err, "User a.user already has read access or higher to //depot/..., there's no need to request it")
+}
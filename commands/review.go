@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "review",
+		Usage:   "review [-j -u user] campaign [keep|remove group [user ...]]",
+		Summary: "confirm who should still be in your groups during a recertification",
+		Help: `Shows the members of each group you review in the campaign and what has
+been decided about them so far.
+
+keep group [user ...]    Confirm the users should stay in the group, without
+                         users every member not yet decided on is kept
+remove group user ...    Take the users out of the group straight away
+
+Members nobody has confirmed by the deadline are removed or escalated.
+
+-j       Respond with json instead of text
+-u user  Show the reviews of another owner, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    review,
+	})
+}
+
+// review shows or records decisions for 'p4 access review campaign [keep|remove group [user ...]]'
+func review(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) == 0 {
+		return c.UsageError(e, "Expected a campaign ID")
+	}
+	if len(a.Params) != 1 && len(a.Params) < 3 {
+		return c.UsageError(e, "Expected keep or remove with a group")
+	}
+	if _, err := e.client(); err != nil {
+		return err
+	}
+	var cp *requests.Campaign
+	var err error
+	if len(a.Params) > 1 {
+		cp, err = e.reviewDecide(c, a.Params[0])
+	} else {
+		cp, err = e.Store.GetCampaign(a.Params[0])
+	}
+	if err != nil {
+		return err
+	}
+	out := newReviewsInfo(cp, a.Target())
+	for _, r := range cp.Reviews {
+		if isOwner(r.Owners, a.Target()) {
+			out.Reviews = append(out.Reviews, r)
+		}
+	}
+	return io.Output(e.Config, a, "review", out)
+}
+
+// reviewDecide keeps or removes members of one of the campaign's groups
+// The decisions are made inside the store's lock, so owners reviewing at
+// the same time, or the campaign closing, can't undo each other
+// Removals are saved one at a time once the user is out of the group, so
+// p4 isn't run while the lock is held and a failure part way through
+// still leaves the earlier removals recorded
+func (e *Env) reviewDecide(c *Command, id string) (*requests.Campaign, error) {
+	a := e.Args
+	decision, group, users := a.Params[1], a.Params[2], a.Params[3:]
+	if decision != requests.Keep && decision != requests.Remove {
+		return nil, c.UsageError(e, fmt.Sprintf("Unknown decision '%s', expected keep or remove", decision))
+	}
+	if decision == requests.Remove && len(users) == 0 {
+		return nil, c.UsageError(e, "Name the users to remove")
+	}
+	cp, err := e.Store.GetCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := reviewMembers(cp, group, users, a.User)
+	if err != nil {
+		return nil, err
+	}
+	if decision == requests.Keep {
+		err := e.Store.ModifyCampaign(id, func(saved *requests.Campaign) error {
+			cp = saved
+			// Looked up again, someone may have decided meanwhile
+			ms, err := reviewMembers(saved, group, users, a.User)
+			if err != nil {
+				return err
+			}
+			members = ms
+			now := time.Now()
+			for _, m := range members {
+				if err := m.Decide(decision, a.User, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			e.recordReview(cp.ID, group, m.User, decision)
+		}
+		return cp, nil
+	}
+	for _, m := range members {
+		if m.Decision == requests.Remove {
+			return nil, fmt.Errorf("%s has already been removed by %s", m.User, m.By)
+		}
+		if err := prots.RemoveUser(e.P4, group, m.User); err != nil {
+			return nil, fmt.Errorf("Failed to remove %s from %s, %v", m.User, group, err)
+		}
+		e.recordReview(cp.ID, group, m.User, decision)
+		user := m.User
+		err := e.Store.ModifyCampaign(id, func(saved *requests.Campaign) error {
+			cp = saved
+			ms, err := reviewMembers(saved, group, []string{user}, a.User)
+			if err != nil {
+				return err
+			}
+			return ms[0].Decide(decision, a.User, time.Now())
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Removed %s from %s but failed to save it, %v", user, group, err)
+		}
+	}
+	return cp, nil
+}
+
+// reviewMembers finds the named members of the group's review, or all the
+// undecided ones, checking the campaign is open and the user owns the group
+func reviewMembers(cp *requests.Campaign, group string, users []string, owner string) ([]*requests.Member, error) {
+	if cp.Closed != nil {
+		return nil, fmt.Errorf("Campaign %s was closed on %s", cp.ID, cp.Closed.Format("2006-01-02"))
+	}
+	r, err := cp.Review(group)
+	if err != nil {
+		return nil, err
+	}
+	if !isOwner(r.Owners, owner) {
+		return nil, fmt.Errorf("Only the owners of %s can review its members", group)
+	}
+	if len(users) == 0 {
+		return r.Undecided(), nil
+	}
+	members := []*requests.Member{}
+	for _, u := range users {
+		m, err := r.Member(u)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// recordReview audits an owner's decision about one member
+func (e *Env) recordReview(id, group, user, decision string) {
+	e.record("review", map[string]string{
+		"campaign": id,
+		"group":    group,
+		"for":      user,
+		"decision": decision,
+	})
+}
+
+// isOwner checks whether the user is one of the owners
+func isOwner(owners []prots.Owner, user string) bool {
+	for _, o := range owners {
+		if o.User == user {
+			return true
+		}
+	}
+	return false
+}
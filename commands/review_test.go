@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/audit"
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// FakeCampaign returns a store holding campaign C00000001, now past its
+// deadline, where jam.owner has kept a.user in P_jam but not decided on
+// b.user or c.user
+func FakeCampaign(t *testing.T) *requests.FileStore {
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	kept := time.Date(2021, 3, 10, 10, 0, 0, 0, time.UTC)
+	err := st.AddCampaign(&requests.Campaign{
+		ID: "C00000001",
+		Reviews: []*requests.Review{{
+			Group:  "P_jam",
+			Owners: []prots.Owner{{User: "jam.owner", FullName: "jam.owner", Email: "jam.owner@email.com"}},
+			Members: []*requests.Member{
+				{User: "a.user", Decision: requests.Keep, By: "jam.owner", Time: &kept},
+				{User: "b.user"},
+				{User: "c.user"},
+			},
+		}},
+		Deadline: time.Date(2021, 3, 23, 10, 0, 0, 0, time.UTC),
+		Created:  time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC),
+	})
+	assert.Nil(t, err)
+	return st
+}
+
+type reviewTest struct {
+	user   string
+	params []string
+	want   string
+	err    error
+}
+
+var reviewTests = []reviewTest{
+	{"jam.owner", []string{"C00000001"}, "review.txt", nil},
+	{"a.user", []string{"C00000001"}, "review_none.txt", nil},
+	{"jam.owner", []string{"C00000001", "remove", "P_jam", "b.user"}, "review_remove.txt", nil},
+	{"jam.owner", []string{"C00000001", "keep", "P_jam"}, "review_keep.txt", nil},
+	{"jam.owner", []string{}, "",
+		errors.New("Expected a campaign ID\nUsage: p4 access review [-j -u user] campaign [keep|remove group [user ...]]")},
+	{"jam.owner", []string{"C00000001", "keep"}, "",
+		errors.New("Expected keep or remove with a group\nUsage: p4 access review [-j -u user] campaign [keep|remove group [user ...]]")},
+	{"jam.owner", []string{"C00000001", "remove", "P_jam"}, "",
+		errors.New("Name the users to remove\nUsage: p4 access review [-j -u user] campaign [keep|remove group [user ...]]")},
+	{"jam.owner", []string{"C00000001", "drop", "P_jam"}, "",
+		errors.New("Unknown decision 'drop', expected keep or remove\nUsage: p4 access review [-j -u user] campaign [keep|remove group [user ...]]")},
+	{"jam.owner", []string{"C99999999"}, "", errors.New("No such campaign 'C99999999'")},
+	{"jam.owner", []string{"C00000001", "keep", "P_other"}, "", errors.New("Campaign C00000001 doesn't review P_other")},
+	{"jam.owner", []string{"C00000001", "keep", "P_jam", "d.user"}, "", errors.New("d.user isn't a member of P_jam in this review")},
+	{"a.user", []string{"C00000001", "keep", "P_jam", "a.user"}, "", errors.New("Only the owners of P_jam can review its members")},
+}
+
+func TestReview(t *testing.T) {
+	for _, tst := range reviewTests {
+		fp4 := &FakeP4Runner{}
+		FakeRecertify(fp4)
+		st := FakeCampaign(t)
+		args := io.Args{User: tst.user, Command: "review", Params: tst.params}
+		res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Store: st, Mode: io.Broker})
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			fp4.AssertNotCalled(t, "Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tc.user\n\n")
+			continue
+		}
+		assert.Nil(t, err)
+		check(t, tst.want, res)
+	}
+}
+
+func TestReviewRemoved(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeRecertify(fp4)
+	st := FakeCampaign(t)
+	e := &Env{Config: testConfig(), P4: fp4, Store: st, Mode: io.Broker}
+	e.Args = io.Args{User: "jam.owner", Command: "review", Params: []string{"C00000001", "remove", "P_jam", "b.user"}}
+	_, err := run(e)
+	assert.Nil(err)
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tc.user\n\n")
+	// Kept members can be removed, but not the other way round
+	e.Args.Params = []string{"C00000001", "keep", "P_jam", "b.user"}
+	_, err = run(e)
+	assert.EqualError(err, "b.user has already been removed by jam.owner")
+
+	// A failed removal leaves the campaign as it was
+	e.Args.Params = []string{"C00000001", "remove", "P_jam", "c.user"}
+	_, err = run(e)
+	assert.EqualError(err, "Failed to remove c.user from P_jam, p4 group -i failed, exit status 1")
+	cp, err := st.GetCampaign("C00000001")
+	assert.Nil(err)
+	m, err := cp.Reviews[0].Member("c.user")
+	assert.Nil(err)
+	assert.Empty(m.Decision)
+}
+
+// unlocked fails the test if the store is locked while p4 changes a group
+func unlocked(t *testing.T, st *requests.FileStore) func(mock.Arguments) {
+	return func(mock.Arguments) {
+		done := make(chan error, 1)
+		go func() {
+			_, err := st.Campaigns()
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			assert.Nil(t, err)
+		case <-time.After(time.Second):
+			t.Error("The store is locked while p4 runs")
+		}
+	}
+}
+
+func TestReviewPartial(t *testing.T) {
+	assert := assert.New(t)
+	st := FakeCampaign(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tc.user\n\n").
+		Run(unlocked(t, st)).Return("Group P_jam updated.", nil)
+	FakeRecertify(fp4)
+	al := filepath.Join(t.TempDir(), "audit.log")
+	args := io.Args{User: "jam.owner", Command: "review", Params: []string{"C00000001", "remove", "P_jam", "b.user", "c.user"}}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Store: st, Audit: audit.New(al), Mode: io.Broker})
+	assert.EqualError(err, "Failed to remove c.user from P_jam, p4 group -i failed, exit status 1")
+
+	// b.user was taken out before c.user failed, that still counts
+	cp, err := st.GetCampaign("C00000001")
+	assert.Nil(err)
+	for user, want := range map[string]string{"b.user": requests.Remove, "c.user": ""} {
+		m, err := cp.Reviews[0].Member(user)
+		assert.Nil(err)
+		assert.Equal(want, m.Decision, user)
+	}
+	b, err := ioutil.ReadFile(al)
+	assert.Nil(err)
+	assert.Equal(1, strings.Count(string(b), `"event":"review"`))
+	assert.Contains(string(b), `"for":"b.user"`)
+	assert.NotContains(string(b), `"for":"c.user"`)
+}
+
+func TestReviewRace(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeRecertify(fp4)
+	// Another owner removes b.user while jam.owner's keep is starting up
+	st := racingStore{FileStore: FakeCampaign(t), beforeCampaign: func(cp *requests.Campaign) error {
+		m, err := cp.Reviews[0].Member("b.user")
+		if err != nil {
+			return err
+		}
+		return m.Decide(requests.Remove, "other.owner", time.Now())
+	}}
+	args := io.Args{User: "jam.owner", Command: "review", Params: []string{"C00000001", "keep", "P_jam"}}
+	_, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Store: st, Mode: io.Broker})
+	assert.Nil(err)
+	cp, err := st.GetCampaign("C00000001")
+	assert.Nil(err)
+	// Only c.user was still undecided, the removal stands
+	for user, want := range map[string]string{"a.user": requests.Keep, "b.user": requests.Remove, "c.user": requests.Keep} {
+		m, err := cp.Reviews[0].Member(user)
+		assert.Nil(err)
+		assert.Equal(want, m.Decision, user)
+	}
+}
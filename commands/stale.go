@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "stale",
+		Usage:   "stale [-j -e age] [suggest] [group ...]",
+		Summary: "find group members who no longer use their access",
+		Help: `Lists the members of each group, or of every group, who haven't used the
+server for P4ACCESS_STALEAFTER, who have no user spec any more, or who have
+never submitted to the paths the group lets them write to.
+
+'suggest' also files a request to remove each of them, which the group's
+owners approve or deny like any other request. A member is only suggested
+once while the last suggestion is pending. Groups synchronised from LDAP are
+listed but nothing is suggested for them, P4ACCESS_LDAPURL or
+P4ACCESS_LDAPCONTACT is given as where to take them out instead.
+
+-e age  How long without using the server counts as stale, e.g. 90d
+-j      Respond with json instead of text`,
+		Direct: true,
+		Run:    stale,
+	})
+}
+
+// staleMember is a member of a group and why they look stale
+type staleMember struct {
+	User    string     `json:"user"`
+	Access  *time.Time `json:"access,omitempty"` // When they last used the server
+	Reason  string     `json:"reason"`
+	Request string     `json:"request,omitempty"` // The removal suggested for them
+}
+
+// staleGroup is a group with stale members
+type staleGroup struct {
+	Group   string         `json:"group"`
+	Members []*staleMember `json:"members"`
+	Ldap    *prots.Ldap    `json:"ldap,omitempty"` // Set when 'p4 ldapsync' fills it, removals are made in LDAP
+}
+
+// staleInfo is fed to stale.go.tpl
+type staleInfo struct {
+	Since  time.Time     `json:"since"`
+	Groups []*staleGroup `json:"groups"`
+}
+
+// stale reports, and optionally suggests removing, stale members for 'p4access stale'
+func stale(e *Env) error {
+	a := e.Args
+	groups := a.Params
+	suggest := len(groups) > 0 && groups[0] == "suggest"
+	if suggest {
+		groups = groups[1:]
+	}
+	var err error
+	if len(groups) == 0 {
+		if groups, err = prots.AllGroups(e.P4); err != nil {
+			return err
+		}
+	}
+	ps, err := prots.Protections(e.P4, "")
+	if err != nil {
+		return err
+	}
+	age := e.Config.StaleAfter
+	if a.Expires != 0 {
+		age = a.Expires
+	}
+	out := staleInfo{time.Now().Add(-age).UTC(), []*staleGroup{}}
+	for _, name := range groups {
+		sg, err := e.staleGroup(ps, name, out.Since)
+		if err != nil {
+			return err
+		}
+		if len(sg.Members) == 0 {
+			continue
+		}
+		// Anyone taken out of an LDAP group would be put back by 'p4 ldapsync'
+		if suggest && sg.Ldap == nil {
+			if err := e.suggestRemovals(sg); err != nil {
+				return err
+			}
+		}
+		out.Groups = append(out.Groups, sg)
+	}
+	return io.Output(e.Config, a, "stale", out)
+}
+
+// staleGroup checks each user named in the group
+func (e *Env) staleGroup(ps prots.Prots, name string, since time.Time) (*staleGroup, error) {
+	sg := &staleGroup{Group: name, Members: []*staleMember{}}
+	g, err := prots.GetGroup(e.P4, name)
+	if err != nil {
+		return nil, err
+	}
+	sg.Ldap = g.Ldap
+	e.ldap(sg.Ldap)
+	if len(g.Users) == 0 {
+		return sg, nil
+	}
+	accounts, err := prots.Accounts(e.P4, g.Users)
+	if err != nil {
+		return nil, err
+	}
+	gps, err := ps.Paths(e.P4, name)
+	if err != nil {
+		return nil, err
+	}
+	writes := []string{}
+	for _, gp := range gps {
+		if prots.CanWrite(gp.Perm) && !contains(writes, gp.Prot.DepotFile) {
+			writes = append(writes, gp.Prot.DepotFile)
+		}
+	}
+	for _, u := range g.Users {
+		m := &staleMember{User: u}
+		acct, ok := accounts[u]
+		switch {
+		case !ok:
+			m.Reason = "has no user spec"
+		case acct.Access.IsZero():
+			m.Reason = "has never used the server"
+		case acct.Access.Before(since):
+			m.Access = &acct.Access
+			m.Reason = "hasn't used the server since " + acct.Access.Format("2006-01-02")
+		case len(writes) > 0:
+			// Only worth asking about someone who is still around
+			m.Access = &acct.Access
+			touched, err := touched(e.P4, u, writes)
+			if err != nil {
+				return nil, err
+			}
+			if !touched {
+				m.Reason = "has never submitted to " + strings.Join(writes, ", ")
+			}
+		}
+		if m.Reason != "" {
+			sg.Members = append(sg.Members, m)
+		}
+	}
+	return sg, nil
+}
+
+// touched checks whether the user has ever submitted to any of the paths
+func touched(p4r prots.P4Runner, user string, paths []string) (bool, error) {
+	for _, p := range paths {
+		last, err := prots.LastChange(p4r, user, p)
+		if err != nil {
+			return false, err
+		}
+		if !last.IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// suggestRemovals files a removal request for each stale member that
+// doesn't already have one pending and tells the group's approvers
+func (e *Env) suggestRemovals(sg *staleGroup) error {
+	pending, err := e.Store.List(requests.Filter{Group: sg.Group, State: requests.Pending})
+	if err != nil {
+		return err
+	}
+	set, err := e.Approvers.Resolve(e.P4, sg.Group)
+	if err != nil {
+		return err
+	}
+	for _, m := range sg.Members {
+		for _, p := range pending {
+			if p.Removal && p.User == m.User {
+				m.Request = p.ID
+			}
+		}
+		if m.Request != "" {
+			continue
+		}
+		r, err := requests.New(m.User, prots.Info{Group: sg.Group, Owners: set.Owners},
+			m.User+" "+m.Reason)
+		if err != nil {
+			return err
+		}
+		r.Removal = true
+		r.Required = 1
+		if err := e.Store.Add(r); err != nil {
+			return fmt.Errorf("Failed to save request, %v", err)
+		}
+		m.Request = r.ID
+		e.record("suggest", map[string]string{
+			"id":            r.ID,
+			"for":           r.User,
+			"group":         sg.Group,
+			"justification": r.Justification,
+		})
+		e.notify("removal", r, emails(set.All()),
+			fmt.Sprintf("Removal %s: %s looks stale in %s", r.ID, r.User, sg.Group))
+	}
+	return nil
+}
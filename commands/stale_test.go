@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeStale mocks p4r so P_jam gives write access to //depot/Jam/... and has
+// a.user, who submits there, b.user, last seen in 2020, c.user, who has
+// never logged in, d.user, who has been deleted, and e.user, who only reads
+func FakeStale(fp4 *FakeP4Runner) {
+	recent := fmt.Sprint(time.Now().Add(-24 * time.Hour).Unix())
+	fp4.On("Run", []string{"groups"}).Return([]map[interface{}]interface{}{{"group": "P_jam"}}, nil).
+		On("Run", []string{"group", "-o", "P_jam"}).Return([]map[interface{}]interface{}{{
+		"Group":   "P_jam",
+		"Owners0": "jam.owner",
+		"Users0":  "a.user",
+		"Users1":  "b.user",
+		"Users2":  "c.user",
+		"Users3":  "d.user",
+		"Users4":  "e.user",
+	}}, nil).
+		On("Run", []string{"users", "-a", "a.user", "b.user", "c.user", "d.user", "e.user"}).Return([]map[interface{}]interface{}{
+		{"User": "a.user", "Access": recent},
+		{"User": "b.user", "Access": "1583748000"},
+		{"User": "c.user", "Access": "0"},
+		{"User": "e.user", "Access": recent},
+	}, nil).
+		On("Run", []string{"protects", "-a"}).Return([]map[interface{}]interface{}{
+		{"perm": "write", "host": "*", "user": "P_jam", "isgroup": "", "line": "1", "depotFile": "//depot/Jam/..."},
+	}, nil).
+		On("Run", []string{"groups", "-i", "-g", "P_jam"}).Return([]map[interface{}]interface{}{}, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_jam", "//depot/Jam/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+		On("Run", []string{"changes", "-m1", "-s", "submitted", "-u", "a.user", "//depot/Jam/..."}).Return(
+		[]map[interface{}]interface{}{{"change": "12", "time": recent}}, nil).
+		On("Run", []string{"changes", "-m1", "-s", "submitted", "-u", "e.user", "//depot/Jam/..."}).Return(
+		[]map[interface{}]interface{}{}, nil)
+	FakeGroup(fp4, "P_jam", "jam.owner")
+}
+
+func TestStale(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeStale(fp4)
+	c := testConfig()
+	c.StaleAfter = 90 * 24 * time.Hour
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	e := &Env{Config: c, P4: fp4, Args: io.Args{Command: "stale"}, Store: st, Mode: io.Direct}
+	res, err := run(e)
+	assert.Nil(err)
+	check(t, "stale.txt", res)
+	saved, err := st.List(requests.Filter{})
+	assert.Nil(err)
+	assert.Empty(saved)
+
+	// b.user isn't stale over a longer time, but hasn't submitted either
+	fp4.On("Run", []string{"changes", "-m1", "-s", "submitted", "-u", "b.user", "//depot/Jam/..."}).Return(
+		[]map[interface{}]interface{}{}, nil)
+	e.Args = io.Args{Command: "stale", Params: []string{"P_jam"}, Expires: 20 * 365 * 24 * time.Hour}
+	res, err = run(e)
+	assert.Nil(err)
+	assert.Contains(res, "    b.user has never submitted to //depot/Jam/...\n")
+}
+
+func TestStaleSuggest(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	FakeStale(fp4)
+	FakeDecide(fp4, nil)
+	fp4.On("Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tb.user\n\tc.user\n\td.user\n\n").
+		Return("Group P_jam updated.", nil)
+	c := testConfig()
+	c.StaleAfter = 90 * 24 * time.Hour
+	fn := &FakeNotifier{}
+	st := requests.NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	e := &Env{Config: c, P4: fp4, Args: io.Args{Command: "stale", Params: []string{"suggest"}}, Notify: fn, Store: st, Mode: io.Direct}
+	res, err := run(e)
+	assert.Nil(err)
+	saved, err := st.List(requests.Filter{State: requests.Pending})
+	assert.Nil(err)
+	assert.Len(saved, 4)
+	for _, r := range saved {
+		assert.True(r.Removal)
+		assert.Contains(res, r.User+" ")
+		assert.Contains(res, "removal "+r.ID)
+	}
+	assert.Equal("e.user has never submitted to //depot/Jam/...", saved[3].Justification)
+	assert.Len(fn.events, 4)
+	assert.Equal("removal", fn.events[3].Type)
+	assert.Equal([]string{"jam.owner@email.com"}, fn.events[3].To)
+	check(t, "notify_removal.txt", ids.ReplaceAllString(fn.events[3].Body, "RID"))
+
+	// Running it again doesn't suggest them twice
+	_, err = run(e)
+	assert.Nil(err)
+	all, err := st.List(requests.Filter{})
+	assert.Nil(err)
+	assert.Len(all, 4)
+
+	// Approving a removal takes them out of the group, only the owners are told
+	fn.events = nil
+	e.Args = io.Args{User: "jam.owner", Command: "approve", Params: []string{saved[3].ID}}
+	e.Mode = io.Broker
+	res, err = run(e)
+	assert.Nil(err)
+	assert.Contains(res, "Removal "+saved[3].ID+" has been approved, e.user has been taken out of P_jam.")
+	fp4.AssertCalled(t, "Input", []string{"group", "-i"}, "Group: P_jam\n\nOwners:\n\tjam.owner\n\nUsers:\n\ta.user\n\tb.user\n\tc.user\n\td.user\n\n")
+	assert.Empty(fn.events)
+	r, err := st.Get(saved[3].ID)
+	assert.Nil(err)
+	assert.Equal(requests.Approved, r.State)
+
+	// And never shows in their status
+	e.Args = io.Args{User: "e.user", Command: "status"}
+	res, err = run(e)
+	assert.Nil(err)
+	assert.Contains(res, "e.user hasn't made any requests")
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/requests"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "status",
+		Usage:   "status [-j -u user]",
+		Summary: "show the requests you have made and where they are up to",
+		Help: `Lists every request you have made, oldest first, with whether it is still
+pending or who approved or denied it and why.
+
+-j       Respond with json instead of text
+-u user  Show the requests of another user, admins only`,
+		Broker: true,
+		Direct: true,
+		Run:    status,
+	})
+}
+
+// statusInfo is fed to status.go.tpl
+type statusInfo struct {
+	User     string              `json:"user"`
+	Requests []*requests.Request `json:"requests"`
+}
+
+// status lists the user's requests for 'p4 access status'
+func status(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) != 0 {
+		return c.UsageError(e, fmt.Sprintf("Too many arguments, expected none, got %d", len(a.Params)))
+	}
+	if _, err := e.client(); err != nil {
+		return err
+	}
+	rs, err := e.Store.List(requests.Filter{User: a.Target()})
+	if err != nil {
+		return err
+	}
+	// Suggestions to remove them weren't made by them
+	mine := []*requests.Request{}
+	for _, r := range rs {
+		if !r.Removal {
+			mine = append(mine, r)
+		}
+	}
+	return io.Output(e.Config, a, "status", statusInfo{a.Target(), mine})
+}
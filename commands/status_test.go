@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeRequests adds to FakeStore's R00000001 a denied request from a.user
+// to join P_jam and a pending one from b.user
+func FakeRequests(t *testing.T) *requests.FileStore {
+	st := FakeStore(t)
+	for _, r := range []*requests.Request{
+		{ID: "R00000002", User: "a.user", State: requests.Denied, Approver: "jam.owner", Reason: "Use P_jam_main"},
+		{ID: "R00000003", User: "b.user", State: requests.Pending},
+	} {
+		r.Info = prots.Info{Path: "//depot/Jam/...", Access: "write", Group: "P_jam"}
+		r.Justification = "Fixing bug 456"
+		r.Created = time.Date(2021, 3, 10, 10, 0, 0, 0, time.UTC)
+		assert.Nil(t, st.Add(r))
+	}
+	return st
+}
+
+type statusTest struct {
+	user string
+	as   string
+	json bool
+	want string
+	err  error
+}
+
+var statusTests = []statusTest{
+	{"a.user", "", false, "status.txt", nil},
+	{"a.user", "", true, "status.json", nil},
+	{"c.user", "", false, "status_none.txt", nil},
+	{"admin", "b.user", false, "status_as.txt", nil},
+	{"a.user", "b.user", false, "", errors.New("Only admins can use -u, user a.user is not an admin")},
+}
+
+func TestStatus(t *testing.T) {
+	for _, tst := range statusTests {
+		fp4 := &FakeP4Runner{}
+		FakeAdmin(fp4, "admin", "a.user")
+		args := io.Args{User: tst.user, As: tst.as, Command: "status", JSON: tst.json}
+		res, err := run(&Env{Config: testConfig(), P4: fp4, Args: args, Store: FakeRequests(t), Mode: io.Broker})
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			continue
+		}
+		assert.Nil(t, err)
+		check(t, tst.want, updated.ReplaceAllString(res, "${1}UPDATED"))
+	}
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/prots"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "who",
+		Usage:   "who [-j] <read|write> path",
+		Summary: "list the groups and users with access to a path",
+		Help: `Lists every group and user that has at least read or write access to path,
+with how many users are in each group (including subgroups).
+Exclusions in the protections table are taken into account.
+
+Depending on how p4 access is set up, this is only available to admins,
+or to admins and the owners of a group that grants access to the path.
+
+-j       Respond with json instead of text`,
+		Broker: true,
+		Direct: true,
+		Run:    who,
+	})
+}
+
+// whoInfo is fed to who.go.tpl
+type whoInfo struct {
+	Access string        `json:"access"`
+	Path   string        `json:"path"`
+	Grants []prots.Grant `json:"grants"`
+}
+
+// who lists who has access for 'p4 access who read|write path'
+func who(e *Env) error {
+	a := e.Args
+	c, _ := Lookup(a.Command)
+	if len(a.Params) != 2 {
+		return c.UsageError(e, fmt.Sprintf("Expected an access level and a path, got %d arguments", len(a.Params)))
+	}
+	reqAccess := a.Params[0]
+	if reqAccess != "read" && reqAccess != "write" {
+		return c.UsageError(e, fmt.Sprintf("Unknown access level '%s', must be read or write", reqAccess))
+	}
+	admin, err := e.whoAdmin()
+	if err != nil {
+		return err
+	}
+	cl, err := e.client()
+	if err != nil {
+		return err
+	}
+	path, err := prots.Where(e.P4, cl, a.Params[1])
+	if err != nil {
+		return err
+	}
+	ps, err := prots.Protections(e.P4, path)
+	if err != nil {
+		return err
+	}
+	// Check ownership before expanding anyone's membership
+	if e.Config.Who == "owners" && !admin {
+		owner, err := ps.OwnsGrant(e.P4, a.User, path, reqAccess)
+		if err != nil {
+			return err
+		}
+		if !owner {
+			return fmt.Errorf("Only admins and owners of a group with %s access to %s can use 'p4 access who'", reqAccess, path)
+		}
+	}
+	grants, err := ps.Who(e.P4, path, reqAccess)
+	if err != nil {
+		return err
+	}
+	e.record("who", map[string]string{"access": reqAccess, "path": path})
+	return io.Output(e.Config, a, "who", whoInfo{reqAccess, path, grants})
+}
+
+// whoAdmin checks P4ACCESS_WHO for commands that show who has access to
+// what, refusing everyone but admins when only they can use them
+// It reports whether the user is an admin, owners must be checked after
+func (e *Env) whoAdmin() (bool, error) {
+	switch e.Config.Who {
+	case "all":
+		return false, nil
+	case "owners", "admins":
+	default:
+		return false, fmt.Errorf("P4ACCESS_WHO must be all, owners or admins, not '%s'", e.Config.Who)
+	}
+	admin, err := prots.IsAdmin(e.P4, e.Args.User)
+	if err != nil {
+		return false, err
+	}
+	if !admin && e.Config.Who == "admins" {
+		return false, fmt.Errorf("Only admins can use 'p4 access %s'", e.Args.Command)
+	}
+	return admin, nil
+}
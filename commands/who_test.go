@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brettbates/p4access/io"
+	"github.com/stretchr/testify/assert"
+)
+
+type whoTest struct {
+	who   string
+	user  string
+	admin string
+	json  bool
+	want  string
+	err   error
+}
+
+var whoTests = []whoTest{
+	{"all", "a.user", "", false, "who.txt", nil},
+	{"owners", "owner.first", "write", false, "who.txt", nil},
+	{"owners", "a.user", "super", true, "who.json", nil},
+	{"owners", "a.user", "write", false, "",
+		errors.New("Only admins and owners of a group with write access to //depot/Jam/MAIN/... can use 'p4 access who'")},
+	{"admins", "owner.first", "write", false, "", errors.New("Only admins can use 'p4 access who'")},
+	{"nobody", "a.user", "", false, "", errors.New("P4ACCESS_WHO must be all, owners or admins, not 'nobody'")},
+}
+
+func TestWho(t *testing.T) {
+	path := "//depot/Jam/MAIN/..."
+	for _, tst := range whoTests {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"protects", "-M", "-u", tst.user, "//..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": tst.admin}}, nil).
+			On("Run", []string{"protects", "-a", path}).Return([]map[interface{}]interface{}{
+			{"perm": "write", "host": "*", "user": "P_jam_rw", "isgroup": "", "line": "3", "depotFile": "//depot/Jam/..."},
+			{"perm": "write", "host": "*", "user": "b.user", "line": "4", "depotFile": "//depot/Jam/MAIN/..."},
+		}, nil).
+			On("Run", []string{"protects", "-M", "-g", "P_jam_rw", path}).Return(
+			[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+			On("Run", []string{"protects", "-M", "-u", "b.user", path}).Return(
+			[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+			On("Run", []string{"group", "-o", "P_jam_rw"}).Return(
+			[]map[interface{}]interface{}{{"Owners0": "owner.first", "Users0": "a.user", "Users1": "b.user"}}, nil)
+		c := testConfig()
+		c.Who = tst.who
+		args := io.Args{User: tst.user, Command: "who", Params: []string{"write", path}, JSON: tst.json}
+		res, err := run(&Env{Config: c, P4: fp4, Args: args, Mode: io.Broker})
+		if tst.err != nil {
+			assert.EqualError(t, err, tst.err.Error())
+			// Refused callers never get as far as checking who has access
+			fp4.AssertNotCalled(t, "Run", []string{"protects", "-M", "-u", "b.user", path})
+			continue
+		}
+		assert.Nil(t, err)
+		check(t, tst.want, res)
+	}
+}
@@ -1,12 +1,65 @@
 package config
 
+import "time"
+
 // Config is for storing confiruables from the env
 // The env variable defaults to P4ACCESS_<VAR>, e.g. P4ACCESS_P4PORT
 type Config struct {
-	P4Port   string
-	P4User   string
-	P4Client string
-	Results  string `default:"results.go.tpl"`
-	Help     string `default:"help.txt"`
-	Log      string `default:"p4access.log"`
+	P4Port     string
+	P4User     string
+	P4Client   string
+	Results    string `default:"results.go.tpl"`
+	Help       string `default:"help.txt"`
+	Templates  string `default:"."`
+	Log        string `default:"p4access.log"`
+	Audit      string `default:"p4access-audit.log"`
+	Store      string `default:"p4access-requests.json"`
+	Who        string `default:"owners"`
+	NotifyCmd  string
+	NotifyFrom string `default:"p4access"`
+	// SMTPHost is host:port, it is used instead of NotifyCmd when set
+	SMTPHost      string
+	SMTPUser      string
+	SMTPPassword  string
+	NotifyRetries int    `default:"2"`
+	Spool         string `default:"p4access-spool"`
+	// Webhooks are posted json for each of the WebhookEvents
+	Webhooks      []string
+	WebhookSecret string
+	WebhookEvents []string `default:"query,request,approve,deny"`
+	// Members of OnCall can add themselves to BreakGlass for BreakGlassFor,
+	// the path's owners and Security are alerted
+	OnCall        string
+	BreakGlass    string
+	BreakGlassFor time.Duration `default:"4h"`
+	Security      []string
+	// Policy is a json file of rules that hide, annotate or block groups
+	Policy string
+	// Approvers is a json file of delegate approvers and approval counts per group
+	Approvers string
+	// Recertification campaigns give owners RecertifyFor to confirm their
+	// members, then unconfirmed members are removed or escalated and the
+	// report is sent to Auditors
+	RecertifyFor         time.Duration `default:"336h"`
+	RecertifyUnconfirmed string        `default:"remove"`
+	Auditors             []string
+	// StaleAfter is how long without using the server makes a member, or an
+	// owner, stale
+	StaleAfter time.Duration `default:"2160h"`
+	// OwnerCheck is hide or demote for owners who are stale, missing or
+	// aren't people, anything else lists owners as their groups name them
+	OwnerCheck string `default:"demote"`
+	// OwnerFallback is who to ask, in order, about groups with no usable
+	// owners: depot, stream, parents, contacts from the Contacts file and
+	// then Support
+	OwnerFallback []string `default:"depot,stream,parents,contacts,support"`
+	Contacts      string
+	Support       string
+	// Annotations is a json file of descriptions, teams, request URLs, chat
+	// channels and self-service flags for groups
+	Annotations string
+	// Groups filled by 'p4 ldapsync' can't be joined in Perforce, users are
+	// sent to LdapURL or LdapContact instead of the group's owners
+	LdapContact string
+	LdapURL     string
 }
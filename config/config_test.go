@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/stretchr/testify/assert"
@@ -14,7 +15,37 @@ func TestConfig(t *testing.T) {
 	os.Setenv("P4ACCESS_P4CLIENT", "client_ws")
 	os.Setenv("P4ACCESS_RESULTS", "/path/to/template.go.tpl")
 	os.Setenv("P4ACCESS_HELP", "/path/to/help.txt")
+	os.Setenv("P4ACCESS_TEMPLATES", "/path/to/templates")
 	os.Setenv("P4ACCESS_LOG", "/path/to/p4access.log")
+	os.Setenv("P4ACCESS_AUDIT", "/path/to/p4access-audit.log")
+	os.Setenv("P4ACCESS_STORE", "/path/to/requests.json")
+	os.Setenv("P4ACCESS_WHO", "admins")
+	os.Setenv("P4ACCESS_NOTIFYCMD", "/usr/sbin/sendmail -t")
+	os.Setenv("P4ACCESS_NOTIFYFROM", "p4access@example.com")
+	os.Setenv("P4ACCESS_SMTPHOST", "mail.example.com:587")
+	os.Setenv("P4ACCESS_SMTPUSER", "p4access")
+	os.Setenv("P4ACCESS_SMTPPASSWORD", "secret")
+	os.Setenv("P4ACCESS_NOTIFYRETRIES", "5")
+	os.Setenv("P4ACCESS_SPOOL", "/path/to/spool")
+	os.Setenv("P4ACCESS_WEBHOOKS", "https://chat.example.com/hook,https://tickets.example.com/hook")
+	os.Setenv("P4ACCESS_WEBHOOKSECRET", "hooksecret")
+	os.Setenv("P4ACCESS_ONCALL", "G_oncall")
+	os.Setenv("P4ACCESS_BREAKGLASS", "G_breakglass")
+	os.Setenv("P4ACCESS_BREAKGLASSFOR", "2h")
+	os.Setenv("P4ACCESS_SECURITY", "security@example.com,soc@example.com")
+	os.Setenv("P4ACCESS_POLICY", "/path/to/policy.json")
+	os.Setenv("P4ACCESS_APPROVERS", "/path/to/approvers.json")
+	os.Setenv("P4ACCESS_RECERTIFYFOR", "720h")
+	os.Setenv("P4ACCESS_RECERTIFYUNCONFIRMED", "escalate")
+	os.Setenv("P4ACCESS_AUDITORS", "audit@example.com")
+	os.Setenv("P4ACCESS_STALEAFTER", "720h")
+	os.Setenv("P4ACCESS_OWNERCHECK", "hide")
+	os.Setenv("P4ACCESS_OWNERFALLBACK", "parents,support")
+	os.Setenv("P4ACCESS_CONTACTS", "/path/to/contacts.json")
+	os.Setenv("P4ACCESS_SUPPORT", "perforce-support@example.com")
+	os.Setenv("P4ACCESS_ANNOTATIONS", "/path/to/annotations.json")
+	os.Setenv("P4ACCESS_LDAPCONTACT", "idm@example.com")
+	os.Setenv("P4ACCESS_LDAPURL", "https://idm.example.com/groups")
 
 	var c Config
 	err := envconfig.Process("p4access", &c)
@@ -25,5 +56,45 @@ func TestConfig(t *testing.T) {
 	assert.Equal("client_ws", c.P4Client)
 	assert.Equal("/path/to/template.go.tpl", c.Results)
 	assert.Equal("/path/to/help.txt", c.Help)
+	assert.Equal("/path/to/templates", c.Templates)
 	assert.Equal("/path/to/p4access.log", c.Log)
+	assert.Equal("/path/to/p4access-audit.log", c.Audit)
+	assert.Equal("/path/to/requests.json", c.Store)
+	assert.Equal("admins", c.Who)
+	assert.Equal("/usr/sbin/sendmail -t", c.NotifyCmd)
+	assert.Equal("p4access@example.com", c.NotifyFrom)
+	assert.Equal("mail.example.com:587", c.SMTPHost)
+	assert.Equal("p4access", c.SMTPUser)
+	assert.Equal("secret", c.SMTPPassword)
+	assert.Equal(5, c.NotifyRetries)
+	assert.Equal("/path/to/spool", c.Spool)
+	assert.Equal([]string{"https://chat.example.com/hook", "https://tickets.example.com/hook"}, c.Webhooks)
+	assert.Equal("hooksecret", c.WebhookSecret)
+	assert.Equal([]string{"query", "request", "approve", "deny"}, c.WebhookEvents)
+	assert.Equal("G_oncall", c.OnCall)
+	assert.Equal("G_breakglass", c.BreakGlass)
+	assert.Equal(2*time.Hour, c.BreakGlassFor)
+	assert.Equal([]string{"security@example.com", "soc@example.com"}, c.Security)
+	assert.Equal("/path/to/policy.json", c.Policy)
+	assert.Equal("/path/to/approvers.json", c.Approvers)
+	assert.Equal(720*time.Hour, c.RecertifyFor)
+	assert.Equal("escalate", c.RecertifyUnconfirmed)
+	assert.Equal([]string{"audit@example.com"}, c.Auditors)
+	assert.Equal(720*time.Hour, c.StaleAfter)
+	assert.Equal("hide", c.OwnerCheck)
+	assert.Equal([]string{"parents", "support"}, c.OwnerFallback)
+	assert.Equal("/path/to/contacts.json", c.Contacts)
+	assert.Equal("perforce-support@example.com", c.Support)
+	assert.Equal("/path/to/annotations.json", c.Annotations)
+	assert.Equal("idm@example.com", c.LdapContact)
+	assert.Equal("https://idm.example.com/groups", c.LdapURL)
+
+	// Unless told otherwise owners who can't approve are listed last and
+	// every fallback is tried
+	os.Unsetenv("P4ACCESS_OWNERCHECK")
+	os.Unsetenv("P4ACCESS_OWNERFALLBACK")
+	var d Config
+	assert.Nil(envconfig.Process("p4access", &d))
+	assert.Equal("demote", d.OwnerCheck)
+	assert.Equal([]string{"depot", "stream", "parents", "contacts", "support"}, d.OwnerFallback)
 }
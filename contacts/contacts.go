@@ -0,0 +1,77 @@
+package contacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// Rule names who to ask about paths whose groups have nobody else to ask
+type Rule struct {
+	Name  string   `json:"name"`  // Shown to the user, e.g. the Jam team
+	Paths []string `json:"paths"` // Depot path patterns, e.g. //depot/Jam/...
+	Email string   `json:"email"`
+	paths []*regexp.Regexp
+}
+
+// Contacts is an ordered list of rules, the first that matches a path is used
+type Contacts struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Load reads a contacts file, an empty path has no contacts
+func Load(file string) (*Contacts, error) {
+	c := &Contacts{}
+	if file == "" {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read contacts %s, %v", file, err)
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("Failed to read contacts %s, %v", file, err)
+	}
+	for i := range c.Rules {
+		if err := c.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("Failed to read contacts %s, rule %d '%s' %v", file, i+1, c.Rules[i].Name, err)
+		}
+	}
+	return c, nil
+}
+
+// compile checks the rule and prepares its path patterns
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("needs a name")
+	}
+	if r.Email == "" {
+		return fmt.Errorf("needs an email")
+	}
+	if len(r.Paths) == 0 {
+		return fmt.Errorf("needs at least one path pattern")
+	}
+	r.paths = nil
+	for _, p := range r.Paths {
+		r.paths = append(r.paths, prots.PathPattern(p))
+	}
+	return nil
+}
+
+// Find returns the contact for the first rule matching the path
+func (c *Contacts) Find(path string) (prots.Owner, bool) {
+	if c == nil {
+		return prots.Owner{}, false
+	}
+	for _, r := range c.Rules {
+		for _, re := range r.paths {
+			if re.MatchString(path) {
+				return prots.Owner{FullName: r.Name, Email: r.Email}, true
+			}
+		}
+	}
+	return prots.Owner{}, false
+}
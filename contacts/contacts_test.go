@@ -0,0 +1,62 @@
+package contacts
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+)
+
+const testContacts = `{"rules": [
+	{"name": "the Jam release team", "paths": ["//depot/Jam/REL*/..."], "email": "jam-rel@email.com"},
+	{"name": "the Jam team", "paths": ["//depot/Jam/...", "//jam/..."], "email": "jam@email.com"}
+]}`
+
+func load(t *testing.T, contacts string) (*Contacts, error) {
+	path := filepath.Join(t.TempDir(), "contacts.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(contacts), 0666))
+	return Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	c, err := load(t, testContacts)
+	assert.Nil(err)
+	assert.Len(c.Rules, 2)
+
+	c, err = Load("")
+	assert.Nil(err)
+	assert.Empty(c.Rules)
+
+	for rules, want := range map[string]string{
+		`{"paths": ["//depot/..."], "email": "a@email.com"}`: "rule 1 '' needs a name",
+		`{"name": "a", "paths": ["//depot/..."]}`:            "rule 1 'a' needs an email",
+		`{"name": "a", "email": "a@email.com"}`:              "rule 1 'a' needs at least one path pattern",
+	} {
+		_, err := load(t, `{"rules": [`+rules+`]}`)
+		assert.Error(err)
+		assert.Contains(err.Error(), want)
+	}
+	_, err = load(t, `{"rules": [`)
+	assert.Error(err)
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+	c, err := load(t, testContacts)
+	assert.Nil(err)
+	o, ok := c.Find("//depot/Jam/REL1/...")
+	assert.True(ok)
+	assert.Equal(prots.Owner{FullName: "the Jam release team", Email: "jam-rel@email.com"}, o)
+	o, ok = c.Find("//jam/main/src/file.c")
+	assert.True(ok)
+	assert.Equal("jam@email.com", o.Email)
+	_, ok = c.Find("//depot/Other/...")
+	assert.False(ok)
+
+	var none *Contacts
+	_, ok = none.Find("//depot/Jam/...")
+	assert.False(ok)
+}
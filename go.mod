@@ -1,6 +1,6 @@
 module github.com/brettbates/p4access
 
-go 1.15
+go 1.18
 
 require (
 	github.com/brettbates/go-libp4 v0.1.0
@@ -8,3 +8,10 @@ require (
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/stretchr/testify v1.7.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.1.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
@@ -0,0 +1,17 @@
+{{ if .Removal }}
+Removal {{ .ID }} has been approved, {{ .User }} has been taken out of {{ .Info.Group }}.
+{{ else if eq .State "pending" }}
+Your approval of request {{ .ID }} has been recorded, {{ .User }} will be added to {{ .Info.Group }} once {{ .Needed }} more of its approvers {{ if eq .Needed 1 }}has{{ else }}have{{ end }} approved.
+{{ if .ExpiresIn }}
+Access will last {{ expiry .ExpiresIn }} from the final approval, or less if another approver says so.
+{{ end }}
+The other approvers have been told.
+{{ else }}
+Request {{ .ID }} has been approved, {{ .User }} is now a member of {{ .Info.Group }} with {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+{{ if .Expires }}
+Access lasts until {{ .Expires.Format "Mon 2 Jan 2006 15:04 MST" }}, then {{ .User }} is taken out of the group again.
+{{ end }}
+{{ .User }} has been told.
+{{ end }}
\ No newline at end of file
@@ -0,0 +1,9 @@
+{{ if .Removal }}
+Removal {{ .ID }} has been denied, {{ .User }} stays in {{ .Info.Group }}.
+{{ else }}
+Request {{ .ID }} for {{ .User }} to join {{ .Info.Group }} has been denied.
+
+{{ .User }} has been told why:
+
+    {{ .Reason }}
+{{ end }}
\ No newline at end of file
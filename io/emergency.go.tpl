@@ -0,0 +1,7 @@
+
+You are now in {{ .Info.Group }} with {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+
+until {{ .Expires.Format "Mon 2 Jan 2006 15:04 MST" }}, when you will be taken out again.
+The owners of the path and security have been alerted, and this has been recorded as {{ .ID }}.
@@ -0,0 +1,4 @@
+{{ range .Expired }}Removed {{ .User }} from {{ .Info.Group }}, approved by {{ .Approver }} until {{ .Expires.Format "Mon 2 Jan 2006 15:04 MST" }} ({{ .ID }})
+{{ end }}{{ range .Failed }}Failed to remove {{ .User }} from {{ .Info.Group }} ({{ .ID }})
+{{ end }}{{ if not (or .Expired .Failed) }}Nothing has expired
+{{ end }}
\ No newline at end of file
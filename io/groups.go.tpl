@@ -0,0 +1,9 @@
+
+Groups for {{ .User }}{{ if .Path }} that apply to {{ .Path }}{{ end }}:
+{{ range .Groups }}
+    {{ .Group }}{{ if not .Direct }} (through a subgroup){{ end }}
+{{ range .Prots }}        {{ if .Unmap }}-{{ end }}{{ .Perm }} {{ .DepotFile }} from host {{ .Host }} (line {{ .Line }})
+{{ else }}        Not named in any protections{{ if $.Path }} for this path{{ end }}
+{{ end }}{{ else }}
+    {{ .User }} is not in any groups
+{{ end }}
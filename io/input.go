@@ -1,31 +1,226 @@
 package io
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/brettbates/p4access/prots"
 	p4b "github.com/brettbates/p4broker-reader/reader"
 )
 
-// Args are the arguments from 'p4 access reqAccess path'
+// Usage is shown alongside any argument errors
+const Usage = "Usage: p4 access [-h] [-v] [-j] [-a] [-n max] [-u user] [-m message] [-e expiry] <command> [args...], see 'p4 access help'"
+
+// Request is the context the p4broker passes to every filter program
+type Request struct {
+	Command          string `json:"command"`
+	User             string `json:"user"`
+	Workspace        string `json:"workspace"`
+	Cwd              string `json:"cwd"`
+	ClientIP         string `json:"clientIp"`
+	ClientHost       string `json:"clientHost"`
+	ProxyIP          string `json:"proxyIp,omitempty"`
+	ClientProg       string `json:"clientProg"`
+	ClientVersion    string `json:"clientVersion"`
+	ClientProtocol   string `json:"clientProtocol,omitempty"`
+	APILevel         string `json:"apiLevel,omitempty"`
+	BrokerListenPort string `json:"brokerListenPort"`
+	BrokerTargetPort string `json:"brokerTargetPort"`
+}
+
+// NewRequest picks the request context out of the p4broker fields
+func NewRequest(res map[string]string) Request {
+	return Request{
+		Command:          res["command"],
+		User:             res["user"],
+		Workspace:        res["workspace"],
+		Cwd:              res["cwd"],
+		ClientIP:         res["clientIp"],
+		ClientHost:       res["clientHost"],
+		ProxyIP:          res["proxyIp"],
+		ClientProg:       res["clientProg"],
+		ClientVersion:    res["clientVersion"],
+		ClientProtocol:   res["clientProtocol"],
+		APILevel:         res["apiLevel"],
+		BrokerListenPort: res["brokerListenPort"],
+		BrokerTargetPort: res["brokerTargetPort"],
+	}
+}
+
+// Client is the part of the request used when evaluating protections
+func (r Request) Client() prots.Client {
+	return prots.Client{
+		IP:        r.ClientIP,
+		ProxyIP:   r.ProxyIP,
+		Workspace: r.Workspace,
+		Cwd:       r.Cwd,
+	}
+}
+
+// Args are the arguments from 'p4 access [flags] command [params]'
+// ReqAccess and Path are filled in by the read and write commands
 type Args struct {
+	Request   Request
 	User      string
+	Command   string
+	Params    []string
 	ReqAccess string
 	Path      string
+	Help      bool          // -h show the help text
+	Verbose   bool          // -v show the protections lines behind each group
+	JSON      bool          // -j respond with json rather than the template
+	Max       int           // -n the most groups to return, 0 is unlimited
+	All       bool          // -a return groups from every tier, not just the most specific
+	As        string        // -u query on behalf of another user, admins only
+	Message   string        // -m a justification or reason
+	Expires   time.Duration // -e how long an approval lasts, 0 is forever
+}
+
+// Target is the user being queried for, the -u user if given
+func (a Args) Target() string {
+	if a.As != "" {
+		return a.As
+	}
+	return a.User
 }
 
 // Input gathers all the information p4broker has passed on
-// Arg0 is read/write
-// Arg1 is the path
-func Input() Args {
+// and parses the Arg0..ArgN fields as a command line
+func Input() (Args, error) {
 	res, err := p4b.Read(os.Stdin)
 	if err != nil {
-		log.Fatalf("Failed to read in stdin, %v", err)
+		return Args{}, fmt.Errorf("Failed to read in stdin, %v", err)
+	}
+	req := NewRequest(res)
+	argv, err := brokerArgs(res)
+	if err != nil {
+		return Args{Request: req}, err
 	}
-	a := Args{
-		res["user"],
-		res["Arg0"],
-		res["Arg1"],
+	a, err := Parse(res["user"], argv)
+	a.Request = req
+	return a, err
+}
+
+// brokerArgs rebuilds the command line from the argCount and ArgN fields
+func brokerArgs(res map[string]string) ([]string, error) {
+	v, ok := res["argCount"]
+	if !ok {
+		return nil, nil
 	}
-	return a
+	cnt, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse argCount '%s'", v)
+	}
+	argv := []string{}
+	for i := 0; i < cnt; i++ {
+		a, ok := res[fmt.Sprintf("Arg%d", i)]
+		if !ok {
+			return nil, fmt.Errorf("Missing Arg%d, expected %d arguments", i, cnt)
+		}
+		argv = append(argv, a)
+	}
+	return argv, nil
+}
+
+// Parse reads the flags and arguments of 'p4 access' for the given user
+// Flags may come before or after the command and its parameters
+func Parse(user string, argv []string) (Args, error) {
+	a := Args{User: user}
+	fs := flag.NewFlagSet("access", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&a.Help, "h", false, "")
+	fs.BoolVar(&a.Verbose, "v", false, "")
+	fs.BoolVar(&a.JSON, "j", false, "")
+	fs.BoolVar(&a.All, "a", false, "")
+	fs.IntVar(&a.Max, "n", 0, "")
+	fs.StringVar(&a.As, "u", "", "")
+	fs.StringVar(&a.Message, "m", "", "")
+	fs.Var((*expiry)(&a.Expires), "e", "")
+
+	pos := []string{}
+	for {
+		if err := fs.Parse(argv); err != nil {
+			return a, UsageError(err.Error(), Usage)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		argv = fs.Args()[1:]
+	}
+	if len(pos) > 0 {
+		a.Command = pos[0]
+		a.Params = pos[1:]
+	}
+
+	if a.Help {
+		return a, nil
+	}
+	if a.Max < 0 {
+		return a, UsageError(fmt.Sprintf("-n must be zero or more, got %d", a.Max), Usage)
+	}
+	if a.Command == "" {
+		return a, UsageError("Missing command", Usage)
+	}
+	return a, nil
+}
+
+// expiry reads -e as days, weeks or anything time.ParseDuration takes, e.g. 7d, 2w, 12h
+type expiry time.Duration
+
+func (e *expiry) String() string {
+	return time.Duration(*e).String()
+}
+
+func (e *expiry) Set(s string) error {
+	d, err := ParseExpiry(s)
+	*e = expiry(d)
+	return err
+}
+
+// ParseExpiry reads a length of time such as 7d, 2w or 12h
+func ParseExpiry(s string) (time.Duration, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	var d time.Duration
+	var err error
+	if unit > 0 {
+		var n int
+		n, err = strconv.Atoi(strings.TrimSuffix(s, s[len(s)-1:]))
+		d = time.Duration(n) * unit
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("expected a length of time such as 7d, 2w or 12h, got '%s'", s)
+	}
+	return d, nil
+}
+
+// FormatExpiry writes a length of time the way ParseExpiry reads it, e.g. 2w
+func FormatExpiry(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d%(7*day) == 0:
+		return fmt.Sprintf("%dw", d/(7*day))
+	case d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
+
+// UsageError is an error message followed by how to use the command
+func UsageError(msg, usage string) error {
+	return errors.New(msg + "\n" + usage)
 }
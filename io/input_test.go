@@ -0,0 +1,172 @@
+package io
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+)
+
+type parseTest struct {
+	argv []string
+	want Args
+	err  error
+}
+
+var parseTests = []parseTest{
+	{
+		[]string{"read", "//depot/..."},
+		Args{User: "usr", Command: "read", Params: []string{"//depot/..."}},
+		nil,
+	},
+	{ // Flags can come before or after the arguments
+		[]string{"-v", "write", "//depot/...", "-j", "-n", "3", "-a"},
+		Args{User: "usr", Command: "write", Params: []string{"//depot/..."}, Verbose: true, JSON: true, Max: 3, All: true},
+		nil,
+	},
+	{
+		[]string{"-u", "other.user", "read", "//depot/..."},
+		Args{User: "usr", Command: "read", Params: []string{"//depot/..."}, As: "other.user"},
+		nil,
+	},
+	{
+		[]string{"request", "write", "//depot/...", "-m", "Fixing \"bug\" 123"},
+		Args{User: "usr", Command: "request", Params: []string{"write", "//depot/..."}, Message: "Fixing \"bug\" 123"},
+		nil,
+	},
+	{
+		[]string{"approve", "R00000001", "-e", "2w"},
+		Args{User: "usr", Command: "approve", Params: []string{"R00000001"}, Expires: 14 * 24 * time.Hour},
+		nil,
+	},
+	{
+		[]string{"approve", "R00000001", "-e", "soon"},
+		Args{User: "usr"},
+		errors.New("invalid value \"soon\" for flag -e: expected a length of time such as 7d, 2w or 12h, got 'soon'\n" + Usage),
+	},
+	{ // Help doesn't need any other arguments
+		[]string{"-h"},
+		Args{User: "usr", Help: true},
+		nil,
+	},
+	{ // Help for a single command
+		[]string{"write", "-h"},
+		Args{User: "usr", Command: "write", Params: []string{}, Help: true},
+		nil,
+	},
+	{
+		[]string{},
+		Args{User: "usr"},
+		errors.New("Missing command\n" + Usage),
+	},
+	{
+		[]string{"-x", "read", "//depot/..."},
+		Args{User: "usr"},
+		errors.New("flag provided but not defined: -x\n" + Usage),
+	},
+	{
+		[]string{"-n", "-1", "read", "//depot/..."},
+		Args{User: "usr", Max: -1},
+		errors.New("-n must be zero or more, got -1\n" + Usage),
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, tst := range parseTests {
+		res, err := Parse("usr", tst.argv)
+		if tst.err == nil {
+			assert.Nil(t, err)
+			assert.Equal(t, tst.want, res)
+		} else {
+			assert.EqualError(t, err, tst.err.Error())
+		}
+	}
+}
+
+func TestBrokerArgs(t *testing.T) {
+	assert := assert.New(t)
+	res, err := brokerArgs(map[string]string{
+		"argCount": "3",
+		"Arg0":     "-v",
+		"Arg1":     "read",
+		"Arg2":     "//depot/...",
+		"user":     "usr",
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"-v", "read", "//depot/..."}, res)
+
+	_, err = brokerArgs(map[string]string{"argCount": "2", "Arg0": "read"})
+	assert.EqualError(err, "Missing Arg1, expected 2 arguments")
+
+	_, err = brokerArgs(map[string]string{"argCount": "two"})
+	assert.EqualError(err, "Failed to parse argCount 'two'")
+}
+
+func TestParseExpiry(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		d, err := ParseExpiry(in)
+		assert.Nil(t, err)
+		assert.Equal(t, want, d, in)
+	}
+	for _, in := range []string{"", "0d", "-1w", "xd", "d", "7"} {
+		_, err := ParseExpiry(in)
+		assert.EqualError(t, err, "expected a length of time such as 7d, 2w or 12h, got '"+in+"'")
+	}
+}
+
+func TestFormatExpiry(t *testing.T) {
+	for want, d := range map[string]time.Duration{
+		"1d":      24 * time.Hour,
+		"2w":      14 * 24 * time.Hour,
+		"12h0m0s": 12 * time.Hour,
+		"1h30m0s": 90 * time.Minute,
+	} {
+		assert.Equal(t, want, FormatExpiry(d))
+	}
+}
+
+func TestTarget(t *testing.T) {
+	assert.Equal(t, "usr", Args{User: "usr"}.Target())
+	assert.Equal(t, "other", Args{User: "usr", As: "other"}.Target())
+}
+
+func TestNewRequest(t *testing.T) {
+	req := NewRequest(map[string]string{
+		"command":          "access",
+		"brokerListenPort": "1667",
+		"brokerTargetPort": "perforce:1666",
+		"clientProg":       "p4",
+		"clientVersion":    "2020.2",
+		"apiLevel":         "90",
+		"workspace":        "a_ws",
+		"user":             "a.user",
+		"clientIp":         "10.0.0.1",
+		"proxyIp":          "",
+		"cwd":              "/home/a.user/ws",
+		"clientHost":       "a-host",
+		"argCount":         "2",
+		"Arg0":             "read",
+		"Arg1":             "//depot/...",
+	})
+	assert.Equal(t, Request{
+		Command:          "access",
+		User:             "a.user",
+		Workspace:        "a_ws",
+		Cwd:              "/home/a.user/ws",
+		ClientIP:         "10.0.0.1",
+		ClientHost:       "a-host",
+		ClientProg:       "p4",
+		ClientVersion:    "2020.2",
+		APILevel:         "90",
+		BrokerListenPort: "1667",
+		BrokerTargetPort: "perforce:1666",
+	}, req)
+	assert.Equal(t, prots.Client{IP: "10.0.0.1", Workspace: "a_ws", Cwd: "/home/a.user/ws"}, req.Client())
+}
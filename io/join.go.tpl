@@ -0,0 +1,2 @@
+
+{{ .User }} is now a member of {{ .Group }}, its owners have been told.
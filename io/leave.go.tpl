@@ -0,0 +1,2 @@
+
+{{ .User }} is no longer a member of {{ .Group }}, its owners have been told.
@@ -0,0 +1,15 @@
+{{ .User }} has asked to join {{ .Info.Group }}, which you can approve requests for, for {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+
+Their reason:
+
+    {{ .Justification }}
+
+It needs {{ .Required }} approvals, so far it has been approved by:
+{{ range .Approvals }}
+    {{ .User }}{{ if .Reason }}: {{ .Reason }}{{ end }}{{ end }}
+
+{{ .Needed }} more needed, approve it with 'p4 access approve {{ .ID }}' or deny it with 'p4 access deny {{ .ID }} -m reason'.
+
+Request ID: {{ .ID }}
@@ -0,0 +1,11 @@
+Your request {{ .ID }} has been approved by {{ .Approver }}, you are now a member of {{ .Info.Group }} with {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+{{ if .Expires }}
+Your access lasts until {{ .Expires.Format "Mon 2 Jan 2006 15:04 MST" }}, after that you will need to ask again.
+{{ end }}{{ if .Reason }}
+They said:
+
+    {{ .Reason }}
+{{ end }}
+Request ID: {{ .ID }}
@@ -0,0 +1,14 @@
+{{ if .Removal }}{{ .Approver }} has {{ .State }} the suggestion {{ .ID }} to take {{ .User }} out of {{ .Info.Group }}, which you own.
+{{ else }}{{ .Approver }} has {{ .State }} request {{ .ID }} from {{ .User }} to join {{ .Info.Group }}, which you own, for {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+{{ end }}{{ if .Expires }}
+Access lasts until {{ .Expires.Format "Mon 2 Jan 2006 15:04 MST" }}.
+{{ end }}{{ if .Reason }}
+They said:
+
+    {{ .Reason }}
+{{ end }}
+There is nothing more for you to do.
+
+Request ID: {{ .ID }}
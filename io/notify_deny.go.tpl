@@ -0,0 +1,9 @@
+Your request {{ .ID }} to join {{ .Info.Group }} for {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+
+has been denied by {{ .Approver }}, their reason:
+
+    {{ .Reason }}
+
+Request ID: {{ .ID }}
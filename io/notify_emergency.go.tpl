@@ -0,0 +1,14 @@
+EMERGENCY ACCESS
+
+{{ .User }} has used break-glass access, joining {{ .Info.Group }} for {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+
+Their reason:
+
+    {{ .Justification }}
+
+Access lasts until {{ .Expires.Format "Mon 2 Jan 2006 15:04 MST" }}, when they will be taken out of the group.
+If you don't recognise this incident, contact security straight away.
+
+Request ID: {{ .ID }}
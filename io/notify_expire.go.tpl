@@ -0,0 +1,8 @@
+{{ .User }}'s access to {{ .Info.Group }}, which gave {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+
+ran out on {{ .Expires.Format "Mon 2 Jan 2006 15:04 MST" }}, so {{ .User }} has been taken out of the group.
+It was approved by {{ .Approver }}, use 'p4 access request' to ask for access again.
+
+Request ID: {{ .ID }}
@@ -0,0 +1,4 @@
+{{ .User }} has joined {{ .Group }}, which you own.{{ if ne .User .By }} {{ .By }} added them.{{ end }}
+
+The group is self-service, so nobody had to approve it. If they shouldn't be
+in it, take them out and ask for the group to stop being self-service.
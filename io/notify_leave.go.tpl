@@ -0,0 +1 @@
+{{ .User }} has left {{ .Group }}, which you own.{{ if ne .User .By }} {{ .By }} took them out.{{ end }}
@@ -0,0 +1,16 @@
+Please confirm who should still be in the groups you own, for access review {{ .ID }}.
+
+{{ range .Reviews }}{{ .Group }}:
+{{ range .Members }}    {{ .User }}
+{{ end }}
+{{ end }}Keep the members who still need access with
+
+    p4 access review {{ .ID }} keep <group> [user ...]
+
+and take out those who don't with
+
+    p4 access review {{ .ID }} remove <group> user ...
+
+'p4 access review {{ .ID }}' shows where you are up to. Members nobody has kept by {{ .Deadline.Format "Mon 2 Jan 2006 15:04 MST" }} are removed or escalated.
+
+Review ID: {{ .ID }}
@@ -0,0 +1,8 @@
+Access review {{ .ID }} has closed and nobody confirmed these members should keep their access:
+{{ range .Reviews }}
+{{ .Group }}, reviewed by{{ range .Owners }} {{ .User }}{{ end }}
+{{ range .Members }}    {{ .User }}
+{{ end }}{{ end }}
+They are still in the group, please decide whether they need to be.
+
+Review ID: {{ .ID }}
@@ -0,0 +1,6 @@
+Access review {{ .ID }} started {{ .Created.Format "2006-01-02" }} and closed {{ .Closed.Format "2006-01-02" }}, the deadline was {{ .Deadline.Format "2006-01-02" }}.
+{{ range .Reviews }}
+{{ .Group }}, reviewed by{{ range .Owners }} {{ .User }}{{ end }}
+{{ range .Members }}    {{ .User }}: {{ .Outcome }}{{ if .By }} by {{ .By }}{{ if .Time }} on {{ .Time.Format "2006-01-02" }}{{ end }}{{ end }}
+{{ end }}{{ end }}
+Review ID: {{ .ID }}
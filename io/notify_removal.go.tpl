@@ -0,0 +1,11 @@
+{{ .User }} is in {{ .Info.Group }}, which you own, but looks stale:
+
+    {{ .Justification }}
+
+If they no longer need to be in it, take them out with
+
+    p4 access approve {{ .ID }}
+
+or keep them with 'p4 access deny {{ .ID }} -m reason'.
+
+Request ID: {{ .ID }}
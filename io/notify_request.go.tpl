@@ -0,0 +1,13 @@
+{{ .User }} has asked to join {{ .Info.Group }}, which you own, for {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+
+Their reason:
+
+    {{ .Justification }}
+
+The group gets access through protections line {{ .Info.Prot.Line }}: {{ .Info.Prot.Perm }} {{ .Info.Prot.DepotFile }}
+
+{{ if gt .Required 1 }}It needs {{ .Required }} approvals from the group's owners and delegated approvers before they are added.
+
+{{ end }}Request ID: {{ .ID }}
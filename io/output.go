@@ -2,66 +2,192 @@ package io
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	goio "io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/brettbates/p4access/config"
 	"github.com/brettbates/p4access/prots"
 )
 
+// Mode is how p4access has been run
+type Mode int
+
+const (
+	// Broker responds in the p4broker filter format, this is the default
+	Broker Mode = iota
+	// Direct prints plain text, for running p4access from a shell or cron
+	Direct
+)
+
+var mode = Broker
+
+// stdout is where responses are written, tests may swap it with SetOutput
+var stdout goio.Writer = os.Stdout
+
+// SetMode changes how responses and rejections are written
+func SetMode(m Mode) {
+	mode = m
+}
+
+// SetOutput changes where responses are written, it returns the previous writer
+func SetOutput(w goio.Writer) goio.Writer {
+	prev := stdout
+	stdout = w
+	return prev
+}
+
 // templateInfo is the struct fed to the result template
 // any information you need in the template must be contained here
 type templateInfo struct {
 	Groups  []prots.Info
 	Context string
+	Verbose bool
+	Request Request
+}
+
+// jsonInfo is the response given for 'p4 access -j'
+type jsonInfo struct {
+	User    string       `json:"user"`
+	Access  string       `json:"access"`
+	Path    string       `json:"path"`
+	Context string       `json:"context,omitempty"`
+	Groups  []prots.Info `json:"groups"`
+}
+
+// ShowResults places the groups found for a path into a p4broker friendly
+// format, they are looked up first so a policy can be applied to them
+func ShowResults(info []prots.Info, context string, args Args, c config.Config) string {
+	if args.Max > 0 && len(info) > args.Max {
+		info = info[:args.Max]
+	}
+	var ob bytes.Buffer
+	if args.JSON {
+		out, err := JSON(jsonInfo{args.Target(), args.ReqAccess, args.Path, context, info})
+		if err != nil {
+			log.Fatalf("Failed to encode json\n%v", err)
+		}
+		ob.WriteString(out)
+	} else {
+		tmp, err := ioutil.ReadFile(c.Results)
+		if err != nil {
+			log.Fatalf("Failed to find response template %s", c.Results)
+		}
+		t := template.Must(template.New("response").Parse(string(tmp)))
+		err = t.Execute(&ob, templateInfo{info, context, args.Verbose, args.Request})
+		if err != nil {
+			log.Fatalf("Failed to execute template\n%v", err)
+		}
+	}
+	return Respond(ob.String())
 }
 
-// Results places successful Advise output into a p4broker friendly format
-func Results(p4r prots.P4Runner, adv *prots.Advice, args Args, c config.Config) string {
-	tmp, err := ioutil.ReadFile(c.Results)
+// funcs can be used in any template from the templates directory
+var funcs = template.FuncMap{
+	// json quotes a value for templates that produce json, e.g. webhooks
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// expiry writes a length of access the way -e takes it, e.g. 2w
+	"expiry": FormatExpiry,
+}
+
+// Render executes the named template from the templates directory
+// e.g. "owners" renders owners.go.tpl
+func Render(c config.Config, name string, data interface{}) (string, error) {
+	path := filepath.Join(c.Templates, name+".go.tpl")
+	tmp, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Failed to find response template %s", c.Results)
+		log.Printf("Failed to find template %s, %v", path, err)
+		return "", fmt.Errorf("Failed to find the %s template, please contact support", name)
 	}
-	t := template.Must(template.New("response").Parse(string(tmp)))
-	info, err := adv.OutputInfo(p4r, args.Path, args.ReqAccess)
+	t, err := template.New(name).Funcs(funcs).Parse(string(tmp))
 	if err != nil {
-		Reject(err)
+		return "", fmt.Errorf("Failed to parse template %s, %v", path, err)
+	}
+	var ob bytes.Buffer
+	if err := t.Execute(&ob, data); err != nil {
+		return "", fmt.Errorf("Failed to execute template %s, %v", path, err)
 	}
-	out := templateInfo{info, adv.Context}
+	return ob.String(), nil
+}
+
+// JSON formats data for the -j flag
+func JSON(data interface{}) (string, error) {
 	var ob bytes.Buffer
-	err = t.Execute(&ob, out)
-	obs := ob.Bytes() // So we can write to Stdout and return the value
-	os.Stdout.Write(obs)
+	enc := json.NewEncoder(&ob)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return "", fmt.Errorf("Failed to encode json, %v", err)
+	}
+	return ob.String(), nil
+}
+
+// Output renders data with the named template, or as json when -j is given,
+// and sends it to the user
+func Output(c config.Config, args Args, name string, data interface{}) error {
+	var out string
+	var err error
+	if args.JSON {
+		out, err = JSON(data)
+	} else {
+		out, err = Render(c, name, data)
+	}
 	if err != nil {
-		log.Fatalf("Failed to execute template\n%v", err)
+		return err
 	}
-	return string(obs)
+	Respond(out)
+	return nil
+}
+
+// Respond sends a successful message to the user
+func Respond(msg string) string {
+	res := msg
+	if mode == Broker {
+		res = Encode(ActionRespond, msg)
+	} else if !strings.HasSuffix(res, "\n") {
+		res += "\n"
+	}
+	fmt.Fprint(stdout, res)
+	return res
 }
 
 // Reject will send a failure message to the user and record the error in a log file
 func Reject(err error) {
 	if err != nil {
-		out := "action: REJECT\n" +
-			"message: \"Failing, error received:\n" +
-			err.Error() +
-			"\""
-		fmt.Printf(out)
+		msg := "Failing, error received:\n" + err.Error()
+		if mode == Broker {
+			fmt.Fprint(stdout, Encode(ActionReject, msg))
+		} else {
+			fmt.Fprintln(os.Stderr, msg)
+		}
 		// Write to log too
 		log.Println("Failing, err recvd:")
-		log.Println(out)
-		os.Exit(0)
+		log.Println(msg)
+		if mode == Broker {
+			os.Exit(0)
+		}
+		os.Exit(1)
 	}
 }
 
 // Help will print the help message from the help.txt file
 func Help(c config.Config) string {
+	return Respond(HelpText(c))
+}
+
+// HelpText reads the help message from the help.txt file
+func HelpText(c config.Config) string {
 	out, err := ioutil.ReadFile(c.Help)
 	if err != nil {
 		log.Fatalf("Failed to find help file %s", c.Help)
 	}
-	fmt.Print(string(out))
 	return string(out)
 }
@@ -0,0 +1,31 @@
+
+Group {{ .Group }}{{ if .Description }}
+
+    {{ .Description }}{{ end }}{{ with .Annotation }}{{ if .Team }}
+    Team: {{ .Team }}{{ end }}{{ if .Chat }}
+    Chat: {{ .Chat }}{{ end }}{{ if .RequestURL }}
+    Request access at {{ .RequestURL }}{{ end }}{{ if .SelfService }}
+    Anyone can join this group with 'p4 access join {{ $.Group }}'{{ end }}{{ end }}
+
+{{ if .Ldap }}The members of this group come from {{ .Ldap.Source }},
+so it can't be joined in Perforce. {{ if .Ldap.URL }}Ask for access at {{ .Ldap.URL }}{{ else if .Ldap.Contact }}Ask {{ .Ldap.Contact }} to add you.{{ else }}Ask your LDAP administrators to add you.{{ end }}
+{{ else if .Contacts }}Nobody who owns this group can give access, ask {{ .Fallback }} instead:
+{{ range .Contacts }}
+    {{ .FullName }}: {{ .Email }}{{ end }}
+{{ if .Owners }}
+The group's owners are:
+{{ range .Owners }}
+    {{ .FullName }} ({{ .User }}): {{ .Email }}, {{ .Status }}{{ end }}
+{{ end }}{{ else if .Unowned }}None of the owners can give access at the moment, they have left, stopped
+using the server or aren't people. Please contact support to get access.
+{{ if .Owners }}{{ range .Owners }}
+    {{ .FullName }} ({{ .User }}): {{ .Email }}, {{ .Status }}{{ end }}
+{{ end }}{{ else if .Owners }}Owners, contact one of these to get access:
+{{ range .Owners }}
+    {{ .FullName }} ({{ .User }}): {{ .Email }}{{ if not .Usable }}, {{ .Status }}{{ end }}{{ end }}
+{{ else }}This group has no owners, please contact support to get access.
+{{ end }}
+{{ if .Membership.Direct }}{{ .User }} is already a member of {{ .Group }}.
+{{ end }}{{ range .Membership.Via }}{{ $.User }} is already a member of {{ $.Group }} through subgroup {{ . }}.
+{{ end }}{{ if not .Membership.Member }}{{ .User }} is not a member of {{ .Group }}.
+{{ end }}
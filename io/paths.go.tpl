@@ -0,0 +1,9 @@
+
+Group {{ .Group }} grants:
+{{ range .Paths }}
+    {{ .Prot.Perm }} {{ .Prot.DepotFile }} from host {{ .Prot.Host }} (line {{ .Prot.Line }}{{ if .Via }}, through parent group {{ .Via }}{{ end }})
+{{ range .Excluded }}        except {{ .DepotFile }} (line {{ .Line }}, {{ if .IsGroup }}group {{ end }}{{ .User }})
+{{ end }}        Highest permission left: {{ .Perm }}
+{{ else }}
+    Nothing, {{ .Group }} isn't named in the protections table
+{{ end }}
@@ -0,0 +1,10 @@
+
+Requests waiting for {{ .User }}{{ if .Group }} to join {{ .Group }}{{ end }}:
+{{ range .Requests }}
+    {{ .ID }}: {{ if .Removal }}take {{ .User }} out of {{ .Info.Group }}, suggested {{ .Created.Format "2006-01-02" }}{{ else }}{{ .User }} wants to join {{ .Info.Group }} for {{ .Info.Access }} access to {{ .Info.Path }}, asked {{ .Created.Format "2006-01-02" }}{{ if gt .Required 1 }}, approved by {{ len .Approvals }} of the {{ .Required }} needed{{ end }}{{ end }}
+        {{ .Justification }}
+{{ else }}
+    Nothing is waiting for {{ .User }}
+{{ end }}{{ if .Requests }}
+Decide with 'p4 access approve <id>' or 'p4 access deny <id> -m reason'
+{{ end }}
\ No newline at end of file
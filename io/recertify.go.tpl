@@ -0,0 +1,3 @@
+Started access review {{ .ID }} of {{ len .Reviews }} group(s), owners have until {{ .Deadline.Format "Mon 2 Jan 2006 15:04 MST" }}
+{{ range .Reviews }}    {{ .Group }}: {{ len .Members }} member(s), reviewed by{{ range .Owners }} {{ .User }}{{ end }}
+{{ end }}Close it with './p4access recertify close'
@@ -0,0 +1,7 @@
+{{ range . }}Access review {{ .ID }}, started {{ .Created.Format "2006-01-02" }}, closed {{ .Closed.Format "2006-01-02" }}
+{{ range .Reviews }}
+    {{ .Group }}, reviewed by{{ range .Owners }} {{ .User }}{{ end }}
+{{ range .Members }}        {{ .User }}: {{ .Outcome }}{{ if .By }} by {{ .By }}{{ end }}
+{{ end }}{{ end }}
+{{ else }}No access reviews are due
+{{ end }}
\ No newline at end of file
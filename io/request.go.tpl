@@ -0,0 +1,16 @@
+
+Request {{ .ID }} has been made for {{ .User }} to join {{ .Info.Group }}, which grants {{ .Info.Access }} access to:
+
+    {{ .Info.Path }}
+
+The owners of {{ .Info.Group }} have been told:
+{{ range .Info.Owners }}
+    {{ .FullName }}: {{ .Email }}{{ end }}
+{{ if .Delegates }}
+As have its delegated approvers:
+{{ range .Delegates }}
+    {{ .FullName }}: {{ .Email }}{{ end }}
+{{ end }}{{ if gt .Required 1 }}
+{{ .Required }} of them need to approve it.
+{{ end }}
+Please quote {{ .ID }} if you need to chase them up.
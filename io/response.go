@@ -0,0 +1,46 @@
+package io
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Actions a p4broker filter program can respond with
+const (
+	ActionRespond = "RESPOND"
+	ActionReject  = "REJECT"
+)
+
+// Encode builds a p4broker filter response, the message is always quoted
+// and escaped so that user supplied text can't break the response
+func Encode(action, message string) string {
+	return "action: " + action + "\n" +
+		"message: \"" + escape(message) + "\""
+}
+
+// escape makes s safe to place between the double quotes of a message field
+// Backslashes and quotes are escaped, newlines and tabs are kept as they are
+// and any other control characters or invalid utf8 are replaced
+func escape(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			b.WriteRune(utf8.RuneError)
+		case r == '\\' || r == '"':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\t':
+			b.WriteRune(r)
+		case unicode.IsControl(r):
+			b.WriteRune(utf8.RuneError)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package io
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decode reads a response back the way the p4broker would
+func decode(resp string) (string, string, error) {
+	lines := strings.SplitN(resp, "\n", 2)
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "action: ") {
+		return "", "", errors.New("missing action")
+	}
+	action := strings.TrimPrefix(lines[0], "action: ")
+	if !strings.HasPrefix(lines[1], "message: \"") {
+		return "", "", errors.New("missing message")
+	}
+	quoted := strings.TrimPrefix(lines[1], "message: \"")
+	var b strings.Builder
+	for i := 0; i < len(quoted); i++ {
+		switch c := quoted[i]; c {
+		case '\\':
+			i++
+			if i == len(quoted) {
+				return "", "", errors.New("dangling escape")
+			}
+			b.WriteByte(quoted[i])
+		case '"':
+			if i != len(quoted)-1 {
+				return "", "", errors.New("message ends early")
+			}
+			return action, b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated message")
+}
+
+type encodeTest struct {
+	action  string
+	message string
+	want    string
+}
+
+var encodeTests = []encodeTest{
+	{
+		ActionRespond,
+		"plain",
+		"action: RESPOND\nmessage: \"plain\"",
+	},
+	{
+		ActionReject,
+		"No such area '//depot/\"quoted\"/...'",
+		"action: REJECT\nmessage: \"No such area '//depot/\\\"quoted\\\"/...'\"",
+	},
+	{
+		ActionRespond,
+		"C:\\path\\",
+		"action: RESPOND\nmessage: \"C:\\\\path\\\\\"",
+	},
+	{
+		ActionRespond,
+		"multi\n\tline",
+		"action: RESPOND\nmessage: \"multi\n\tline\"",
+	},
+	{
+		ActionRespond,
+		"bell\a esc\x1b[0m bad\xff",
+		"action: RESPOND\nmessage: \"bell\uFFFD esc\uFFFD[0m bad\uFFFD\"",
+	},
+}
+
+func TestEncode(t *testing.T) {
+	for _, tst := range encodeTests {
+		assert.Equal(t, tst.want, Encode(tst.action, tst.message))
+	}
+}
+
+func FuzzEncode(f *testing.F) {
+	for _, tst := range encodeTests {
+		f.Add(tst.message)
+	}
+	f.Add("\"")
+	f.Add("\\\"")
+	f.Add("message: \"\naction: REJECT")
+	f.Fuzz(func(t *testing.T, msg string) {
+		resp := Encode(ActionRespond, msg)
+		action, got, err := decode(resp)
+		if err != nil {
+			t.Fatalf("Failed to decode %q, %v", resp, err)
+		}
+		if action != ActionRespond {
+			t.Fatalf("Expected action %s, got %s", ActionRespond, action)
+		}
+		if !utf8.ValidString(got) {
+			t.Fatalf("Decoded message %q is not valid utf8", got)
+		}
+		// Only control characters and invalid utf8 should be changed
+		want := strings.Map(func(r rune) rune {
+			if r != '\n' && r != '\t' && unicode.IsControl(r) {
+				return utf8.RuneError
+			}
+			return r
+		}, msg)
+		if got != want {
+			t.Fatalf("Expected %q, got %q", want, got)
+		}
+	})
+}
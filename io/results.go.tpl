@@ -1,5 +1,4 @@
-action: RESPOND
-message:  "
+
 Possible ways to get access are listed below. This is a beta, please report issues to support.
 
 *The more specific your path is, the more useful your results will be.*
@@ -12,11 +11,36 @@ Groups:
     Group {{ $group.Group }} grants {{ $group.Access }} access to the path: 
 
         {{ $group.Path }}
+{{ with $group.Annotation }}
+{{ if .Description }}    {{ .Description }}
+{{ end }}{{ if .Team }}    Team: {{ .Team }}
+{{ end }}{{ if .Chat }}    Chat: {{ .Chat }}
+{{ end }}{{ if .RequestURL }}    Request access at {{ .RequestURL }}
+{{ end }}{{ if .SelfService }}    Anyone can join this group with 'p4 access join {{ $group.Group }}'
+{{ end }}{{ end }}{{ if $group.Note }}
+    Note: {{ $group.Note }}
+{{ end }}{{ if $.Verbose }}
+    From protections line {{ $group.Prot.Line }}: {{ $group.Prot.Perm }} group {{ $group.Prot.User }} {{ $group.Prot.Host }} {{ $group.Prot.DepotFile }}
+{{ end }}{{ if $group.Ldap }}
+    The members of this group come from {{ $group.Ldap.Source }},
+    so it can't be joined in Perforce. {{ if $group.Ldap.URL }}Ask for access at {{ $group.Ldap.URL }}{{ else if $group.Ldap.Contact }}Ask {{ $group.Ldap.Contact }} to add you.{{ else }}Ask your LDAP administrators to add you.{{ end }}
+{{ else if $group.Contacts }}
+    Nobody who owns this group can give access, ask {{ $group.Fallback }} instead: 
+    {{ range $group.Contacts }} 
+        {{ .FullName }}: {{ .Email }} {{ end }}{{ if $group.Owners }}
 
+    The group's owners are: 
+    {{ range $group.Owners }} 
+        {{ .FullName }}: {{ .Email }} ({{ .Status }}) {{ end }}{{ end }}
+{{ else if $group.Unowned }}
+    None of this group's owners can give access at the moment, they have left,
+    stopped using the server or aren't people. Please contact support.
+    {{ range $group.Owners }} 
+        {{ .FullName }}: {{ .Email }} ({{ .Status }}) {{ end }}
+{{ else }}
     You can get access by contacting one of the owners listed: 
     {{ range $group.Owners }} 
-        {{ .FullName }}: {{ .Email }} {{ end }}
-    ----
+        {{ .FullName }}: {{ .Email }} {{ if not .Usable }}({{ .Status }}) {{ end }}{{ end }}
+{{ end }}    ----
 
 {{ end }}
-"
\ No newline at end of file
@@ -0,0 +1,12 @@
+
+Access review {{ .ID }}{{ if .Closed }}, closed on {{ .Closed.Format "Mon 2 Jan 2006" }}{{ else }}, please finish by {{ .Deadline.Format "Mon 2 Jan 2006 15:04 MST" }}{{ end }}
+{{ range .Reviews }}
+    {{ .Group }}:
+{{ range .Members }}        {{ .User }}: {{ if .Outcome }}{{ .Outcome }}{{ else if eq .Decision "keep" }}kept{{ else if .Decision }}removed{{ else }}not decided{{ end }}{{ if .By }} by {{ .By }}{{ end }}
+{{ end }}{{ else }}
+    {{ .User }} has nothing to review
+{{ end }}{{ if and .Reviews (not .Closed) }}
+Keep members with 'p4 access review {{ .ID }} keep <group> [user ...]'
+or take them out with 'p4 access review {{ .ID }} remove <group> user ...'
+Members nobody has kept by the deadline are removed or escalated.
+{{ end }}
\ No newline at end of file
@@ -0,0 +1,5 @@
+{{ range .Groups }}{{ .Group }}:
+{{ if .Ldap }}    The members come from {{ .Ldap.Source }}, remove them {{ if .Ldap.URL }}at {{ .Ldap.URL }}{{ else if .Ldap.Contact }}through {{ .Ldap.Contact }}{{ else }}through your LDAP administrators{{ end }}
+{{ end }}{{ range .Members }}    {{ .User }} {{ .Reason }}{{ if .Request }}, removal {{ .Request }}{{ end }}
+{{ end }}{{ else }}Nobody has been stale since {{ .Since.Format "2006-01-02" }}
+{{ end }}
\ No newline at end of file
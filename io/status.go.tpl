@@ -0,0 +1,8 @@
+
+Requests made by {{ .User }}:
+{{ range .Requests }}
+    {{ .ID }} {{ .State }}{{ if and .Needed (gt .Required 1) }} ({{ len .Approvals }} of {{ .Required }} approvals){{ end }}{{ if .Approver }} by {{ .Approver }}{{ end }}{{ if .Expires }} until {{ .Expires.Format "2006-01-02 15:04 MST" }}{{ end }}: {{ .Info.Group }} for {{ .Info.Access }} access to {{ .Info.Path }}, asked {{ .Created.Format "2006-01-02" }}{{ if .Reason }}
+        {{ .Reason }}{{ end }}
+{{ else }}
+    {{ .User }} hasn't made any requests
+{{ end }}
\ No newline at end of file
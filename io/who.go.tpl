@@ -0,0 +1,8 @@
+
+Groups and users with {{ .Access }} access to {{ .Path }}:
+{{ range .Grants }}
+    {{ if .IsGroup }}Group {{ .Name }} ({{ .Members }} users){{ else }}User {{ .Name }}{{ end }} has {{ .Perm }}
+{{ range .Prots }}        {{ if .Unmap }}-{{ end }}{{ .Perm }} {{ .DepotFile }} from host {{ .Host }} (line {{ .Line }})
+{{ end }}{{ else }}
+    Nobody has {{ .Access }} access to {{ .Path }}
+{{ end }}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/brettbates/p4access/config"
+)
+
+// Event is something people need to hear about, e.g. a new request
+type Event struct {
+	Type    string   `json:"type"` // request, approved, denied...
+	To      []string `json:"to"`   // Email addresses
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+}
+
+// Notifier sends events to the people who need them
+type Notifier interface {
+	Notify(ev Event) error
+}
+
+// New picks the notifier set up in the config, SMTP then NotifyCmd
+// Without one, events are only written to the log
+// The broker only tries SMTP once, anything that fails is spooled for
+// 'p4access flush' to retry rather than keeping the user waiting
+func New(c config.Config, broker bool) Notifier {
+	var n Notifier
+	switch {
+	case c.SMTPHost != "":
+		retries := c.NotifyRetries
+		if broker {
+			retries = 0
+		}
+		n = NewSMTP(c.SMTPHost, c.SMTPUser, c.SMTPPassword, c.NotifyFrom, retries)
+	case c.NotifyCmd != "":
+		n = &Command{c.NotifyCmd, c.NotifyFrom, 10 * time.Second}
+	default:
+		return Log{}
+	}
+	if c.Spool != "" {
+		return &Spool{n, c.Spool}
+	}
+	return n
+}
+
+// Command pipes each event as an email to a command such as 'sendmail -t'
+type Command struct {
+	Cmd     string
+	From    string
+	Timeout time.Duration // The command is killed if it takes longer
+}
+
+// Notify runs the command with the email on stdin
+func (n *Command) Notify(ev Event) error {
+	if len(ev.To) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Cmd)
+	cmd.Stdin = strings.NewReader(Message(n.From, ev))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Failed to run '%s', %v", n.Cmd, err)
+	}
+	// Anything the shell started can hold its output open after it is
+	// killed, so don't wait for that once the time is up
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("Failed to run '%s', %v: %s", n.Cmd, err, stderr.String())
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Failed to run '%s', it took longer than %v", n.Cmd, n.Timeout)
+	}
+}
+
+// Log writes events to the log file
+type Log struct{}
+
+// Notify logs the event
+func (Log) Notify(ev Event) error {
+	log.Printf("Notify %s %v: %s", ev.Type, ev.To, ev.Subject)
+	return nil
+}
+
+// Message formats the event as an email
+// Line breaks are taken out of the headers so a subject can't add headers
+// of its own
+func Message(from string, ev Event) string {
+	return "From: " + header(from) + "\r\n" +
+		"To: " + header(strings.Join(ev.To, ", ")) + "\r\n" +
+		"Subject: " + header(ev.Subject) + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		strings.Replace(ev.Body, "\n", "\r\n", -1)
+}
+
+// header makes a header value safe, any line breaks become spaces
+func header(v string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(v)
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = Event{
+	Type:    "request",
+	To:      []string{"owner.first@email.com", "owner.second@email.com"},
+	Subject: "Access request R1",
+	Body:    "line one\nline two\n",
+}
+
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "From: p4access@email.com\r\n"+
+		"To: owner.first@email.com, owner.second@email.com\r\n"+
+		"Subject: Access request R1\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n"+
+		"\r\n"+
+		"line one\r\nline two\r\n", Message("p4access@email.com", testEvent))
+
+	// Line breaks can't add headers
+	ev := Event{To: []string{"a@email.com\r\nBcc: b@email.com"}, Subject: "Hi\nBcc: c@email.com", Body: "hi"}
+	assert.Equal(t, "From: p4access\r\n"+
+		"To: a@email.com Bcc: b@email.com\r\n"+
+		"Subject: Hi Bcc: c@email.com\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n"+
+		"\r\n"+
+		"hi", Message("p4access", ev))
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "mail")
+	n := New(config.Config{NotifyCmd: "cat > " + out, NotifyFrom: "p4access@email.com"}, false)
+	assert.Nil(t, n.Notify(testEvent))
+	b, err := ioutil.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, Message("p4access@email.com", testEvent), string(b))
+
+	n = New(config.Config{NotifyCmd: "echo broken >&2; exit 3"}, false)
+	assert.EqualError(t, n.Notify(testEvent), "Failed to run 'echo broken >&2; exit 3', exit status 3: broken\n")
+
+	// A hung command doesn't hold up the broker
+	n = &Command{Cmd: "sleep 10", Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	assert.EqualError(t, n.Notify(testEvent), "Failed to run 'sleep 10', it took longer than 100ms")
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestNewLog(t *testing.T) {
+	assert.Equal(t, Log{}, New(config.Config{}, false))
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTP sends each event as an email through an SMTP server
+type SMTP struct {
+	Addr    string    // host:port
+	Auth    smtp.Auth // nil for servers that don't need a login
+	From    string
+	Retries int           // How many more times to try after the first failure
+	Wait    time.Duration // Between tries, doubled each time
+	Timeout time.Duration // For each try, from connecting to the last reply
+}
+
+// NewSMTP logs in with PLAIN auth if a user is given
+// Go only sends the password over TLS, or to localhost
+func NewSMTP(addr, user, password, from string, retries int) *SMTP {
+	n := &SMTP{Addr: addr, From: from, Retries: retries, Wait: time.Second, Timeout: 10 * time.Second}
+	if user != "" {
+		n.Auth = smtp.PlainAuth("", user, password, n.host())
+	}
+	return n
+}
+
+func (n *SMTP) host() string {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return n.Addr
+	}
+	return host
+}
+
+// Notify sends the email, trying again if the server can't be reached
+func (n *SMTP) Notify(ev Event) error {
+	if len(ev.To) == 0 {
+		return nil
+	}
+	msg := []byte(Message(n.From, ev))
+	wait := n.Wait
+	var err error
+	for try := 0; try <= n.Retries; try++ {
+		if try > 0 {
+			log.Printf("Failed to send %s email to %v, trying again in %v, %v", ev.Type, ev.To, wait, err)
+			time.Sleep(wait)
+			wait *= 2
+		}
+		if err = n.send(ev.To, msg); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("Failed to send email through %s, %v", n.Addr, err)
+}
+
+// send is smtp.SendMail with a deadline, so a server that accepts the
+// connection and then hangs can't hold up the broker
+func (n *SMTP) send(to []string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", n.Addr, n.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if n.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(n.Timeout)); err != nil {
+			return err
+		}
+	}
+	c, err := smtp.NewClient(conn, n.host())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host()}); err != nil {
+			return err
+		}
+	}
+	if n.Auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("%s doesn't support AUTH", n.Addr)
+		}
+		if err := c.Auth(n.Auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
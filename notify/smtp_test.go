@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP is just enough of an SMTP server to accept mail
+// The first fail connections are dropped straight away
+type fakeSMTP struct {
+	ln   net.Listener
+	fail int
+	mu   sync.Mutex
+	auth []string
+	mail []string
+}
+
+func newFakeSMTP(t *testing.T, fail int) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen, %v", err)
+	}
+	s := &fakeSMTP{ln: ln, fail: fail}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			drop := s.fail > 0
+			s.fail--
+			s.mu.Unlock()
+			if drop {
+				c.Close()
+				continue
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeSMTP) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	reply := func(l string) { c.Write([]byte(l + "\r\n")) }
+	reply("220 localhost fake")
+	var data strings.Builder
+	inData := false
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		l = strings.TrimRight(l, "\r\n")
+		if inData {
+			if l == "." {
+				inData = false
+				s.mu.Lock()
+				s.mail = append(s.mail, data.String())
+				s.mu.Unlock()
+				reply("250 queued")
+				continue
+			}
+			data.WriteString(l + "\r\n")
+			continue
+		}
+		cmd := strings.ToUpper(strings.SplitN(l, " ", 2)[0])
+		switch cmd {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = append(s.auth, l)
+			s.mu.Unlock()
+			reply("235 ok")
+		case "DATA":
+			inData = true
+			data.Reset()
+			reply("354 go ahead")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	assert := assert.New(t)
+	s := newFakeSMTP(t, 0)
+	n := NewSMTP(s.Addr(), "p4access", "secret", "p4access@email.com", 0)
+	assert.Nil(n.Notify(testEvent))
+	assert.Equal([]string{Message("p4access@email.com", testEvent)}, s.mail)
+	// base64 of "\x00p4access\x00secret"
+	assert.Equal([]string{"AUTH PLAIN AHA0YWNjZXNzAHNlY3JldA=="}, s.auth)
+
+	// Nothing to send
+	assert.Nil(n.Notify(Event{Type: "request"}))
+	assert.Len(s.mail, 1)
+}
+
+func TestSMTPRetry(t *testing.T) {
+	s := newFakeSMTP(t, 2)
+	n := NewSMTP(s.Addr(), "", "", "p4access@email.com", 2)
+	n.Wait = 0
+	assert.Nil(t, n.Notify(testEvent))
+	assert.Len(t, s.mail, 1)
+	assert.Empty(t, s.auth)
+
+	s = newFakeSMTP(t, 3)
+	n = NewSMTP(s.Addr(), "", "", "p4access@email.com", 1)
+	n.Wait = 0
+	assert.Error(t, n.Notify(testEvent))
+	assert.Empty(t, s.mail)
+}
+
+func TestSMTPTimeout(t *testing.T) {
+	// A server that accepts the connection and never says anything
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen, %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	n := NewSMTP(ln.Addr().String(), "", "", "p4access@email.com", 0)
+	n.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	assert.Error(t, n.Notify(testEvent))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestNewSMTP(t *testing.T) {
+	c := config.Config{SMTPHost: "mail:25", NotifyCmd: "sendmail -t", NotifyFrom: "p4access", NotifyRetries: 2, Spool: "spool"}
+	assert.Equal(t, &Spool{&SMTP{Addr: "mail:25", From: "p4access", Retries: 2, Wait: time.Second, Timeout: 10 * time.Second}, "spool"}, New(c, false))
+	// The broker tries once and leaves the rest to flush
+	assert.Equal(t, &Spool{&SMTP{Addr: "mail:25", From: "p4access", Retries: 0, Wait: time.Second, Timeout: 10 * time.Second}, "spool"}, New(c, true))
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Spool queues the events its Notifier fails to send, so an outage
+// doesn't hold up the broker or lose the email
+// 'p4access flush' sends them once the outage is over
+type Spool struct {
+	Notifier
+	Dir string
+}
+
+// Notify passes the event on, queueing it if that fails
+func (s *Spool) Notify(ev Event) error {
+	err := s.Notifier.Notify(ev)
+	if err == nil {
+		return nil
+	}
+	path, qerr := s.queue(ev)
+	if qerr != nil {
+		return fmt.Errorf("%v, and failed to queue it, %v", err, qerr)
+	}
+	log.Printf("Queued %s notification for %v as %s, %v", ev.Type, ev.To, path, err)
+	return nil
+}
+
+// queue writes the event to its own file, named so they sort oldest first
+func (s *Spool) queue(ev Event) (string, error) {
+	return enqueue(s.Dir, ev)
+}
+
+// Queued lists the files of the events waiting to be sent, oldest first
+func (s *Spool) Queued() ([]string, error) {
+	return queued(s.Dir)
+}
+
+// Flush tries to send every queued event, those that still fail stay queued
+func (s *Spool) Flush() (sent int, left int, err error) {
+	queued, err := s.Queued()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, path := range queued {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return sent, len(queued) - sent, err
+		}
+		var ev Event
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return sent, len(queued) - sent, fmt.Errorf("Failed to read queued event %s, %v", path, err)
+		}
+		if err := s.Notifier.Notify(ev); err != nil {
+			log.Printf("Failed to send queued %s notification %s, %v", ev.Type, path, err)
+			left++
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return sent, len(queued) - sent, err
+		}
+		sent++
+	}
+	return sent, left, nil
+}
+
+// enqueue writes v as json to its own file in dir, named so they sort oldest first
+func enqueue(dir string, v interface{}) (string, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(b) + ".json"
+	path := filepath.Join(dir, name)
+	// Written under another name first so a flush never reads half of it
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp, path)
+}
+
+// queued lists the files queued in dir, oldest first
+func queued(dir string) ([]string, error) {
+	fs, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, f := range fs {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			out = append(out, filepath.Join(dir, f.Name()))
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
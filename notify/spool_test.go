@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flaky fails to send while down is set
+type flaky struct {
+	down bool
+	sent []Event
+}
+
+func (f *flaky) Notify(ev Event) error {
+	if f.down {
+		return errors.New("connection refused")
+	}
+	f.sent = append(f.sent, ev)
+	return nil
+}
+
+func TestSpool(t *testing.T) {
+	assert := assert.New(t)
+	f := &flaky{down: true}
+	s := &Spool{f, filepath.Join(t.TempDir(), "spool")}
+
+	// Nothing has been queued yet, not even the directory
+	q, err := s.Queued()
+	assert.Nil(err)
+	assert.Empty(q)
+
+	second := testEvent
+	second.Subject = "Access request R2"
+	assert.Nil(s.Notify(testEvent))
+	assert.Nil(s.Notify(second))
+	q, err = s.Queued()
+	assert.Nil(err)
+	assert.Len(q, 2)
+
+	// Still down, so they stay queued
+	sent, left, err := s.Flush()
+	assert.Nil(err)
+	assert.Equal(0, sent)
+	assert.Equal(2, left)
+
+	f.down = false
+	sent, left, err = s.Flush()
+	assert.Nil(err)
+	assert.Equal(2, sent)
+	assert.Equal(0, left)
+	// In the order they were queued
+	assert.Equal([]Event{testEvent, second}, f.sent)
+	q, err = s.Queued()
+	assert.Nil(err)
+	assert.Empty(q)
+
+	// Sent straight away when the notifier works
+	assert.Nil(s.Notify(testEvent))
+	assert.Len(f.sent, 3)
+}
+
+func TestSpoolQueueFails(t *testing.T) {
+	// The spool can't be created inside a file
+	s := &Spool{&flaky{down: true}, filepath.Join("notify.go", "spool")}
+	err := s.Notify(testEvent)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused, and failed to queue it")
+}
@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brettbates/p4access/config"
+)
+
+// Webhook posts json about events to chat bots, ticket systems etc.
+type Webhook struct {
+	URLs    []string
+	Secret  string   // Signs each body, see Sign
+	Events  []string // The events to post, e.g. query, request
+	Retries int
+	Wait    time.Duration // Between tries, doubled each time
+	Client  *http.Client
+	Spool   string // Posts that are still failing are queued here for Flush, empty drops them
+}
+
+// queuedPost is a failed post waiting in the spool
+type queuedPost struct {
+	URL   string `json:"url"`
+	Event string `json:"event"`
+	Body  []byte `json:"body"`
+}
+
+// NewWebhook sets up the webhooks in the config, it is nil if there aren't any
+// Like SMTP, the broker only tries once and queues failed posts for
+// 'p4access flush', so a slow receiver doesn't keep the user waiting
+func NewWebhook(c config.Config, broker bool) *Webhook {
+	if len(c.Webhooks) == 0 {
+		return nil
+	}
+	w := &Webhook{
+		URLs:    c.Webhooks,
+		Secret:  c.WebhookSecret,
+		Events:  c.WebhookEvents,
+		Retries: c.NotifyRetries,
+		Wait:    time.Second,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if broker {
+		w.Retries = 0
+		w.Client.Timeout = 5 * time.Second
+	}
+	if c.Spool != "" {
+		w.Spool = filepath.Join(c.Spool, "webhooks")
+	}
+	return w
+}
+
+// Queues checks whether failed posts are queued for Flush
+func (w *Webhook) Queues() bool {
+	return w != nil && w.Spool != ""
+}
+
+// Wants checks whether the event should be posted
+func (w *Webhook) Wants(event string) bool {
+	if w == nil {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign is the hex HMAC-SHA256 of the body, sent as 'X-P4Access-Signature: sha256=<hex>'
+// so the receiver can check the post came from us
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// Post sends the body to every URL, a failing URL doesn't stop the others
+// Posts that might work later are queued if there's a spool
+func (w *Webhook) Post(event string, body []byte) error {
+	failed := []string{}
+	for _, url := range w.URLs {
+		retry, err := w.post(url, event, body)
+		if err == nil {
+			continue
+		}
+		if retry && w.Queues() {
+			path, qerr := enqueue(w.Spool, queuedPost{url, event, body})
+			if qerr == nil {
+				log.Printf("Queued %s webhook to %s as %s, %v", event, url, path, err)
+				continue
+			}
+			err = fmt.Errorf("%v, and failed to queue it, %v", err, qerr)
+		}
+		log.Printf("Failed to post %s webhook to %s, %v", event, url, err)
+		failed = append(failed, url)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to post %s webhook to %s", event, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Flush tries every queued post again, those that might still work later
+// stay queued, others are dropped
+func (w *Webhook) Flush() (sent int, left int, err error) {
+	if !w.Queues() {
+		return 0, 0, nil
+	}
+	paths, err := queued(w.Spool)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return sent, len(paths) - sent, err
+		}
+		var p queuedPost
+		if err := json.Unmarshal(data, &p); err != nil {
+			return sent, len(paths) - sent, fmt.Errorf("Failed to read queued webhook %s, %v", path, err)
+		}
+		// Only URLs that are still set up are posted to, anyone who can
+		// write to the spool mustn't be able to send signed posts elsewhere
+		retry, err := false, fmt.Errorf("%s isn't one of the webhooks", p.URL)
+		if w.configured(p.URL) {
+			retry, err = w.post(p.URL, p.Event, p.Body)
+		}
+		if err != nil && retry {
+			log.Printf("Failed to post queued %s webhook %s to %s, %v", p.Event, path, p.URL, err)
+			left++
+			continue
+		}
+		if err != nil {
+			log.Printf("Dropped queued %s webhook %s to %s, %v", p.Event, path, p.URL, err)
+		} else {
+			sent++
+		}
+		if err := os.Remove(path); err != nil {
+			return sent, len(paths) - sent, err
+		}
+	}
+	return sent, left, nil
+}
+
+// configured checks whether url is one of the webhooks
+func (w *Webhook) configured(url string) bool {
+	for _, u := range w.URLs {
+		if u == url {
+			return true
+		}
+	}
+	return false
+}
+
+// post tries again on connection errors and server errors, other errors
+// like a 404 or 403 won't fix themselves
+// retry is whether a failure might work later
+func (w *Webhook) post(url, event string, body []byte) (retry bool, err error) {
+	wait := w.Wait
+	for try := 0; try <= w.Retries; try++ {
+		if try > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+		retry, err = w.send(url, event, body)
+		if err == nil || !retry {
+			return retry, err
+		}
+	}
+	return retry, err
+}
+
+func (w *Webhook) send(url, event string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "p4access")
+	req.Header.Set("X-P4Access-Event", event)
+	if w.Secret != "" {
+		req.Header.Set("X-P4Access-Signature", Sign(w.Secret, body))
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	ioutil.ReadAll(res.Body)
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("%s responded %s", url, res.Status)
+}
@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/brettbates/p4access/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// hookServer records what is posted to it, responding with codes in turn
+// and then 200 once they run out
+type hookServer struct {
+	mu     sync.Mutex
+	codes  []int
+	bodies []string
+	heads  []http.Header
+}
+
+func (h *hookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, _ := ioutil.ReadAll(r.Body)
+	h.bodies = append(h.bodies, string(b))
+	h.heads = append(h.heads, r.Header)
+	if len(h.codes) > 0 {
+		w.WriteHeader(h.codes[0])
+		h.codes = h.codes[1:]
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	assert := assert.New(t)
+	h := &hookServer{}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	w := NewWebhook(config.Config{Webhooks: []string{srv.URL}, WebhookSecret: "secret", WebhookEvents: []string{"request"}}, false)
+	assert.True(w.Wants("request"))
+	assert.False(w.Wants("query"))
+
+	body := []byte(`{"event": "request"}`)
+	assert.Nil(w.Post("request", body))
+	assert.Equal([]string{string(body)}, h.bodies)
+	assert.Equal("application/json", h.heads[0].Get("Content-Type"))
+	assert.Equal("request", h.heads[0].Get("X-P4Access-Event"))
+	// echo -n '{"event": "request"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal("sha256=f259fb7669264b6b87dcb3600e585a6f979084afd83650beea9e39c847c60e70", Sign("secret", body))
+	assert.Equal(Sign("secret", body), h.heads[0].Get("X-P4Access-Signature"))
+	assert.NotEqual(Sign("other", body), Sign("secret", body))
+}
+
+func TestWebhookRetry(t *testing.T) {
+	assert := assert.New(t)
+	h := &hookServer{codes: []int{http.StatusBadGateway, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	w := &Webhook{URLs: []string{srv.URL}, Retries: 2}
+	assert.Nil(w.Post("query", []byte(`{}`)))
+	assert.Len(h.bodies, 3)
+	assert.Empty(h.heads[0].Get("X-P4Access-Signature"))
+
+	// Not worth trying again
+	h = &hookServer{codes: []int{http.StatusNotFound}}
+	srv2 := httptest.NewServer(h)
+	defer srv2.Close()
+	w = &Webhook{URLs: []string{srv2.URL, srv.URL}, Retries: 2}
+	assert.EqualError(w.Post("query", []byte(`{}`)), "Failed to post query webhook to "+srv2.URL)
+	assert.Len(h.bodies, 1)
+
+	// Out of tries
+	h = &hookServer{codes: []int{500, 500}}
+	srv3 := httptest.NewServer(h)
+	defer srv3.Close()
+	w = &Webhook{URLs: []string{srv3.URL}, Retries: 1}
+	assert.Error(w.Post("query", []byte(`{}`)))
+	assert.Len(h.bodies, 2)
+}
+
+func TestWebhookQueue(t *testing.T) {
+	assert := assert.New(t)
+	h := &hookServer{codes: []int{http.StatusBadGateway, http.StatusNotFound, http.StatusBadGateway}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	w := &Webhook{URLs: []string{srv.URL}, Spool: filepath.Join(t.TempDir(), "webhooks")}
+
+	// Server errors are queued, other errors aren't worth it
+	assert.Nil(w.Post("query", []byte(`{"n": 1}`)))
+	assert.EqualError(w.Post("query", []byte(`{"n": 2}`)), "Failed to post query webhook to "+srv.URL)
+	q, err := queued(w.Spool)
+	assert.Nil(err)
+	assert.Len(q, 1)
+
+	// Still down
+	sent, left, err := w.Flush()
+	assert.Nil(err)
+	assert.Equal([]int{0, 1}, []int{sent, left})
+
+	sent, left, err = w.Flush()
+	assert.Nil(err)
+	assert.Equal([]int{1, 0}, []int{sent, left})
+	assert.Equal([]string{`{"n": 1}`, `{"n": 2}`, `{"n": 1}`, `{"n": 1}`}, h.bodies)
+	assert.Equal("query", h.heads[3].Get("X-P4Access-Event"))
+	q, err = queued(w.Spool)
+	assert.Nil(err)
+	assert.Empty(q)
+}
+
+func TestWebhookQueueUnknown(t *testing.T) {
+	assert := assert.New(t)
+	h := &hookServer{codes: []int{http.StatusBadGateway}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	w := &Webhook{URLs: []string{srv.URL}, Secret: "secret", Spool: filepath.Join(t.TempDir(), "webhooks")}
+	assert.Nil(w.Post("query", []byte(`{}`)))
+
+	// The URL has been taken out of the config since
+	w.URLs = []string{"http://127.0.0.1:1/other"}
+	sent, left, err := w.Flush()
+	assert.Nil(err)
+	assert.Equal([]int{0, 0}, []int{sent, left})
+	assert.Len(h.bodies, 1)
+	q, err := queued(w.Spool)
+	assert.Nil(err)
+	assert.Empty(q)
+}
+
+func TestNewWebhook(t *testing.T) {
+	var w *Webhook
+	assert.Equal(t, w, NewWebhook(config.Config{}, false))
+	assert.False(t, w.Wants("query"))
+	assert.False(t, w.Queues())
+
+	c := config.Config{Webhooks: []string{"http://bot"}, NotifyRetries: 2, Spool: "spool"}
+	w = NewWebhook(c, false)
+	assert.Equal(t, 2, w.Retries)
+	assert.Equal(t, filepath.Join("spool", "webhooks"), w.Spool)
+	// The broker tries once and leaves the rest to flush
+	w = NewWebhook(c, true)
+	assert.Equal(t, 0, w.Retries)
+	assert.True(t, w.Queues())
+}
@@ -4,13 +4,28 @@ import (
 	"log"
 	"os"
 
+	"github.com/brettbates/p4access/annotations"
+	"github.com/brettbates/p4access/approvers"
+	"github.com/brettbates/p4access/audit"
+	"github.com/brettbates/p4access/commands"
 	"github.com/brettbates/p4access/config"
+	"github.com/brettbates/p4access/contacts"
 	"github.com/brettbates/p4access/io"
+	"github.com/brettbates/p4access/notify"
+	"github.com/brettbates/p4access/policy"
 	"github.com/brettbates/p4access/prots"
+	"github.com/brettbates/p4access/requests"
 	"github.com/kelseyhightower/envconfig"
 )
 
 func main() {
+	// 'p4access <command>' from a shell, otherwise we're a p4broker filter
+	mode := io.Broker
+	if len(os.Args) > 1 {
+		mode = io.Direct
+	}
+	io.SetMode(mode)
+
 	var c config.Config
 	io.Reject(envconfig.Process("p4access", &c))
 	f, err := os.OpenFile(c.Log, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
@@ -19,16 +34,39 @@ func main() {
 	}
 	defer f.Close()
 	log.SetOutput(f)
-	args := io.Input()
-	// If we get 'p4 access -h', print help and exit
-	if args.ReqAccess == "-h" {
-		io.Help(c)
-		return
+
+	var args io.Args
+	if mode == io.Direct {
+		// Direct runs are made by whoever holds the config, so act as its user
+		args, err = io.Parse(c.P4User, os.Args[1:])
+		args.Request.User = c.P4User
+	} else {
+		args, err = io.Input()
 	}
-	p4c := prots.NewP4CParams(c)
-	res, err := prots.Protections(p4c, args.Path)
+	// Help is still given for otherwise bad arguments
+	if !args.Help {
+		io.Reject(err)
+	}
+	pol, err := policy.Load(c.Policy)
+	io.Reject(err)
+	apr, err := approvers.Load(c.Approvers)
+	io.Reject(err)
+	con, err := contacts.Load(c.Contacts)
 	io.Reject(err)
-	advice, err := res.Advise(p4c, args.User, args.Path, args.ReqAccess)
+	ann, err := annotations.Load(c.Annotations)
 	io.Reject(err)
-	io.Results(p4c, advice, args, c)
+	io.Reject(commands.Run(&commands.Env{
+		Config:      c,
+		P4:          prots.NewP4CParams(c),
+		Args:        args,
+		Audit:       audit.New(c.Audit),
+		Notify:      notify.New(c, mode == io.Broker),
+		Webhook:     notify.NewWebhook(c, mode == io.Broker),
+		Store:       requests.NewFileStore(c.Store),
+		Policy:      pol,
+		Approvers:   apr,
+		Contacts:    con,
+		Annotations: ann,
+		Mode:        mode,
+	}))
 }
@@ -0,0 +1,210 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// The actions a rule can take on a group
+const (
+	Allow    = "allow"    // Show it as normal, e.g. to make an exception to a later rule
+	Hide     = "hide"     // Leave it out of the results altogether
+	Annotate = "annotate" // Show it with the rule's explanation
+	Block    = "block"    // Show it with the explanation, but it can't be requested
+)
+
+// BlockedNote starts the note on a blocked group, before the rule's explanation
+const BlockedNote = "This group can't be requested, "
+
+// Rule matches a group being recommended to or requested by a user
+// Every field that is set must match, an empty field matches anything
+type Rule struct {
+	Name      string   `json:"name"`
+	Groups    []string `json:"groups,omitempty"`    // Group name patterns, e.g. P_export_*
+	Paths     []string `json:"paths,omitempty"`     // Depot path patterns, e.g. //depot/export/...
+	Members   []string `json:"members,omitempty"`   // The user is in any of these groups
+	UserTypes []string `json:"userTypes,omitempty"` // standard, operator or service
+	Access    []string `json:"access,omitempty"`    // read or write
+	Action    string   `json:"action"`
+	Explain   string   `json:"explain"` // Shown to the user and recorded in the audit log
+	paths     []*regexp.Regexp
+}
+
+// Policy is an ordered list of rules, the first that matches a group decides
+// what happens to it, groups no rule matches are allowed
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Decision is what a policy did with a group, and why
+type Decision struct {
+	Group   string `json:"group"`
+	Action  string `json:"action"`
+	Rule    string `json:"rule,omitempty"`
+	Explain string `json:"explain,omitempty"`
+}
+
+// Subject is the user the policy is applied to
+type Subject struct {
+	User   string
+	Groups []string
+	Type   string
+}
+
+// Load reads a policy file, an empty path is a policy with no rules
+func Load(file string) (*Policy, error) {
+	p := &Policy{}
+	if file == "" {
+		return p, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read policy %s, %v", file, err)
+	}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("Failed to read policy %s, %v", file, err)
+	}
+	for i := range p.Rules {
+		if err := p.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("Failed to read policy %s, rule %d '%s' %v", file, i+1, p.Rules[i].Name, err)
+		}
+	}
+	return p, nil
+}
+
+// compile checks the rule and prepares its path patterns
+func (r *Rule) compile() error {
+	switch r.Action {
+	case Allow, Hide, Annotate, Block:
+	default:
+		return fmt.Errorf("has an unknown action '%s', expected allow, hide, annotate or block", r.Action)
+	}
+	if r.Explain == "" && r.Action != Allow {
+		return fmt.Errorf("needs an explanation")
+	}
+	for _, g := range r.Groups {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("has a bad group pattern '%s'", g)
+		}
+	}
+	r.paths = nil
+	for _, p := range r.Paths {
+		r.paths = append(r.paths, prots.PathPattern(p))
+	}
+	return nil
+}
+
+// needs reports whether any rule looks at the user's groups or type
+func (p *Policy) needs() (groups, types bool) {
+	for _, r := range p.Rules {
+		groups = groups || len(r.Members) > 0
+		types = types || len(r.UserTypes) > 0
+	}
+	return
+}
+
+// Subject looks up what the rules need to know about the user
+func (p *Policy) Subject(p4r prots.P4Runner, user string) (Subject, error) {
+	s := Subject{User: user}
+	if p == nil {
+		return s, nil
+	}
+	groups, types := p.needs()
+	var err error
+	if groups {
+		if s.Groups, err = prots.UserGroups(p4r, user); err != nil {
+			return s, err
+		}
+	}
+	if types {
+		if s.Type, err = prots.UserType(p4r, user); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// Decide finds what the policy does with the group in info for the subject
+func (p *Policy) Decide(s Subject, info prots.Info) Decision {
+	if p != nil {
+		for _, r := range p.Rules {
+			if r.matches(s, info) {
+				return Decision{info.Group, r.Action, r.Name, r.Explain}
+			}
+		}
+	}
+	return Decision{Group: info.Group, Action: Allow}
+}
+
+// Apply decides on every group, leaving out the hidden ones and noting
+// the explanation on the annotated and blocked ones
+// The decisions other than allow are returned so they can be recorded
+func (p *Policy) Apply(s Subject, infos []prots.Info) ([]prots.Info, []Decision) {
+	out := []prots.Info{}
+	ds := []Decision{}
+	for _, i := range infos {
+		d := p.Decide(s, i)
+		switch d.Action {
+		case Hide:
+			ds = append(ds, d)
+			continue
+		case Annotate:
+			i.Note = d.Explain
+			ds = append(ds, d)
+		case Block:
+			i.Note = BlockedNote + d.Explain
+			ds = append(ds, d)
+		}
+		out = append(out, i)
+	}
+	return out, ds
+}
+
+func (r Rule) matches(s Subject, info prots.Info) bool {
+	return anyOf(r.Groups, func(g string) bool {
+		ok, _ := path.Match(g, info.Group)
+		return ok
+	}) && anyPath(r.paths, info.Path) &&
+		anyOf(r.Members, func(m string) bool { return in(s.Groups, m) }) &&
+		anyOf(r.UserTypes, func(t string) bool { return t == s.Type }) &&
+		anyOf(r.Access, func(a string) bool { return a == info.Access })
+}
+
+// anyOf is true for an empty list, otherwise when any entry matches
+func anyOf(list []string, match func(string) bool) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, l := range list {
+		if match(l) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyPath(ps []*regexp.Regexp, p string) bool {
+	if len(ps) == 0 {
+		return true
+	}
+	for _, re := range ps {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func in(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type FakeP4Runner struct {
+	mock.Mock
+}
+
+// Mocks p4.Run, so we can run fake perforce commands
+func (mock *FakeP4Runner) Run(args []string) ([]map[interface{}]interface{}, error) {
+	ags := mock.Called(args)
+	return ags.Get(0).([]map[interface{}]interface{}), ags.Error(1)
+}
+
+const testPolicy = `{"rules": [
+	{"name": "contractors-export", "groups": ["P_export_*"], "members": ["G_contractors"], "action": "block",
+	 "explain": "export controlled code can't be requested by contractors"},
+	{"name": "export", "paths": ["//depot/export/..."], "access": ["write"], "action": "annotate",
+	 "explain": "owners will check your export training"},
+	{"name": "admins", "groups": ["G_admin*"], "action": "hide", "explain": "admin groups are never self-requested"},
+	{"name": "services", "userTypes": ["service"], "access": ["write"], "action": "block", "explain": "service users need a ticket"}
+]}`
+
+func load(t *testing.T, policy string) (*Policy, error) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(policy), 0666))
+	return Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	p, err := load(t, testPolicy)
+	assert.Nil(err)
+	assert.Len(p.Rules, 4)
+
+	p, err = Load("")
+	assert.Nil(err)
+	assert.Empty(p.Rules)
+
+	for policy, want := range map[string]string{
+		`{"rules": [{"name": "x", "action": "deny", "explain": "no"}]}`:                     "rule 1 'x' has an unknown action 'deny', expected allow, hide, annotate or block",
+		`{"rules": [{"name": "x", "action": "block"}]}`:                                     "rule 1 'x' needs an explanation",
+		`{"rules": [{"name": "x", "groups": ["P_[a"], "action": "hide", "explain": "no"}]}`: "rule 1 'x' has a bad group pattern 'P_[a'",
+	} {
+		_, err := load(t, policy)
+		assert.Error(err)
+		assert.Contains(err.Error(), want)
+	}
+	_, err = load(t, `{"rules": [`)
+	assert.Error(err)
+}
+
+func TestDecide(t *testing.T) {
+	p, err := load(t, testPolicy)
+	assert.Nil(t, err)
+	contractor := Subject{User: "c.user", Groups: []string{"G_contractors"}, Type: "standard"}
+	staff := Subject{User: "a.user", Groups: []string{"G_staff"}, Type: "standard"}
+	service := Subject{User: "svc", Type: "service"}
+	exportW := prots.Info{Group: "P_export_main", Path: "//depot/export/main/...", Access: "write"}
+	exportR := prots.Info{Group: "P_export_read", Path: "//depot/export/main/...", Access: "read"}
+	jam := prots.Info{Group: "P_jam", Path: "//depot/Jam/...", Access: "write"}
+
+	assert.Equal(t, Decision{"P_export_main", Block, "contractors-export", "export controlled code can't be requested by contractors"},
+		p.Decide(contractor, exportW))
+	assert.Equal(t, Decision{"P_export_main", Annotate, "export", "owners will check your export training"}, p.Decide(staff, exportW))
+	assert.Equal(t, Decision{Group: "P_export_read", Action: Allow}, p.Decide(staff, exportR))
+	assert.Equal(t, Decision{"G_admins", Hide, "admins", "admin groups are never self-requested"},
+		p.Decide(staff, prots.Info{Group: "G_admins", Path: "//...", Access: "read"}))
+	assert.Equal(t, Decision{"P_jam", Block, "services", "service users need a ticket"}, p.Decide(service, jam))
+	assert.Equal(t, Decision{Group: "P_jam", Action: Allow}, p.Decide(staff, jam))
+
+	var none *Policy
+	assert.Equal(t, Decision{Group: "P_jam", Action: Allow}, none.Decide(staff, jam))
+}
+
+func TestApply(t *testing.T) {
+	p, err := load(t, testPolicy)
+	assert.Nil(t, err)
+	infos := []prots.Info{
+		{Group: "P_export_main", Path: "//depot/export/...", Access: "write"},
+		{Group: "G_admins", Path: "//depot/...", Access: "write"},
+		{Group: "P_export_all", Path: "//depot/export/...", Access: "write"},
+	}
+	out, ds := p.Apply(Subject{User: "c.user", Groups: []string{"G_contractors"}}, infos)
+	assert.Equal(t, []prots.Info{
+		{Group: "P_export_main", Path: "//depot/export/...", Access: "write",
+			Note: "This group can't be requested, export controlled code can't be requested by contractors"},
+		{Group: "P_export_all", Path: "//depot/export/...", Access: "write",
+			Note: "This group can't be requested, export controlled code can't be requested by contractors"},
+	}, out)
+	assert.Len(t, ds, 3)
+	assert.Equal(t, Hide, ds[1].Action)
+	// The caller's infos are left alone
+	assert.Empty(t, infos[0].Note)
+}
+
+func TestSubject(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"groups", "-i", "-u", "svc"}).Return(
+		[]map[interface{}]interface{}{{"group": "G_builds"}}, nil).
+		On("Run", []string{"user", "-o", "svc"}).Return(
+		[]map[interface{}]interface{}{{"User": "svc", "Type": "service"}}, nil)
+	p, err := load(t, testPolicy)
+	assert.Nil(t, err)
+	s, err := p.Subject(fp4, "svc")
+	assert.Nil(t, err)
+	assert.Equal(t, Subject{"svc", []string{"G_builds"}, "service"}, s)
+
+	// Nothing is looked up that no rule needs
+	fp4 = &FakeP4Runner{}
+	p, err = load(t, `{"rules": [{"name": "x", "groups": ["P_*"], "action": "hide", "explain": "no"}]}`)
+	assert.Nil(t, err)
+	s, err = p.Subject(fp4, "a.user")
+	assert.Nil(t, err)
+	assert.Equal(t, Subject{User: "a.user"}, s)
+	fp4.AssertNotCalled(t, "Run", mock.Anything)
+}
@@ -0,0 +1,87 @@
+package prots
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// Client is where a request came from
+// The IP is used to honour host based protections and the workspace
+// and cwd let users give paths in client or local syntax
+type Client struct {
+	IP        string
+	ProxyIP   string
+	Workspace string
+	Cwd       string
+}
+
+// hostArgs adds -h for 'p4 protects' when we know the client's address
+func (c Client) hostArgs(args []string) []string {
+	if c.IP == "" {
+		return args
+	}
+	return append(args, "-h", c.IP)
+}
+
+// matchesHost checks whether a protections host field applies to the client
+// Without a known address every host is assumed to apply
+func (c Client) matchesHost(host string) bool {
+	if c.IP == "" || host == "" || host == "*" {
+		return true
+	}
+	// proxy- lines only apply to requests that came through a proxy
+	if strings.HasPrefix(host, "proxy-") {
+		if c.ProxyIP == "" {
+			return false
+		}
+		host = strings.TrimPrefix(host, "proxy-")
+	}
+	if _, n, err := net.ParseCIDR(host); err == nil {
+		ip := net.ParseIP(c.IP)
+		return ip != nil && n.Contains(ip)
+	}
+	// Protections hosts use * as a wildcard, which path.Match handles
+	// as long as there are no slashes involved
+	ok, err := path.Match(host, c.IP)
+	return err == nil && ok
+}
+
+// Where turns a path given in client or local syntax into depot syntax using
+// the client's workspace, depot syntax paths are returned as they are
+func Where(p4r P4Runner, c Client, p string) (string, error) {
+	if c.Workspace == "" || !isClientPath(c, p) {
+		return p, nil
+	}
+	args := []string{"-c", c.Workspace}
+	if c.Cwd != "" {
+		args = append(args, "-d", c.Cwd)
+	}
+	res, err := p4r.Run(append(args, "where", p))
+	if err != nil {
+		return "", err
+	}
+	if len(res) == 0 {
+		return "", fmt.Errorf("Path %s is not in workspace %s", p, c.Workspace)
+	}
+	if v, ok := res[0]["code"]; ok && v.(string) == "error" {
+		if d, ok := res[0]["data"]; ok {
+			return "", errors.New(strings.TrimSpace(d.(string)))
+		}
+		return "", fmt.Errorf("Path %s is not in workspace %s", p, c.Workspace)
+	}
+	if v, ok := res[0]["depotFile"]; ok {
+		return v.(string), nil
+	}
+	return "", fmt.Errorf("Path %s is not in workspace %s", p, c.Workspace)
+}
+
+// isClientPath checks for //workspace/... or local paths
+func isClientPath(c Client, p string) bool {
+	if !strings.HasPrefix(p, "//") {
+		return true
+	}
+	return strings.HasPrefix(p, "//"+c.Workspace+"/")
+}
@@ -0,0 +1,142 @@
+package prots
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type matchesHostTest struct {
+	client Client
+	host   string
+	want   bool
+}
+
+var matchesHostTests = []matchesHostTest{
+	{Client{}, "10.0.0.1", true},
+	{Client{IP: "10.0.0.1"}, "*", true},
+	{Client{IP: "10.0.0.1"}, "10.0.0.1", true},
+	{Client{IP: "10.0.0.1"}, "10.0.0.2", false},
+	{Client{IP: "10.0.0.1"}, "10.0.*", true},
+	{Client{IP: "10.1.0.1"}, "10.0.*", false},
+	{Client{IP: "10.0.0.1"}, "10.0.0.0/8", true},
+	{Client{IP: "192.168.0.1"}, "10.0.0.0/8", false},
+	{Client{IP: "10.0.0.1"}, "proxy-10.0.0.1", false},
+	{Client{IP: "10.0.0.1", ProxyIP: "10.0.0.9"}, "proxy-10.0.0.*", true},
+}
+
+func TestMatchesHost(t *testing.T) {
+	for _, tst := range matchesHostTests {
+		assert.Equal(t, tst.want, tst.client.matchesHost(tst.host), "%v %s", tst.client, tst.host)
+	}
+}
+
+type whereTest struct {
+	client Client
+	path   string
+	args   []string
+	ret    []map[interface{}]interface{}
+	want   string
+	err    error
+}
+
+var whereTests = []whereTest{
+	{ // No workspace, nothing to do
+		Client{},
+		"//depot/path/...",
+		nil,
+		nil,
+		"//depot/path/...",
+		nil,
+	},
+	{ // Depot syntax is left alone
+		Client{Workspace: "a_ws", Cwd: "/home/a.user/ws"},
+		"//depot/path/...",
+		nil,
+		nil,
+		"//depot/path/...",
+		nil,
+	},
+	{ // Client syntax
+		Client{Workspace: "a_ws", Cwd: "/home/a.user/ws"},
+		"//a_ws/path/...",
+		[]string{"-c", "a_ws", "-d", "/home/a.user/ws", "where", "//a_ws/path/..."},
+		[]map[interface{}]interface{}{{
+			"depotFile":  "//depot/main/path/...",
+			"clientFile": "//a_ws/path/...",
+			"path":       "/home/a.user/ws/path/...",
+		}},
+		"//depot/main/path/...",
+		nil,
+	},
+	{ // Local syntax relative to the cwd
+		Client{Workspace: "a_ws", Cwd: "/home/a.user/ws"},
+		"path/...",
+		[]string{"-c", "a_ws", "-d", "/home/a.user/ws", "where", "path/..."},
+		[]map[interface{}]interface{}{{
+			"depotFile":  "//depot/main/path/...",
+			"clientFile": "//a_ws/path/...",
+			"path":       "/home/a.user/ws/path/...",
+		}},
+		"//depot/main/path/...",
+		nil,
+	},
+	{
+		Client{Workspace: "a_ws"},
+		"elsewhere/...",
+		[]string{"-c", "a_ws", "where", "elsewhere/..."},
+		[]map[interface{}]interface{}{{
+			"code": "error",
+			"data": "elsewhere/... - file(s) not in client view.\n",
+		}},
+		"",
+		errors.New("elsewhere/... - file(s) not in client view."),
+	},
+}
+
+func TestWhere(t *testing.T) {
+	for _, tst := range whereTests {
+		fp4 := &FakeP4Runner{}
+		if tst.args != nil {
+			fp4.On("Run", tst.args).Return(tst.ret, nil)
+		}
+		res, err := Where(fp4, tst.client, tst.path)
+		if tst.err == nil {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, tst.err.Error())
+		}
+		assert.Equal(t, tst.want, res)
+	}
+}
+
+func TestFilterHost(t *testing.T) {
+	// Lines for other hosts are skipped, and the host is passed on to p4
+	ps := Prots{
+		{
+			Perm:      "write",
+			Host:      "10.0.0.0/8",
+			User:      "grp",
+			IsGroup:   true,
+			Line:      1,
+			DepotFile: "//depot/...",
+			Segments:  2,
+		},
+		{
+			Perm:      "write",
+			Host:      "192.168.*",
+			User:      "grp2",
+			IsGroup:   true,
+			Line:      2,
+			DepotFile: "//depot/...",
+			Segments:  2,
+		},
+	}
+	fp4 := &FakeP4Runner{}
+	pwrite := []map[interface{}]interface{}{{"permMax": "write"}}
+	fp4.On("Run", []string{"protects", "-M", "-g", "grp", "-h", "10.1.2.3", "//depot/path"}).Return(pwrite, nil)
+	res, err := ps.filter(fp4, Client{IP: "10.1.2.3"}, "//depot/path", "write")
+	assert.Nil(t, err)
+	assert.Equal(t, Prots{ps[0]}, res)
+}
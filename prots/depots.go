@@ -0,0 +1,87 @@
+package prots
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Depot is the part of a depot spec we care about
+type Depot struct {
+	Name        string `json:"depot"`
+	Owner       string `json:"owner,omitempty"`
+	Type        string `json:"type"`
+	StreamDepth int    `json:"streamDepth,omitempty"` // How many levels below the depot stream names go, 1 by default
+}
+
+// DepotOf reads the spec of the depot a path is in
+// p4 makes up a spec for depots that don't exist, those are returned
+// without an owner
+func DepotOf(p4r P4Runner, path string) (Depot, error) {
+	name := strings.SplitN(strings.TrimPrefix(path, "//"), "/", 2)[0]
+	d := Depot{Name: name}
+	if name == "" || strings.ContainsAny(name, "*.%") {
+		return d, nil
+	}
+	res, err := p4r.Run([]string{"depot", "-o", name})
+	if err != nil {
+		return d, err
+	}
+	if len(res) == 0 {
+		return d, nil
+	}
+	// Only saved specs have a date
+	if _, ok := res[0]["Date"]; !ok {
+		return d, nil
+	}
+	if v, ok := res[0]["Owner"].(string); ok {
+		d.Owner = v
+	}
+	if v, ok := res[0]["Type"].(string); ok {
+		d.Type = v
+	}
+	if d.Type == "stream" {
+		d.StreamDepth = 1
+		// StreamDepth is //depot/N
+		if v, ok := res[0]["StreamDepth"].(string); ok {
+			if n, err := strconv.Atoi(v[strings.LastIndex(v, "/")+1:]); err == nil && n > 0 {
+				d.StreamDepth = n
+			}
+		}
+	}
+	return d, nil
+}
+
+// Stream works out which of the depot's streams a path is in, empty when
+// it isn't a stream depot or the path doesn't reach a whole stream name
+func (d Depot) Stream(path string) string {
+	if d.Type != "stream" {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "//"), "/")
+	if len(parts) <= d.StreamDepth {
+		return ""
+	}
+	for _, p := range parts[1 : d.StreamDepth+1] {
+		if p == "" || strings.ContainsAny(p, "*.%") {
+			return ""
+		}
+	}
+	return "//" + strings.Join(parts[:d.StreamDepth+1], "/")
+}
+
+// StreamOwner reads the owner of a stream, empty if it doesn't exist
+func StreamOwner(p4r P4Runner, stream string) (string, error) {
+	res, err := p4r.Run([]string{"stream", "-o", stream})
+	if err != nil {
+		return "", err
+	}
+	// p4 makes up a spec, owned by whoever asked, for streams that don't exist
+	if len(res) == 0 {
+		return "", nil
+	}
+	if _, ok := res[0]["Update"]; !ok {
+		return "", nil
+	}
+	owner, _ := res[0]["Owner"].(string)
+	return owner, nil
+}
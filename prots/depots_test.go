@@ -0,0 +1,60 @@
+package prots
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDepotOf(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"depot", "-o", "depot"}).Return([]map[interface{}]interface{}{
+		{"Depot": "depot", "Owner": "depot.owner", "Type": "local", "Date": "2021/03/09 10:00:00"},
+	}, nil).
+		On("Run", []string{"depot", "-o", "jam"}).Return([]map[interface{}]interface{}{
+		{"Depot": "jam", "Owner": "jam.owner", "Type": "stream", "StreamDepth": "//jam/2", "Date": "2021/03/09 10:00:00"},
+	}, nil).
+		On("Run", []string{"depot", "-o", "gone"}).Return([]map[interface{}]interface{}{
+		{"Depot": "gone", "Owner": "p4access", "Type": "local"},
+	}, nil)
+
+	d, err := DepotOf(fp4, "//depot/Jam/MAIN/...")
+	assert.Nil(err)
+	assert.Equal(Depot{Name: "depot", Owner: "depot.owner", Type: "local"}, d)
+	assert.Empty(d.Stream("//depot/Jam/MAIN/..."))
+
+	d, err = DepotOf(fp4, "//jam/dev/main/src/...")
+	assert.Nil(err)
+	assert.Equal(Depot{Name: "jam", Owner: "jam.owner", Type: "stream", StreamDepth: 2}, d)
+	assert.Equal("//jam/dev/main", d.Stream("//jam/dev/main/src/..."))
+	assert.Empty(d.Stream("//jam/dev/..."))
+	assert.Empty(d.Stream("//jam/*/main/..."))
+
+	// Made up specs have no owner
+	d, err = DepotOf(fp4, "//gone/...")
+	assert.Nil(err)
+	assert.Empty(d.Owner)
+
+	// Wildcards don't name a depot
+	d, err = DepotOf(fp4, "//.../file.c")
+	assert.Nil(err)
+	assert.Empty(d.Owner)
+}
+
+func TestStreamOwner(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"stream", "-o", "//jam/main"}).Return([]map[interface{}]interface{}{
+		{"Stream": "//jam/main", "Owner": "stream.owner", "Update": "2021/03/09 10:00:00"},
+	}, nil).
+		On("Run", []string{"stream", "-o", "//jam/gone"}).Return([]map[interface{}]interface{}{
+		{"Stream": "//jam/gone", "Owner": "p4access"},
+	}, nil)
+	o, err := StreamOwner(fp4, "//jam/main")
+	assert.Nil(err)
+	assert.Equal("stream.owner", o)
+	o, err = StreamOwner(fp4, "//jam/gone")
+	assert.Nil(err)
+	assert.Empty(o)
+}
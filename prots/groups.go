@@ -0,0 +1,266 @@
+package prots
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Group is the part of a group spec we care about
+type Group struct {
+	Name        string   `json:"group"`
+	Description string   `json:"description,omitempty"`
+	Owners      []string `json:"owners"`
+	Users       []string `json:"users"`
+	Subgroups   []string `json:"subgroups"`
+	Ldap        *Ldap    `json:"ldap,omitempty"`
+}
+
+// Ldap is how 'p4 ldapsync' fills a group, its members are replaced with
+// whoever the search finds each time it runs
+type Ldap struct {
+	Config        string `json:"config"`                  // The LDAP configuration, see 'p4 ldap -o'
+	SearchQuery   string `json:"searchQuery,omitempty"`   // The search that finds the members
+	UserAttribute string `json:"userAttribute,omitempty"` // The attribute holding their user names
+	// Who to ask to be added on the LDAP side, set from the config rather than the spec
+	Contact string `json:"contact,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// Source describes where the members come from, for showing to users
+func (l Ldap) Source() string {
+	if l.SearchQuery == "" {
+		return "LDAP configuration " + l.Config
+	}
+	return fmt.Sprintf("the search %s on LDAP configuration %s", l.SearchQuery, l.Config)
+}
+
+// ldapOf reads the LDAP fields of a group spec, nil if it has none
+func ldapOf(res map[interface{}]interface{}) *Ldap {
+	l := &Ldap{}
+	l.Config, _ = res["LdapConfig"].(string)
+	l.SearchQuery, _ = res["LdapSearchQuery"].(string)
+	l.UserAttribute, _ = res["LdapUserAttribute"].(string)
+	if l.Config == "" && l.SearchQuery == "" {
+		return nil
+	}
+	return l
+}
+
+// Membership is how a user belongs to a group
+type Membership struct {
+	Member bool     `json:"member"`
+	Direct bool     `json:"direct"`
+	Via    []string `json:"via,omitempty"` // Subgroups that make the user a member
+}
+
+// GetGroup reads a group spec
+// p4 hands back an empty spec for groups that don't exist, and a group
+// can't exist without members, so an empty spec is an error here, unless
+// LDAP fills it
+func GetGroup(p4r P4Runner, name string) (Group, error) {
+	res, err := p4r.Run([]string{"group", "-o", name})
+	if err != nil {
+		return Group{}, err
+	}
+	if len(res) == 0 {
+		return Group{}, fmt.Errorf("No such group '%s'", name)
+	}
+	g := Group{
+		Name:      name,
+		Owners:    listField(res[0], "Owners"),
+		Users:     listField(res[0], "Users"),
+		Subgroups: listField(res[0], "Subgroups"),
+	}
+	if v, ok := res[0]["Description"]; ok {
+		g.Description = v.(string)
+	}
+	g.Ldap = ldapOf(res[0])
+	if len(g.Owners) == 0 && len(g.Users) == 0 && len(g.Subgroups) == 0 && g.Ldap == nil {
+		return Group{}, fmt.Errorf("No such group '%s'", name)
+	}
+	return g, nil
+}
+
+// listField collects the values of a spec's list field, e.g. Users0, Users1...
+func listField(res map[interface{}]interface{}, key string) []string {
+	out := []string{}
+	// There is an indeterminate amount of KeyX: value
+	// so we have to just try them all until we run out
+	for i := 0; ; i++ {
+		v, ok := res[fmt.Sprintf("%s%d", key, i)]
+		if !ok {
+			return out
+		}
+		out = append(out, v.(string))
+	}
+}
+
+// Owners returns the owners of a group along with their names and emails
+func Owners(p4r P4Runner, group string) ([]Owner, error) {
+	return Prot{User: group, IsGroup: true}.owners(p4r)
+}
+
+// UserGroups lists every group a user belongs to, directly or through subgroups
+func UserGroups(p4r P4Runner, user string) ([]string, error) {
+	return groups(p4r, []string{"groups", "-i", "-u", user})
+}
+
+// DirectGroups lists the groups a user is named in
+func DirectGroups(p4r P4Runner, user string) ([]string, error) {
+	return groups(p4r, []string{"groups", "-u", user})
+}
+
+// AllGroups lists every group on the server
+func AllGroups(p4r P4Runner) ([]string, error) {
+	return groups(p4r, []string{"groups"})
+}
+
+func groups(p4r P4Runner, args []string) ([]string, error) {
+	res, err := p4r.Run(args)
+	if err != nil {
+		return nil, err
+	}
+	// Without a user there is a record for every member of every group
+	seen := map[string]bool{}
+	out := []string{}
+	for _, r := range res {
+		if v, ok := r["group"]; ok && !seen[v.(string)] {
+			seen[v.(string)] = true
+			out = append(out, v.(string))
+		}
+	}
+	return out, nil
+}
+
+// Member works out whether and how a user belongs to the group
+func (g Group) Member(p4r P4Runner, user string) (Membership, error) {
+	m := Membership{}
+	for _, u := range g.Users {
+		if u == user {
+			m.Member = true
+			m.Direct = true
+		}
+	}
+	if len(g.Subgroups) == 0 {
+		return m, nil
+	}
+	groups, err := UserGroups(p4r, user)
+	if err != nil {
+		return m, err
+	}
+	for _, s := range g.Subgroups {
+		for _, ug := range groups {
+			if s == ug {
+				m.Member = true
+				m.Via = append(m.Via, s)
+			}
+		}
+	}
+	return m, nil
+}
+
+// AddUser adds a user directly to a group, rewriting its spec with 'p4 group -i'
+// Every other field of the spec is written back as it was
+func AddUser(p4w P4Writer, group, user string) error {
+	return setUsers(p4w, group, func(users []string) []string {
+		for _, u := range users {
+			if u == user {
+				return nil
+			}
+		}
+		return append(users, user)
+	})
+}
+
+// RemoveUser takes a user out of a group's Users, it can't remove
+// them from the group's subgroups
+func RemoveUser(p4w P4Writer, group, user string) error {
+	return setUsers(p4w, group, func(users []string) []string {
+		out := []string{}
+		for _, u := range users {
+			if u != user {
+				out = append(out, u)
+			}
+		}
+		if len(out) == len(users) {
+			return nil
+		}
+		return out
+	})
+}
+
+// setUsers rewrites the group's Users with what change returns
+// change returns nil when there is nothing to do
+func setUsers(p4w P4Writer, group string, change func([]string) []string) error {
+	res, err := p4w.Run([]string{"group", "-o", group})
+	if err != nil {
+		return err
+	}
+	if len(res) == 0 {
+		return fmt.Errorf("No such group '%s'", group)
+	}
+	users := change(listField(res[0], "Users"))
+	if users == nil {
+		return nil
+	}
+	spec := map[interface{}]interface{}{}
+	for k, v := range res[0] {
+		if m := listKey.FindStringSubmatch(fmt.Sprint(k)); m == nil || m[1] != "Users" {
+			spec[k] = v
+		}
+	}
+	for i, u := range users {
+		spec[fmt.Sprintf("Users%d", i)] = u
+	}
+	_, err = p4w.Input([]string{"group", "-i"}, formatSpec(spec))
+	return err
+}
+
+// listKey splits a list field's key into its name and index, e.g. Users12
+var listKey = regexp.MustCompile(`^([A-Za-z]+)([0-9]+)$`)
+
+// formatSpec turns 'p4 -G' spec output back into the text form p4 reads on -i
+func formatSpec(res map[interface{}]interface{}) string {
+	fields := map[string]string{}
+	lists := map[string]string{}
+	for k, v := range res {
+		key, ok := k.(string)
+		val, vok := v.(string)
+		if !ok || !vok || key == "code" {
+			continue
+		}
+		if m := listKey.FindStringSubmatch(key); m != nil {
+			lists[m[1]] = ""
+			continue
+		}
+		fields[key] = val
+	}
+	var b strings.Builder
+	for _, key := range sortedKeys(fields) {
+		val := strings.TrimRight(fields[key], "\n")
+		if strings.Contains(val, "\n") {
+			b.WriteString(key + ":\n\t" + strings.ReplaceAll(val, "\n", "\n\t") + "\n\n")
+		} else {
+			b.WriteString(key + ": " + val + "\n\n")
+		}
+	}
+	for _, name := range sortedKeys(lists) {
+		b.WriteString(name + ":\n")
+		for _, v := range listField(res, name) {
+			b.WriteString("\t" + v + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,193 @@
+package prots
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var groupSpec = []map[interface{}]interface{}{{
+	"code":        "stat",
+	"Group":       "P_group_name",
+	"Description": "Read access to the jam main line",
+	"Timeout":     "43200",
+	"Subgroups0":  "A_subgroup",
+	"Subgroups1":  "B_subgroup",
+	"Users0":      "some.guy",
+	"Users1":      "a.user",
+	"Owners0":     "owner.first",
+}}
+
+func TestGetGroup(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_group_name"}).Return(groupSpec, nil).
+		On("Run", []string{"group", "-o", "nosuch"}).Return(
+		[]map[interface{}]interface{}{{"code": "stat", "Group": "nosuch", "Timeout": "43200"}}, nil).
+		On("Run", []string{"group", "-o", "broken"}).Return(
+		[]map[interface{}]interface{}{}, errors.New("exit status 1"))
+
+	g, err := GetGroup(fp4, "P_group_name")
+	assert.Nil(err)
+	assert.Equal(Group{
+		Name:        "P_group_name",
+		Description: "Read access to the jam main line",
+		Owners:      []string{"owner.first"},
+		Users:       []string{"some.guy", "a.user"},
+		Subgroups:   []string{"A_subgroup", "B_subgroup"},
+	}, g)
+
+	_, err = GetGroup(fp4, "nosuch")
+	assert.EqualError(err, "No such group 'nosuch'")
+
+	_, err = GetGroup(fp4, "broken")
+	assert.EqualError(err, "exit status 1")
+}
+
+func TestGetGroupLdap(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	// ldapsync hasn't found anyone yet, the group still exists
+	fp4.On("Run", []string{"group", "-o", "P_ldap"}).Return([]map[interface{}]interface{}{{
+		"Group":             "P_ldap",
+		"LdapConfig":        "corp",
+		"LdapSearchQuery":   "(memberOf=cn=jam-devs,ou=groups,dc=example,dc=com)",
+		"LdapUserAttribute": "uid",
+	}}, nil)
+	g, err := GetGroup(fp4, "P_ldap")
+	assert.Nil(err)
+	assert.Equal(&Ldap{
+		Config:        "corp",
+		SearchQuery:   "(memberOf=cn=jam-devs,ou=groups,dc=example,dc=com)",
+		UserAttribute: "uid",
+	}, g.Ldap)
+
+	// Plain groups have no LDAP fields
+	fp4.On("Run", []string{"group", "-o", "P_group_name"}).Return(groupSpec, nil)
+	g, err = GetGroup(fp4, "P_group_name")
+	assert.Nil(err)
+	assert.Nil(g.Ldap)
+}
+
+type memberTest struct {
+	user   string
+	groups []map[interface{}]interface{}
+	want   Membership
+}
+
+var memberTests = []memberTest{
+	{ // Directly in the group
+		"a.user",
+		[]map[interface{}]interface{}{{"group": "P_group_name"}},
+		Membership{Member: true, Direct: true},
+	},
+	{ // Through a subgroup of a subgroup
+		"other.user",
+		[]map[interface{}]interface{}{{"group": "C_subsubgroup"}, {"group": "B_subgroup"}, {"group": "P_group_name"}},
+		Membership{Member: true, Via: []string{"B_subgroup"}},
+	},
+	{ // Not a member
+		"not.member",
+		[]map[interface{}]interface{}{{"group": "Unrelated"}},
+		Membership{},
+	},
+}
+
+func TestMember(t *testing.T) {
+	g := Group{
+		Name:      "P_group_name",
+		Users:     []string{"some.guy", "a.user"},
+		Subgroups: []string{"A_subgroup", "B_subgroup"},
+	}
+	for _, tst := range memberTests {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"groups", "-i", "-u", tst.user}).Return(tst.groups, nil)
+		res, err := g.Member(fp4, tst.user)
+		assert.Nil(t, err)
+		assert.Equal(t, tst.want, res, tst.user)
+	}
+}
+
+func TestForGroup(t *testing.T) {
+	ps := Prots{
+		{Perm: "write", User: "grp", IsGroup: true, Line: 1, DepotFile: "//depot/..."},
+		{Perm: "write", User: "grp", IsGroup: false, Line: 2, DepotFile: "//depot/..."},
+		{Perm: "read", User: "grp2", IsGroup: true, Line: 3, DepotFile: "//depot/..."},
+		{Perm: "write", User: "grp", IsGroup: true, Line: 4, DepotFile: "//depot/secret/...", Unmap: true},
+	}
+	assert.Equal(t, Prots{ps[0], ps[3]}, ps.ForGroup("grp"))
+	assert.Equal(t, Prots{}, ps.ForGroup("nosuch"))
+}
+
+func TestProtectionsTable(t *testing.T) {
+	// No path gives the whole table
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"protects", "-a"}).Return([]map[interface{}]interface{}{{
+		"perm": "super", "host": "*", "user": "perforce", "line": "1", "depotFile": "//...",
+	}}, nil)
+	res, err := Protections(fp4, "")
+	assert.Nil(t, err)
+	assert.Equal(t, Prots{{Perm: "super", Host: "*", User: "perforce", Line: 1, DepotFile: "//...", Segments: 1}}, res)
+}
+
+func TestAddUser(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_group_name"}).Return([]map[interface{}]interface{}{{
+		"code":        "stat",
+		"Group":       "P_group_name",
+		"Description": "Read access to\nthe jam main line\n",
+		"Timeout":     "43200",
+		"Subgroups0":  "A_subgroup",
+		"Users0":      "some.guy",
+		"Users1":      "a.user",
+		"Owners0":     "owner.first",
+	}}, nil).
+		On("Input", []string{"group", "-i"}, "Description:\n\tRead access to\n\tthe jam main line\n\n"+
+			"Group: P_group_name\n\nTimeout: 43200\n\n"+
+			"Owners:\n\towner.first\n\nSubgroups:\n\tA_subgroup\n\nUsers:\n\tsome.guy\n\ta.user\n\tnew.user\n\n").
+		Return("Group P_group_name updated.", nil)
+
+	assert.Nil(AddUser(fp4, "P_group_name", "new.user"))
+	fp4.AssertNumberOfCalls(t, "Input", 1)
+
+	// Existing members are left alone
+	assert.Nil(AddUser(fp4, "P_group_name", "a.user"))
+	fp4.AssertNumberOfCalls(t, "Input", 1)
+
+	fp4.On("Run", []string{"group", "-o", "locked"}).Return(
+		[]map[interface{}]interface{}{{"Group": "locked", "Users0": "some.guy"}}, nil).
+		On("Input", []string{"group", "-i"}, "Group: locked\n\nUsers:\n\tsome.guy\n\tnew.user\n\n").
+		Return("", errors.New("p4 group -i failed, exit status 1"))
+	assert.EqualError(AddUser(fp4, "locked", "new.user"), "p4 group -i failed, exit status 1")
+}
+
+func TestRemoveUser(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_group_name"}).Return(groupSpec, nil).
+		On("Input", []string{"group", "-i"}, "Description: Read access to the jam main line\n\n"+
+			"Group: P_group_name\n\nTimeout: 43200\n\n"+
+			"Owners:\n\towner.first\n\nSubgroups:\n\tA_subgroup\n\tB_subgroup\n\nUsers:\n\ta.user\n\n").
+		Return("Group P_group_name updated.", nil)
+
+	assert.Nil(RemoveUser(fp4, "P_group_name", "some.guy"))
+	fp4.AssertNumberOfCalls(t, "Input", 1)
+
+	// Not a member, nothing to do
+	assert.Nil(RemoveUser(fp4, "P_group_name", "new.user"))
+	fp4.AssertNumberOfCalls(t, "Input", 1)
+}
+
+func TestAllGroups(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"groups"}).Return([]map[interface{}]interface{}{
+		{"group": "P_jam", "user": "a.user", "isOwner": "0", "isUser": "1"},
+		{"group": "P_jam", "user": "jam.owner", "isOwner": "1", "isUser": "0"},
+		{"group": "P_other", "user": "b.user", "isOwner": "0", "isUser": "1"},
+	}, nil)
+	gs, err := AllGroups(fp4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"P_jam", "P_other"}, gs)
+}
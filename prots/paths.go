@@ -0,0 +1,87 @@
+package prots
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GroupPath is a path that a group is granted access to
+type GroupPath struct {
+	Prot     Prot   `json:"prot"`
+	Via      string `json:"via,omitempty"` // The parent group named on the line, empty for the group itself
+	Perm     string `json:"perm"`          // Highest permission left after exclusions
+	Excluded Prots  `json:"excluded"`      // Later lines that take away some or all of the path
+}
+
+// ParentGroups lists the groups that have the group as a subgroup, directly or not
+func ParentGroups(p4r P4Runner, group string) ([]string, error) {
+	return groups(p4r, []string{"groups", "-i", "-g", group})
+}
+
+// Paths collects every line of the protections table that grants the group
+// access, either naming it or one of its parent groups
+// The protections must be the whole table, see Protections
+func (ps Prots) Paths(p4r P4Runner, group string) ([]GroupPath, error) {
+	parents, err := ParentGroups(p4r, group)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{group: ""}
+	for _, p := range parents {
+		names[p] = p
+	}
+
+	out := []GroupPath{}
+	for i, p := range ps {
+		via, ok := names[p.User]
+		if !p.IsGroup || !ok || p.Unmap {
+			continue
+		}
+		gp := GroupPath{Prot: p, Via: via, Excluded: Prots{}}
+		// Exclusions only count if they come later in the table
+		for _, u := range ps[i+1:] {
+			if !u.Unmap || !within(u.DepotFile, p.DepotFile) {
+				continue
+			}
+			if _, ok := names[u.User]; (u.IsGroup && ok) || (!u.IsGroup && u.User == "*") {
+				gp.Excluded = append(gp.Excluded, u)
+			}
+		}
+		res, err := p4r.Run([]string{"protects", "-M", "-g", group, p.DepotFile})
+		if err != nil {
+			return nil, err
+		}
+		gp.Perm = "none"
+		if len(res) > 0 {
+			if v, ok := res[0]["permMax"]; ok {
+				gp.Perm = v.(string)
+			}
+		}
+		out = append(out, gp)
+	}
+	return out, nil
+}
+
+// within checks whether path overlaps the area of the protections path
+// This only understands trailing ... and * wildcards, which covers most tables
+func within(path, area string) bool {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(area, "..."), "*")
+	if prefix == area {
+		return path == area
+	}
+	return strings.HasPrefix(path, prefix)
+}
+
+// PathPattern turns a p4 wildcard path into a regexp, ... matches
+// anything and * anything but a /
+func PathPattern(p string) *regexp.Regexp {
+	parts := strings.Split(p, "...")
+	for i, part := range parts {
+		sub := strings.Split(part, "*")
+		for j := range sub {
+			sub[j] = regexp.QuoteMeta(sub[j])
+		}
+		parts[i] = strings.Join(sub, "[^/]*")
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
@@ -0,0 +1,55 @@
+package prots
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithin(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(within("//depot/Jam/REL/...", "//depot/..."))
+	assert.True(within("//depot/Jam/file.c", "//depot/Jam/*"))
+	assert.True(within("//depot/Jam/file.c", "//depot/Jam/file.c"))
+	assert.False(within("//other/...", "//depot/..."))
+	assert.False(within("//depot/Jam/file.h", "//depot/Jam/file.c"))
+}
+
+func TestPaths(t *testing.T) {
+	ps := Prots{
+		{Perm: "write", Host: "*", User: "grp", IsGroup: true, Line: 1, DepotFile: "//depot/..."},
+		{Perm: "write", Host: "*", User: "grp", IsGroup: true, Line: 2, DepotFile: "//depot/secret/...", Unmap: true},
+		{Perm: "read", Host: "*", User: "parent", IsGroup: true, Line: 3, DepotFile: "//other/..."},
+		{Perm: "read", Host: "*", User: "unrelated", IsGroup: true, Line: 4, DepotFile: "//other/..."},
+		{Perm: "read", Host: "*", User: "unrelated", IsGroup: true, Line: 5, DepotFile: "//other/x/...", Unmap: true},
+		{Perm: "read", Host: "*", User: "grp", IsGroup: true, Line: 6, DepotFile: "//other/..."},
+	}
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"groups", "-i", "-g", "grp"}).Return(
+		[]map[interface{}]interface{}{{"group": "parent"}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "grp", "//depot/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "grp", "//other/..."}).Return(
+		[]map[interface{}]interface{}{{"permMax": "read"}}, nil)
+
+	res, err := ps.Paths(fp4, "grp")
+	assert.Nil(t, err)
+	assert.Equal(t, []GroupPath{
+		{Prot: ps[0], Perm: "write", Excluded: Prots{ps[1]}},
+		{Prot: ps[2], Via: "parent", Perm: "read", Excluded: Prots{}},
+		{Prot: ps[5], Perm: "read", Excluded: Prots{}},
+	}, res)
+}
+
+func TestPathPattern(t *testing.T) {
+	for pattern, paths := range map[string]map[string]bool{
+		"//depot/export/...": {"//depot/export/...": true, "//depot/export/a/b.c": true, "//depot/exported/...": false},
+		"//depot/*/MAIN/...": {"//depot/Jam/MAIN/...": true, "//depot/Jam/REL/MAIN/...": false},
+		"//depot/a+b.c":      {"//depot/a+b.c": true, "//depot/aab.c": false},
+	} {
+		re := PathPattern(pattern)
+		for p, want := range paths {
+			assert.Equal(t, want, re.MatchString(p), pattern+" "+p)
+		}
+	}
+}
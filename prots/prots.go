@@ -1,9 +1,11 @@
 package prots
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
+	"os/exec"
 	"regexp"
 	"sort"
 	"strconv"
@@ -19,9 +21,18 @@ type P4Runner interface {
 	Run([]string) ([]map[interface{}]interface{}, error)
 }
 
+// P4Writer can also change things, by feeding a spec to a command like 'p4 group -i'
+type P4Writer interface {
+	P4Runner
+	Input(args []string, spec string) (string, error)
+}
+
 // P4C Is a wrapper around the p4 connection
 type P4C struct {
 	p4.P4
+	port   string
+	user   string
+	client string
 }
 
 // NewP4C connects to p4 and returns a P4C wrapper
@@ -31,7 +42,31 @@ func NewP4C() *P4C {
 
 // NewP4CParams TODO This needs to read from .p4config files
 func NewP4CParams(c config.Config) *P4C {
-	return &P4C{P4: *p4.NewP4Params(c.P4Port, c.P4User, c.P4Client)}
+	return &P4C{P4: *p4.NewP4Params(c.P4Port, c.P4User, c.P4Client), port: c.P4Port, user: c.P4User, client: c.P4Client}
+}
+
+// Input runs a p4 command with spec on stdin, returning what p4 printed
+// go-libp4's Save doesn't pass on the port or user, so we run p4 ourselves
+func (p *P4C) Input(args []string, spec string) (string, error) {
+	opts := []string{}
+	if p.port != "" {
+		opts = append(opts, "-p", p.port)
+	}
+	if p.user != "" {
+		opts = append(opts, "-u", p.user)
+	}
+	if p.client != "" {
+		opts = append(opts, "-c", p.client)
+	}
+	cmd := exec.Command("p4", append(opts, args...)...)
+	cmd.Stdin = strings.NewReader(spec)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("p4 %s failed, %v\n%s", strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
 }
 
 // permMap maps permission levels to their hex value
@@ -53,14 +88,14 @@ func init() {
 
 // Prot is a single line of a protections table
 type Prot struct {
-	Perm      string
-	Unmap     bool
-	Host      string
-	User      string
-	IsGroup   bool
-	Line      int
-	DepotFile string
-	Segments  int
+	Perm      string `json:"perm"`
+	Unmap     bool   `json:"unmap"`
+	Host      string `json:"host"`
+	User      string `json:"user"`
+	IsGroup   bool   `json:"isGroup"`
+	Line      int    `json:"line"`
+	DepotFile string `json:"depotFile"`
+	Segments  int    `json:"-"`
 }
 
 // Prots is a set of protections
@@ -68,45 +103,69 @@ type Prots []Prot
 
 // Owner represents the username and password of a group owner
 type Owner struct {
-	User     string
-	FullName string
-	Email    string
+	User     string `json:"user"`
+	FullName string `json:"fullName"`
+	Email    string `json:"email"`
+	Status   string `json:"status,omitempty"` // Set by Classify, active, stale, missing or non-human
 }
 
 // owners returns the owners for a given prots group
 func (p Prot) owners(p4r P4Runner) ([]Owner, error) {
+	owners, _, err := p.group(p4r)
+	return owners, err
+}
+
+// group returns the owners for a given prots group, and how LDAP fills it
+// when 'p4 ldapsync' does
+func (p Prot) group(p4r P4Runner) ([]Owner, *Ldap, error) {
 	res, err := p4r.Run([]string{"group", "-o", p.User})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	i := 0
 	out := []Owner{}
-	// There is an indeterminate amount of OwnersX: owner.name
-	// so we have to just try them all until we run out
-	for {
-		key := fmt.Sprintf("Owners%d", i)
-		if v, ok := res[0][key]; ok {
-			user := v.(string)
-			// We have the username, find their email address
-			ures, err := p4r.Run([]string{"user", "-o", user})
-			if err != nil {
-				return nil, err
-			}
-			var fullname, email string
-			if uv, ok := ures[0]["Email"]; ok {
-				email = uv.(string)
-			}
-			if uv, ok := ures[0]["FullName"]; ok {
-				fullname = uv.(string)
-			}
-			out = append(out, Owner{user, fullname, email})
-			i++
-		} else {
-			break
+	for _, user := range listField(res[0], "Owners") {
+		// We have the username, find their email address
+		o, err := GetUser(p4r, user)
+		if err != nil {
+			return nil, nil, err
 		}
+		out = append(out, o)
 	}
-	return out, nil
+	return out, ldapOf(res[0]), nil
+}
+
+// UserType is a user's Type, standard, operator or service
+func UserType(p4r P4Runner, user string) (string, error) {
+	res, err := p4r.Run([]string{"user", "-o", user})
+	if err != nil {
+		return "", err
+	}
+	if len(res) > 0 {
+		if v, ok := res[0]["Type"]; ok {
+			return v.(string), nil
+		}
+	}
+	return "standard", nil
+}
+
+// GetUser looks up a user's full name and email address
+func GetUser(p4r P4Runner, user string) (Owner, error) {
+	ures, err := p4r.Run([]string{"user", "-o", user})
+	if err != nil {
+		return Owner{}, err
+	}
+	o := Owner{User: user}
+	if len(ures) == 0 {
+		return o, nil
+	}
+	if uv, ok := ures[0]["Email"]; ok {
+		o.Email = uv.(string)
+	}
+	if uv, ok := ures[0]["FullName"]; ok {
+		o.FullName = uv.(string)
+	}
+	return o, nil
 }
 
 func segments(path string) int {
@@ -138,9 +197,13 @@ func parseError(res map[interface{}]interface{}) error {
 	return err
 }
 
-// Protections takes a path in p4 depot syntax
+// Protections takes a path in p4 depot syntax, an empty path returns the whole table
 func Protections(p4r P4Runner, path string) (Prots, error) {
-	res, err := p4r.Run([]string{"protects", "-a", path})
+	args := []string{"protects", "-a"}
+	if path != "" {
+		args = append(args, path)
+	}
+	res, err := p4r.Run(args)
 	if err != nil {
 		log.Printf("Failed to get protects for %s\nRes: %v\nErr: %v\n", path, res, err)
 	}
@@ -181,30 +244,59 @@ func Protections(p4r P4Runner, path string) (Prots, error) {
 	return prots, err
 }
 
-// Info is the path and owners of a group
+// ForGroup returns the lines that name the given group, in table order
+func (ps Prots) ForGroup(group string) Prots {
+	out := Prots{}
+	for _, p := range ps {
+		if p.IsGroup && p.User == group {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Info is the path and owners of a group, along with the protections line
+// that grants the access
 type Info struct {
-	Path   string
-	Access string
-	Group  string
-	Owners []Owner
+	Path   string  `json:"path"`
+	Access string  `json:"access"`
+	Group  string  `json:"group"`
+	Owners []Owner `json:"owners"`
+	Prot   Prot    `json:"prot"`
+	Note   string  `json:"note,omitempty"` // Anything else the user should know, e.g. from a policy
+	// Unowned is set when none of the owners can approve, see Classify
+	Unowned bool `json:"unowned,omitempty"`
+	// Contacts are who to ask instead when the group is unowned, and
+	// Fallback says where they came from, e.g. the owner of depot //jam
+	Fallback string  `json:"fallback,omitempty"`
+	Contacts []Owner `json:"contacts,omitempty"`
+	// Annotation is what the annotations file says about the group, if anything
+	Annotation *Annotation `json:"annotation,omitempty"`
+	// Ldap is set when the group's members come from LDAP
+	Ldap *Ldap `json:"ldap,omitempty"`
+}
+
+// Annotation describes a group for people who only see its name
+type Annotation struct {
+	Description string `json:"description,omitempty"`
+	Team        string `json:"team,omitempty"`
+	RequestURL  string `json:"requestURL,omitempty"`  // Where to ask for access instead of p4 access request
+	Chat        string `json:"chat,omitempty"`        // A channel to ask questions in
+	SelfService bool   `json:"selfService,omitempty"` // Anyone can join the group themselves
 }
 
-// OutputInfo prepares the output for use in a template
+// OutputInfo prepares the output for use in a template, leaving out
+// groups without owners
 func (adv *Advice) OutputInfo(p4r P4Runner, path, reqAccess string) ([]Info, error) {
+	infos, err := adv.AllInfo(p4r, path, reqAccess)
+	if err != nil {
+		return nil, err
+	}
 	out := []Info{}
-	for _, p := range adv.Ps {
-		owners, err := p.owners(p4r)
-		if err != nil {
-			return nil, err
-		}
-		// Don't report on ownerless groups
-		if len(owners) > 0 {
-			out = append(out, Info{
-				path,
-				reqAccess,
-				p.User,
-				owners,
-			})
+	for _, i := range infos {
+		// Don't report on ownerless groups, unless LDAP says who is in them
+		if len(i.Owners) > 0 || i.Ldap != nil {
+			out = append(out, i)
 		}
 	}
 	if len(out) == 0 {
@@ -213,8 +305,29 @@ func (adv *Advice) OutputInfo(p4r P4Runner, path, reqAccess string) ([]Info, err
 	return out, nil
 }
 
+// AllInfo prepares the output for every group, owned or not, so that
+// someone else can be found to ask about the ownerless ones
+func (adv *Advice) AllInfo(p4r P4Runner, path, reqAccess string) ([]Info, error) {
+	out := []Info{}
+	for _, p := range adv.Ps {
+		owners, ldap, err := p.group(p4r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Info{
+			Path:   path,
+			Access: reqAccess,
+			Group:  p.User,
+			Owners: owners,
+			Prot:   p,
+			Ldap:   ldap,
+		})
+	}
+	return out, nil
+}
+
 // filterProts filters the output Prots from 'p4 protects' for those that pertain to the the request
-func (ps *Prots) filter(p4r P4Runner, path, reqAccess string) (Prots, error) {
+func (ps *Prots) filter(p4r P4Runner, cl Client, path, reqAccess string) (Prots, error) {
 	out := Prots{}
 
 	// read can be read or open, write is just write
@@ -240,9 +353,14 @@ func (ps *Prots) filter(p4r P4Runner, path, reqAccess string) (Prots, error) {
 			continue
 		}
 
+		// Lines for other hosts won't help the user where they are
+		if !cl.matchesHost(c.Host) {
+			continue
+		}
+
 		// Check that the group actually gives the correct access
 		if permMap[c.Perm] >= minA && permMap[c.Perm] <= maxA {
-			res, err := p4r.Run([]string{"protects", "-M", "-g", c.User, path})
+			res, err := p4r.Run(append(cl.hostArgs([]string{"protects", "-M", "-g", c.User}), path))
 			if err != nil {
 				return nil, err
 			}
@@ -284,13 +402,24 @@ type Advice struct {
 
 // Advise running user on probable group to join
 // Returns one or more possible protections in order of how likely they are correct
-func (ps *Prots) Advise(p4r P4Runner, user, path, reqAccess string) (*Advice, error) {
+// The client is used to honour host based protections, pass Client{} to ignore hosts
+func (ps *Prots) Advise(p4r P4Runner, cl Client, user, path, reqAccess string) (*Advice, error) {
+	return ps.advise(p4r, cl, user, path, reqAccess, false)
+}
+
+// AdviseAll is like Advise, but returns every matching protection rather than
+// only those from the most specific tier
+func (ps *Prots) AdviseAll(p4r P4Runner, cl Client, user, path, reqAccess string) (*Advice, error) {
+	return ps.advise(p4r, cl, user, path, reqAccess, true)
+}
+
+func (ps *Prots) advise(p4r P4Runner, cl Client, user, path, reqAccess string, all bool) (*Advice, error) {
 	ctx := ""
 	if reqAccess != "read" && reqAccess != "write" {
 		return nil, errors.New("Must request either read or write access")
 	}
 
-	a, err := hasAccess(p4r, user, path, reqAccess)
+	a, err := hasAccess(p4r, cl, user, path, reqAccess)
 	if err != nil {
 		return nil, err
 	} else if a {
@@ -298,11 +427,17 @@ func (ps *Prots) Advise(p4r P4Runner, user, path, reqAccess string) (*Advice, er
 	}
 
 	// Filter the prots for those that matter
-	psf, err := ps.filter(p4r, path, reqAccess)
+	psf, err := ps.filter(p4r, cl, path, reqAccess)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to filter %v", err)
 	}
+	if len(psf) == 0 {
+		return nil, errors.New("No matching groups found, try again with a more specific path")
+	}
 	psf = psf.sort(path)
+	if all {
+		return &Advice{psf, ctx}, nil
+	}
 	l := psf[0].Segments
 	out := Prots{psf[0]}
 
@@ -316,9 +451,40 @@ func (ps *Prots) Advise(p4r P4Runner, user, path, reqAccess string) (*Advice, er
 	return &Advice{out, ctx}, nil
 }
 
+// IsAdmin checks whether the given user has admin or super access
+func IsAdmin(p4r P4Runner, user string) (bool, error) {
+	res, err := p4r.Run([]string{"protects", "-M", "-u", user, "//..."})
+	if err != nil {
+		return false, err
+	}
+	if len(res) == 0 {
+		return false, nil
+	}
+	if v, ok := res[0]["permMax"]; ok {
+		perm := v.(string)
+		return perm == "admin" || perm == "super", nil
+	}
+	return false, nil
+}
+
+// GroupHasAccess checks whether the group gives reqAccess or higher to path
+func GroupHasAccess(p4r P4Runner, group, path, reqAccess string) (bool, error) {
+	res, err := p4r.Run([]string{"protects", "-M", "-g", group, path})
+	if err != nil {
+		return false, err
+	}
+	if len(res) == 0 {
+		return false, nil
+	}
+	if v, ok := res[0]["permMax"]; ok {
+		return permMap[v.(string)] >= permMap[reqAccess], nil
+	}
+	return false, nil
+}
+
 // hasAccess checks whether the given user already has access
-func hasAccess(p4r P4Runner, user, path, reqAccess string) (bool, error) {
-	res, err := p4r.Run([]string{"protects", "-M", "-u", user, path})
+func hasAccess(p4r P4Runner, cl Client, user, path, reqAccess string) (bool, error) {
+	res, err := p4r.Run(append(cl.hostArgs([]string{"protects", "-M", "-u", user}), path))
 	if err != nil {
 		return false, err
 	}
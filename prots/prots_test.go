@@ -19,6 +19,12 @@ func (mock *FakeP4Runner) Run(args []string) ([]map[interface{}]interface{}, err
 	return ags.Get(0).([]map[interface{}]interface{}), ags.Error(1)
 }
 
+// Mocks p4 commands that read a spec, e.g. 'p4 group -i'
+func (mock *FakeP4Runner) Input(args []string, spec string) (string, error) {
+	ags := mock.Called(args, spec)
+	return ags.String(0), ags.Error(1)
+}
+
 type parseErrorTest struct {
 	input map[interface{}]interface{}
 	want  error
@@ -221,7 +227,7 @@ func TestHasAccess(t *testing.T) {
 	for _, tst := range accessTests {
 		fp4 := &FakeP4Runner{}
 		fp4.On("Run", []string{"protects", "-M", "-u", tst.input.user, tst.input.path}).Return(tst.input.retAccess, tst.err)
-		res, err := hasAccess(fp4, tst.input.user, tst.input.path, tst.input.reqAccess)
+		res, err := hasAccess(fp4, Client{}, tst.input.user, tst.input.path, tst.input.reqAccess)
 		assert := assert.New(t)
 		if tst.err == nil {
 			assert.Nil(err)
@@ -381,7 +387,7 @@ func TestFilter(t *testing.T) {
 			On("Run", []string{"protects", "-M", "-g", "grp2", "//depot/unmapped"}).Return(pwrite, nil)
 		// TODO check that the group gives the correct access? or are we sure already
 		// fp4.On("Run", []string{"protects", "-M", "-g", tst.input.user, tst.input.path}).Return("super", nil)
-		res, err := tst.input.prots.filter(fp4, tst.input.path, tst.input.reqAccess)
+		res, err := tst.input.prots.filter(fp4, Client{}, tst.input.path, tst.input.reqAccess)
 		assert := assert.New(t)
 		if tst.err == nil {
 			assert.Nil(err)
@@ -854,7 +860,7 @@ func TestAdvise(t *testing.T) {
 			On("Run", []string{"protects", "-M", "-g", "grp2", tst.input.path}).Return(pwrite, nil)
 		// TODO check that the group gives the correct access? or are we sure already
 		// fp4.On("Run", []string{"protects", "-M", "-g", tst.input.user, tst.input.path}).Return("super", nil)
-		res, err := tst.input.prots.Advise(fp4, tst.input.user, tst.input.path, tst.input.reqAccess)
+		res, err := tst.input.prots.Advise(fp4, Client{}, tst.input.user, tst.input.path, tst.input.reqAccess)
 		assert := assert.New(t)
 		if tst.err == nil {
 			assert.Nil(err)
@@ -867,6 +873,86 @@ func TestAdvise(t *testing.T) {
 	}
 }
 
+func TestAdviseAll(t *testing.T) {
+	// Every tier should come back, most specific first
+	ps := Prots{
+		{
+			Perm:      "write",
+			Host:      "host",
+			User:      "grp",
+			IsGroup:   true,
+			Line:      1,
+			DepotFile: "//...",
+			Segments:  1,
+		},
+		{
+			Perm:      "write",
+			Host:      "host",
+			User:      "grp2",
+			IsGroup:   true,
+			Line:      2,
+			DepotFile: "//depot/...",
+			Segments:  2,
+		},
+	}
+	fp4 := &FakeP4Runner{}
+	pnone := []map[interface{}]interface{}{{"permMax": "none"}}
+	pwrite := []map[interface{}]interface{}{{"permMax": "write"}}
+	fp4.On("Run", []string{"protects", "-M", "-u", "usr", "//depot/path"}).Return(pnone, nil).
+		On("Run", []string{"protects", "-M", "-g", "grp", "//depot/path"}).Return(pwrite, nil).
+		On("Run", []string{"protects", "-M", "-g", "grp2", "//depot/path"}).Return(pwrite, nil)
+
+	assert := assert.New(t)
+	res, err := ps.AdviseAll(fp4, Client{}, "usr", "//depot/path", "write")
+	assert.Nil(err)
+	assert.Equal(&Advice{Prots{ps[1], ps[0]}, ""}, res)
+
+	res, err = ps.Advise(fp4, Client{}, "usr", "//depot/path", "write")
+	assert.Nil(err)
+	assert.Equal(&Advice{Prots{ps[1]}, ""}, res)
+}
+
+func TestAdviseNoGroups(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	pnone := []map[interface{}]interface{}{{"permMax": "none"}}
+	fp4.On("Run", []string{"protects", "-M", "-u", "usr", "//depot/path"}).Return(pnone, nil)
+	ps := Prots{}
+	res, err := ps.Advise(fp4, Client{}, "usr", "//depot/path", "write")
+	assert.Nil(t, res)
+	assert.EqualError(t, err, "No matching groups found, try again with a more specific path")
+}
+
+func TestIsAdmin(t *testing.T) {
+	for perm, want := range map[string]bool{"super": true, "admin": true, "write": false, "none": false} {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"protects", "-M", "-u", "usr", "//..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": perm}}, nil)
+		res, err := IsAdmin(fp4, "usr")
+		assert.Nil(t, err)
+		assert.Equal(t, want, res, perm)
+	}
+}
+
+func TestIsAdminEmpty(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"protects", "-M", "-u", "usr", "//..."}).Return(
+		[]map[interface{}]interface{}{}, nil)
+	res, err := IsAdmin(fp4, "usr")
+	assert.Nil(t, err)
+	assert.False(t, res)
+}
+
+func TestGroupHasAccess(t *testing.T) {
+	for perm, want := range map[string]bool{"super": true, "write": true, "open": false, "read": false, "none": false} {
+		fp4 := &FakeP4Runner{}
+		fp4.On("Run", []string{"protects", "-M", "-g", "grp", "//depot/..."}).Return(
+			[]map[interface{}]interface{}{{"permMax": perm}}, nil)
+		res, err := GroupHasAccess(fp4, "grp", "//depot/...", "write")
+		assert.Nil(t, err)
+		assert.Equal(t, want, res, perm)
+	}
+}
+
 func TestOwners(t *testing.T) {
 	tst := []map[interface{}]interface{}{{
 		"code":            "stat",
@@ -922,8 +1008,8 @@ func TestOwners(t *testing.T) {
 	assert := assert.New(t)
 	assert.Nil(err)
 	assert.Equal([]Owner{
-		{"owner.first", "Owner First", "owner.first@p4access.com"},
-		{"owner.second", "Owner Second", "owner.second@p4access.com"}}, res)
+		{"owner.first", "Owner First", "owner.first@p4access.com", ""},
+		{"owner.second", "Owner Second", "owner.second@p4access.com", ""}}, res)
 }
 
 type testGroup struct {
@@ -947,7 +1033,7 @@ type outputInfoTest struct {
 var outputInfoTests = []outputInfoTest{
 	{
 		outputInfoInput{
-			testGroup{"g1", []Owner{{"o1", "o o", "o@o.o"}}},
+			testGroup{"g1", []Owner{{"o1", "o o", "o@o.o", ""}}},
 			"//depot/...",
 			"write",
 			Advice{Prots{
@@ -969,8 +1055,24 @@ var outputInfoTests = []outputInfoTest{
 				"write",
 				"g1",
 				[]Owner{
-					{"o1", "o o", "o@o.o"},
+					{"o1", "o o", "o@o.o", ""},
+				},
+				Prot{
+					Perm:      "write",
+					Host:      "host",
+					User:      "g1",
+					IsGroup:   true,
+					Line:      1,
+					DepotFile: "//depot/...",
+					Unmap:     false,
+					Segments:  2,
 				},
+				"",
+				false,
+				"",
+				nil,
+				nil,
+				nil,
 			},
 		},
 		nil,
@@ -979,8 +1081,8 @@ var outputInfoTests = []outputInfoTest{
 		// Multiple owners
 		outputInfoInput{
 			testGroup{"g1", []Owner{
-				{"o1", "o o", "o@o.o"},
-				{"o2", "o 2", "o2@o.o"},
+				{"o1", "o o", "o@o.o", ""},
+				{"o2", "o 2", "o2@o.o", ""},
 			}},
 			"//depot/...",
 			"write",
@@ -1003,9 +1105,25 @@ var outputInfoTests = []outputInfoTest{
 				"write",
 				"g1",
 				[]Owner{
-					{"o1", "o o", "o@o.o"},
-					{"o2", "o 2", "o2@o.o"},
+					{"o1", "o o", "o@o.o", ""},
+					{"o2", "o 2", "o2@o.o", ""},
 				},
+				Prot{
+					Perm:      "write",
+					Host:      "host",
+					User:      "g1",
+					IsGroup:   true,
+					Line:      1,
+					DepotFile: "//depot/...",
+					Unmap:     false,
+					Segments:  2,
+				},
+				"",
+				false,
+				"",
+				nil,
+				nil,
+				nil,
 			},
 		},
 		nil,
@@ -1057,3 +1175,34 @@ func TestOutputInfo(t *testing.T) {
 	}
 
 }
+
+func TestAllInfo(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "g1"}).Return([]map[interface{}]interface{}{{}}, nil)
+	adv := Advice{Prots{{Perm: "write", Host: "*", User: "g1", IsGroup: true, Line: 1, DepotFile: "//depot/...", Segments: 2}}, ""}
+	// Ownerless groups are kept, OutputInfo leaves them out
+	res, err := adv.AllInfo(fp4, "//depot/...", "write")
+	assert.Nil(err)
+	assert.Len(res, 1)
+	assert.Equal("g1", res[0].Group)
+	assert.Empty(res[0].Owners)
+	_, err = adv.OutputInfo(fp4, "//depot/...", "write")
+	assert.EqualError(err, "No matching groups found, try again with a more specific path")
+}
+
+func TestAllInfoLdap(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "g1"}).Return([]map[interface{}]interface{}{{
+		"Group": "g1", "LdapConfig": "corp", "LdapSearchQuery": "(cn=jam)",
+	}}, nil)
+	adv := Advice{Prots{{Perm: "write", Host: "*", User: "g1", IsGroup: true, Line: 1, DepotFile: "//depot/...", Segments: 2}}, ""}
+	res, err := adv.AllInfo(fp4, "//depot/...", "write")
+	assert.Nil(err)
+	assert.Equal(&Ldap{Config: "corp", SearchQuery: "(cn=jam)"}, res[0].Ldap)
+	// LDAP groups are kept without owners, LDAP says who is in them
+	res, err = adv.OutputInfo(fp4, "//depot/...", "write")
+	assert.Nil(err)
+	assert.Len(res, 1)
+}
@@ -0,0 +1,132 @@
+package prots
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Account is a user as 'p4 users -a' lists them
+type Account struct {
+	User     string    `json:"user"`
+	FullName string    `json:"fullName"`
+	Email    string    `json:"email"`
+	Type     string    `json:"type"`   // standard, operator or service
+	Access   time.Time `json:"access"` // When they last ran a command, zero if never
+}
+
+// Accounts reads the named users, including operator and service users,
+// keyed by the names asked for, those without a user spec are left out
+func Accounts(p4r P4Runner, users []string) (map[string]Account, error) {
+	out := map[string]Account{}
+	if len(users) == 0 {
+		return out, nil
+	}
+	res, err := p4r.Run(append([]string{"users", "-a"}, users...))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range res {
+		u, ok := r["User"].(string)
+		if !ok {
+			continue
+		}
+		// Case insensitive servers give back the spec's own spelling
+		for _, name := range users {
+			if strings.EqualFold(name, u) {
+				u = name
+				break
+			}
+		}
+		a := Account{User: u, Type: "standard"}
+		if v, ok := r["FullName"].(string); ok {
+			a.FullName = v
+		}
+		if v, ok := r["Email"].(string); ok {
+			a.Email = v
+		}
+		if v, ok := r["Type"].(string); ok {
+			a.Type = v
+		}
+		a.Access = epoch(r["Access"])
+		out[u] = a
+	}
+	return out, nil
+}
+
+// LastChange finds when the user last submitted a change under path, zero if never
+func LastChange(p4r P4Runner, user, path string) (time.Time, error) {
+	res, err := p4r.Run([]string{"changes", "-m1", "-s", "submitted", "-u", user, path})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(res) == 0 {
+		return time.Time{}, nil
+	}
+	return epoch(res[0]["time"]), nil
+}
+
+// CanWrite checks whether a permission allows submitting
+func CanWrite(p string) bool {
+	return perm(p) >= permMap["write"]
+}
+
+// epoch reads the seconds since 1970 that p4 gives times as
+func epoch(v interface{}) time.Time {
+	s, _ := v.(string)
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(n, 0).UTC()
+}
+
+// What Classify finds an owner to be, only active owners are any use for approving
+const (
+	Active   = "active"
+	Stale    = "stale"     // Hasn't used the server since the cutoff, or ever
+	Missing  = "missing"   // Has no user spec, p4 user -o makes one up
+	NonHuman = "non-human" // An operator or service account
+)
+
+// Usable checks whether the owner can be asked for access
+// Owners that were never classified are assumed to be
+func (o Owner) Usable() bool {
+	return o.Status == "" || o.Status == Active
+}
+
+// Classify sets the Status of each owner from the accounts, usable owners are
+// moved to the front and the rest keep their order after them
+func Classify(owners []Owner, accounts map[string]Account, since time.Time) []Owner {
+	usable, unusable := []Owner{}, []Owner{}
+	for _, o := range owners {
+		a, ok := accounts[o.User]
+		switch {
+		case !ok:
+			o.Status = Missing
+		case a.Type == "operator" || a.Type == "service":
+			o.Status = NonHuman
+		case a.Access.IsZero() || a.Access.Before(since):
+			o.Status = Stale
+		default:
+			o.Status = Active
+		}
+		if o.Usable() {
+			usable = append(usable, o)
+		} else {
+			unusable = append(unusable, o)
+		}
+	}
+	return append(usable, unusable...)
+}
+
+// UsableOwners keeps only the owners that can be asked for access
+func UsableOwners(owners []Owner) []Owner {
+	out := []Owner{}
+	for _, o := range owners {
+		if o.Usable() {
+			out = append(out, o)
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package prots
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccounts(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"users", "-a", "a.user", "build", "Old.User", "gone.user"}).Return([]map[interface{}]interface{}{
+		{"User": "a.user", "Email": "a.user@email.com", "FullName": "A User", "Type": "standard", "Access": "1615284000"},
+		{"User": "build", "Email": "build@email.com", "FullName": "Build", "Type": "service", "Access": "0"},
+		{"User": "old.user", "Email": "old.user@email.com", "FullName": "Old User"},
+		{"code": "error", "data": "gone.user - no such user(s).\n"},
+	}, nil)
+	as, err := Accounts(fp4, []string{"a.user", "build", "Old.User", "gone.user"})
+	assert.Nil(err)
+	assert.Len(as, 3)
+	assert.Equal(Account{"a.user", "A User", "a.user@email.com", "standard", time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)}, as["a.user"])
+	assert.Equal("service", as["build"].Type)
+	assert.True(as["build"].Access.IsZero())
+	// Keyed by the name asked for, and older servers leave the type out for standard users
+	assert.Equal("standard", as["Old.User"].Type)
+	_, ok := as["gone.user"]
+	assert.False(ok)
+
+	// Nobody to look up doesn't list every user
+	as, err = Accounts(fp4, nil)
+	assert.Nil(err)
+	assert.Empty(as)
+	fp4.AssertNumberOfCalls(t, "Run", 1)
+}
+
+func TestLastChange(t *testing.T) {
+	assert := assert.New(t)
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"changes", "-m1", "-s", "submitted", "-u", "a.user", "//depot/Jam/..."}).Return(
+		[]map[interface{}]interface{}{{"change": "12", "time": "1615284000", "user": "a.user"}}, nil).
+		On("Run", []string{"changes", "-m1", "-s", "submitted", "-u", "b.user", "//depot/Jam/..."}).Return(
+		[]map[interface{}]interface{}{}, nil)
+	last, err := LastChange(fp4, "a.user", "//depot/Jam/...")
+	assert.Nil(err)
+	assert.Equal(time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC), last)
+	last, err = LastChange(fp4, "b.user", "//depot/Jam/...")
+	assert.Nil(err)
+	assert.True(last.IsZero())
+}
+
+func TestCanWrite(t *testing.T) {
+	assert.True(t, CanWrite("write"))
+	assert.True(t, CanWrite("=write"))
+	assert.True(t, CanWrite("super"))
+	assert.False(t, CanWrite("read"))
+	assert.False(t, CanWrite("none"))
+}
+
+func TestClassify(t *testing.T) {
+	assert := assert.New(t)
+	since := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	accounts := map[string]Account{
+		"old.owner":   {User: "old.owner", Type: "standard", Access: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)},
+		"new.owner":   {User: "new.owner", Type: "standard", Access: time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)},
+		"never.owner": {User: "never.owner", Type: "standard"},
+		"build":       {User: "build", Type: "service", Access: time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)},
+		"other.owner": {User: "other.owner", Type: "standard", Access: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	owners := []Owner{
+		{User: "old.owner"}, {User: "gone.owner"}, {User: "new.owner"},
+		{User: "never.owner"}, {User: "build"}, {User: "other.owner"},
+	}
+	out := Classify(owners, accounts, since)
+	assert.Equal([]Owner{
+		{User: "new.owner", Status: Active},
+		{User: "other.owner", Status: Active},
+		{User: "old.owner", Status: Stale},
+		{User: "gone.owner", Status: Missing},
+		{User: "never.owner", Status: Stale},
+		{User: "build", Status: NonHuman},
+	}, out)
+	assert.Equal([]Owner{{User: "new.owner", Status: Active}, {User: "other.owner", Status: Active}}, UsableOwners(out))
+	// The owners passed in are left alone
+	assert.Empty(owners[0].Status)
+	assert.True(Owner{User: "unchecked"}.Usable())
+}
@@ -0,0 +1,175 @@
+package prots
+
+import (
+	"sort"
+	"strings"
+)
+
+// Grant is a user or group that has access to a path
+type Grant struct {
+	Name    string   `json:"name"`
+	IsGroup bool     `json:"isGroup"`
+	Perm    string   `json:"perm"`             // Highest permission on the path, after exclusions
+	Members int      `json:"members"`          // Users in a group, including subgroups
+	Owners  []string `json:"owners,omitempty"` // Owners of a group
+	Prots   Prots    `json:"prots"`            // The lines that name the user or group
+}
+
+// perm returns the value of a protections permission, =perm rights count
+// as the permission they name
+func perm(p string) uint8 {
+	return permMap[strings.TrimPrefix(p, "=")]
+}
+
+// Who lists every group and user that has at least reqAccess to path
+// The protections must come from Protections for the same path, each
+// candidate is then checked with 'p4 protects -M' so exclusions are honoured
+// Wildcard users can't be checked so are listed as they are
+func (ps Prots) Who(p4r P4Runner, path, reqAccess string) ([]Grant, error) {
+	grants := map[string]*Grant{}
+	order := []string{}
+	for _, p := range ps {
+		key := p.User
+		if p.IsGroup {
+			key = "@" + p.User
+		}
+		g, ok := grants[key]
+		if !ok {
+			g = &Grant{Name: p.User, IsGroup: p.IsGroup, Prots: Prots{}}
+			grants[key] = g
+			order = append(order, key)
+		}
+		g.Prots = append(g.Prots, p)
+	}
+
+	out := []Grant{}
+	for _, key := range order {
+		g := grants[key]
+		// Skip anyone whose lines couldn't give the access
+		granted := false
+		for _, p := range g.Prots {
+			if !p.Unmap && perm(p.Perm) >= permMap[reqAccess] {
+				granted = true
+			}
+		}
+		if !granted {
+			continue
+		}
+		if !g.IsGroup && strings.Contains(g.Name, "*") {
+			g.Perm = maxPerm(g.Prots)
+			out = append(out, *g)
+			continue
+		}
+
+		flag := "-u"
+		if g.IsGroup {
+			flag = "-g"
+		}
+		res, err := p4r.Run([]string{"protects", "-M", flag, g.Name, path})
+		if err != nil {
+			return nil, err
+		}
+		g.Perm = "none"
+		if len(res) > 0 {
+			if v, ok := res[0]["permMax"]; ok {
+				g.Perm = v.(string)
+			}
+		}
+		if permMap[g.Perm] < permMap[reqAccess] {
+			continue
+		}
+
+		if g.IsGroup {
+			spec, err := GetGroup(p4r, g.Name)
+			if err != nil {
+				return nil, err
+			}
+			members, err := spec.Members(p4r)
+			if err != nil {
+				return nil, err
+			}
+			g.Members = len(members)
+			g.Owners = spec.Owners
+		}
+		out = append(out, *g)
+	}
+	return out, nil
+}
+
+// OwnsGrant checks whether user owns a group with at least reqAccess to
+// path. Only the group specs are read, so no group's members are expanded
+func (ps Prots) OwnsGrant(p4r P4Runner, user, path, reqAccess string) (bool, error) {
+	seen := map[string]bool{}
+	for _, p := range ps {
+		if !p.IsGroup || p.Unmap || perm(p.Perm) < permMap[reqAccess] || seen[p.User] {
+			continue
+		}
+		seen[p.User] = true
+		spec, err := GetGroup(p4r, p.User)
+		if err != nil {
+			return false, err
+		}
+		owner := false
+		for _, o := range spec.Owners {
+			if o == user {
+				owner = true
+			}
+		}
+		if !owner {
+			continue
+		}
+		ok, err := GroupHasAccess(p4r, p.User, path, reqAccess)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// maxPerm is the highest permission granted by the lines
+func maxPerm(ps Prots) string {
+	max := "none"
+	for _, p := range ps {
+		if !p.Unmap && perm(p.Perm) > permMap[max] {
+			max = strings.TrimPrefix(p.Perm, "=")
+		}
+	}
+	return max
+}
+
+// Members expands the group into every user in it, including those in
+// subgroups, sorted by name
+func (g Group) Members(p4r P4Runner) ([]string, error) {
+	seen := map[string]bool{g.Name: true}
+	users := map[string]bool{}
+	if err := g.members(p4r, seen, users); err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for u := range users {
+		out = append(out, u)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (g Group) members(p4r P4Runner, seen, users map[string]bool) error {
+	for _, u := range g.Users {
+		users[u] = true
+	}
+	for _, s := range g.Subgroups {
+		// Subgroups can loop back on themselves
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		sub, err := GetGroup(p4r, s)
+		if err != nil {
+			return err
+		}
+		if err := sub.members(p4r, seen, users); err != nil {
+			return err
+		}
+	}
+	return nil
+}
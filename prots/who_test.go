@@ -0,0 +1,93 @@
+package prots
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWho(t *testing.T) {
+	ps := Prots{
+		{Perm: "super", Host: "*", User: "perforce", Line: 1, DepotFile: "//..."},
+		{Perm: "read", Host: "*", User: "*", Line: 2, DepotFile: "//depot/..."},
+		{Perm: "write", Host: "*", User: "P_jam_rw", IsGroup: true, Line: 3, DepotFile: "//depot/Jam/..."},
+		{Perm: "=write", Host: "*", User: "P_old_rw", IsGroup: true, Line: 4, DepotFile: "//depot/Jam/..."},
+		{Perm: "write", Host: "*", User: "P_old_rw", IsGroup: true, Line: 5, DepotFile: "//depot/Jam/MAIN/...", Unmap: true},
+		{Perm: "write", Host: "*", User: "a.user", Line: 6, DepotFile: "//depot/Jam/MAIN/..."},
+	}
+	path := "//depot/Jam/MAIN/..."
+	fp4 := &FakeP4Runner{}
+	pwrite := []map[interface{}]interface{}{{"permMax": "write"}}
+	psuper := []map[interface{}]interface{}{{"permMax": "super"}}
+	pnone := []map[interface{}]interface{}{{"permMax": "none"}}
+	fp4.On("Run", []string{"protects", "-M", "-u", "perforce", path}).Return(psuper, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_jam_rw", path}).Return(pwrite, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_old_rw", path}).Return(pnone, nil).
+		On("Run", []string{"protects", "-M", "-u", "a.user", path}).Return(pwrite, nil).
+		On("Run", []string{"group", "-o", "P_jam_rw"}).Return([]map[interface{}]interface{}{{
+		"Owners0":    "owner.first",
+		"Users0":     "a.user",
+		"Users1":     "b.user",
+		"Subgroups0": "P_jam_devs",
+	}}, nil).
+		On("Run", []string{"group", "-o", "P_jam_devs"}).Return([]map[interface{}]interface{}{{
+		"Users0":     "b.user",
+		"Users1":     "c.user",
+		"Subgroups0": "P_jam_rw", // Loops are allowed
+	}}, nil)
+
+	res, err := ps.Who(fp4, path, "write")
+	assert.Nil(t, err)
+	assert.Equal(t, []Grant{
+		{Name: "perforce", Perm: "super", Prots: Prots{ps[0]}},
+		{Name: "P_jam_rw", IsGroup: true, Perm: "write", Members: 3, Owners: []string{"owner.first"}, Prots: Prots{ps[2]}},
+		{Name: "a.user", Perm: "write", Prots: Prots{ps[5]}},
+	}, res)
+
+	// Wildcard users are listed without checking
+	res, err = ps.Who(fp4, path, "read")
+	assert.Nil(t, err)
+	assert.Equal(t, Grant{Name: "*", Perm: "read", Prots: Prots{ps[1]}}, res[1])
+}
+
+func TestMembers(t *testing.T) {
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "sub"}).Return([]map[interface{}]interface{}{{
+		"Users0": "z.user",
+		"Users1": "a.user",
+	}}, nil)
+	g := Group{Name: "top", Users: []string{"m.user", "a.user"}, Subgroups: []string{"sub"}}
+	res, err := g.Members(fp4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.user", "m.user", "z.user"}, res)
+}
+
+func TestOwnsGrant(t *testing.T) {
+	ps := Prots{
+		{Perm: "read", Host: "*", User: "P_jam_ro", IsGroup: true, Line: 1, DepotFile: "//depot/Jam/..."},
+		{Perm: "write", Host: "*", User: "P_jam_rw", IsGroup: true, Line: 2, DepotFile: "//depot/Jam/..."},
+		{Perm: "write", Host: "*", User: "P_old_rw", IsGroup: true, Line: 3, DepotFile: "//depot/Jam/..."},
+	}
+	path := "//depot/Jam/MAIN/..."
+	fp4 := &FakeP4Runner{}
+	fp4.On("Run", []string{"group", "-o", "P_jam_rw"}).Return([]map[interface{}]interface{}{{
+		"Owners0": "owner.first",
+		"Users0":  "a.user",
+	}}, nil).
+		On("Run", []string{"group", "-o", "P_old_rw"}).Return([]map[interface{}]interface{}{{
+		"Owners0": "owner.old",
+		"Users0":  "a.user",
+	}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_jam_rw", path}).Return(
+		[]map[interface{}]interface{}{{"permMax": "write"}}, nil).
+		On("Run", []string{"protects", "-M", "-g", "P_old_rw", path}).Return(
+		[]map[interface{}]interface{}{{"permMax": "none"}}, nil)
+
+	for user, want := range map[string]bool{"owner.first": true, "owner.old": false, "a.user": false} {
+		res, err := ps.OwnsGrant(fp4, user, path, "write")
+		assert.Nil(t, err)
+		assert.Equal(t, want, res, user)
+	}
+	// Read-only groups aren't looked at for write
+	fp4.AssertNotCalled(t, "Run", []string{"group", "-o", "P_jam_ro"})
+}
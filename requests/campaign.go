@@ -0,0 +1,109 @@
+package requests
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// What owners decide about each member during a campaign
+const (
+	Keep   = "keep"
+	Remove = "remove"
+)
+
+// What happened to each member once a campaign closed
+const (
+	Kept      = "kept"
+	Removed   = "removed"
+	Escalated = "escalated" // Nobody confirmed them, they were left for someone to follow up
+	Failed    = "failed"    // They should have been removed but couldn't be
+)
+
+// Campaign is a round of owners re-confirming who belongs in their groups
+type Campaign struct {
+	ID       string     `json:"id"`
+	Reviews  []*Review  `json:"reviews"`
+	Deadline time.Time  `json:"deadline"`
+	Closed   *time.Time `json:"closed,omitempty"`
+	Created  time.Time  `json:"created"`
+	Updated  time.Time  `json:"updated"`
+}
+
+// Review is one group's members for its owners to keep or remove
+type Review struct {
+	Group   string        `json:"group"`
+	Owners  []prots.Owner `json:"owners"`
+	Members []*Member     `json:"members"`
+}
+
+// Member is a user named in a group and what was decided about them
+type Member struct {
+	User     string     `json:"user"`
+	Decision string     `json:"decision,omitempty"` // keep or remove, empty until an owner decides
+	By       string     `json:"by,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+	Outcome  string     `json:"outcome,omitempty"` // Set when the campaign closes
+}
+
+// NewCampaign starts a campaign that owners have until deadline to finish
+func NewCampaign(reviews []*Review, deadline time.Time) (*Campaign, error) {
+	id, err := newID("C")
+	if err != nil {
+		return nil, err
+	}
+	return &Campaign{
+		ID:       id,
+		Reviews:  reviews,
+		Deadline: deadline.UTC(),
+		Created:  time.Now().UTC(),
+	}, nil
+}
+
+// Review finds the review of a group
+func (c *Campaign) Review(group string) (*Review, error) {
+	for _, r := range c.Reviews {
+		if r.Group == group {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("Campaign %s doesn't review %s", c.ID, group)
+}
+
+// Due checks whether the deadline has passed on an open campaign
+func (c *Campaign) Due(now time.Time) bool {
+	return c.Closed == nil && !now.Before(c.Deadline)
+}
+
+// Member finds a user in the review
+func (r *Review) Member(user string) (*Member, error) {
+	for _, m := range r.Members {
+		if m.User == user {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%s isn't a member of %s in this review", user, r.Group)
+}
+
+// Undecided lists the members nobody has kept or removed yet
+func (r *Review) Undecided() []*Member {
+	out := []*Member{}
+	for _, m := range r.Members {
+		if m.Decision == "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Decide records an owner keeping or removing the member, they can change
+// their mind about a kept member but a removal has already happened
+func (m *Member) Decide(decision, by string, now time.Time) error {
+	if m.Decision == Remove {
+		return fmt.Errorf("%s has already been removed by %s", m.User, m.By)
+	}
+	now = now.UTC()
+	m.Decision, m.By, m.Time = decision, by, &now
+	return nil
+}
@@ -0,0 +1,109 @@
+package requests
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCampaign(t *testing.T, deadline time.Time) *Campaign {
+	c, err := NewCampaign([]*Review{{
+		Group:   "grp",
+		Owners:  []prots.Owner{{User: "owner.first"}},
+		Members: []*Member{{User: "a.user"}, {User: "b.user"}},
+	}}, deadline)
+	assert.Nil(t, err)
+	return c
+}
+
+func TestCampaign(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)
+	c := testCampaign(t, now.Add(time.Hour))
+	assert.Regexp(regexp.MustCompile(`^C[0-9a-f]{8}$`), c.ID)
+	assert.False(c.Due(now))
+	assert.True(c.Due(now.Add(time.Hour)))
+
+	_, err := c.Review("other")
+	assert.EqualError(err, "Campaign "+c.ID+" doesn't review other")
+	r, err := c.Review("grp")
+	assert.Nil(err)
+	_, err = r.Member("c.user")
+	assert.EqualError(err, "c.user isn't a member of grp in this review")
+
+	m, err := r.Member("a.user")
+	assert.Nil(err)
+	assert.Nil(m.Decide(Keep, "owner.first", now))
+	assert.Len(r.Undecided(), 1)
+	// Changing their mind about a kept member is fine
+	assert.Nil(m.Decide(Remove, "owner.first", now))
+	assert.EqualError(m.Decide(Keep, "owner.first", now), "a.user has already been removed by owner.first")
+	assert.Equal(Remove, m.Decision)
+	assert.Equal(now, *m.Time)
+
+	closed := now.Add(2 * time.Hour)
+	c.Closed = &closed
+	assert.False(c.Due(closed))
+}
+
+func TestStoreCampaigns(t *testing.T) {
+	assert := assert.New(t)
+	s := testStore(t)
+	c := testCampaign(t, time.Date(2021, 3, 23, 10, 0, 0, 0, time.UTC))
+	assert.Nil(s.AddCampaign(c))
+	assert.EqualError(s.AddCampaign(c), "Campaign "+c.ID+" already exists")
+	// Requests and campaigns share the file
+	assert.Nil(s.Add(testRequest("R1", "a.user", "grp", Pending)))
+
+	got, err := s.GetCampaign(c.ID)
+	assert.Nil(err)
+	assert.Equal(s.now(), got.Updated)
+	got.Reviews[0].Members[0].Decision = Keep
+	assert.Nil(s.UpdateCampaign(got))
+	all, err := s.Campaigns()
+	assert.Nil(err)
+	assert.Len(all, 1)
+	assert.Equal(Keep, all[0].Reviews[0].Members[0].Decision)
+
+	_, err = s.GetCampaign("C99999999")
+	assert.EqualError(err, "No such campaign 'C99999999'")
+	assert.EqualError(s.UpdateCampaign(&Campaign{ID: "C99999999"}), "No such campaign 'C99999999'")
+}
+
+func TestStoreModifyCampaign(t *testing.T) {
+	assert := assert.New(t)
+	s := testStore(t)
+	c := testCampaign(t, time.Date(2021, 3, 23, 10, 0, 0, 0, time.UTC))
+	assert.Nil(s.AddCampaign(c))
+	assert.EqualError(s.ModifyCampaign("C99999999", func(*Campaign) error { return nil }), "No such campaign 'C99999999'")
+
+	// Each change sees the ones before it
+	for i, user := range []string{"a.user", "b.user"} {
+		i, user := i, user
+		assert.Nil(s.ModifyCampaign(c.ID, func(saved *Campaign) error {
+			assert.Equal(i, 2-len(saved.Reviews[0].Undecided()))
+			m, err := saved.Reviews[0].Member(user)
+			if err != nil {
+				return err
+			}
+			return m.Decide(Keep, "owner.first", s.now())
+		}))
+	}
+	got, err := s.GetCampaign(c.ID)
+	assert.Nil(err)
+	assert.Empty(got.Reviews[0].Undecided())
+
+	// Nothing is saved when fn fails
+	err = s.ModifyCampaign(c.ID, func(saved *Campaign) error {
+		saved.Reviews[0].Members[0].Decision = Remove
+		return fmt.Errorf("no")
+	})
+	assert.EqualError(err, "no")
+	got, err = s.GetCampaign(c.ID)
+	assert.Nil(err)
+	assert.Equal(Keep, got.Reviews[0].Members[0].Decision)
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package requests
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileLock is an flock on a lock file
+type fileLock struct {
+	f *os.File
+}
+
+// lock waits for a lock on path, exclusive for writers and shared for readers
+func lock(path string, exclusive bool) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileLock{f}, nil
+}
+
+func (l *fileLock) unlock() {
+	syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	l.f.Close()
+}
@@ -0,0 +1,43 @@
+package requests
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// fileLock is a lock file that exists while the lock is held
+// There's no shared locking, readers wait for the lock like writers
+type fileLock struct {
+	path string
+}
+
+// staleLock is how old a lock file can be before we assume its owner died
+const staleLock = time.Minute
+
+// lock waits for the lock file at path to be free and creates it
+func lock(path string, exclusive bool) (*fileLock, error) {
+	deadline := time.Now().Add(2 * staleLock)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			f.Close()
+			return &fileLock{path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timed out waiting for lock %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (l *fileLock) unlock() {
+	os.Remove(l.path)
+}
@@ -0,0 +1,149 @@
+package requests
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/brettbates/p4access/prots"
+)
+
+// The states a request moves through
+const (
+	Pending  = "pending"
+	Approved = "approved"
+	Denied   = "denied"
+	Expired  = "expired" // Approved for a time that has now passed
+)
+
+// Request is a user asking to be added to a group, or a suggestion to take
+// them out of it again when Removal is set
+// Info is the same group, owners and protections line that 'p4 access read|write' shows
+type Request struct {
+	ID            string        `json:"id"`
+	User          string        `json:"user"`
+	Info          prots.Info    `json:"info"`
+	Justification string        `json:"justification"`
+	State         string        `json:"state"`
+	Approver      string        `json:"approver,omitempty"`  // The owner who approved or denied it
+	Reason        string        `json:"reason,omitempty"`    // The approver's comment, required when denying
+	Required      int           `json:"required,omitempty"`  // Approvals needed, 0 is the same as 1
+	Approvals     []Approval    `json:"approvals,omitempty"` // The approvals so far, the last completes it
+	Emergency     bool          `json:"emergency,omitempty"` // Break-glass access the user gave themselves
+	Removal       bool          `json:"removal,omitempty"`   // A suggestion to take the user out of the group
+	Expires       *time.Time    `json:"expires,omitempty"`   // When an approval lapses, nil is never
+	ExpiresIn     time.Duration `json:"expiresIn,omitempty"` // The shortest access approvers gave, Expires is set from it once approved
+	Removed       *time.Time    `json:"removed,omitempty"`   // When the user was taken out of the group
+	Created       time.Time     `json:"created"`
+	Updated       time.Time     `json:"updated"`
+}
+
+// Approval is one approver's say so on a request that needs several
+type Approval struct {
+	User   string    `json:"user"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// New creates a pending request with a fresh ID
+func New(user string, info prots.Info, justification string) (*Request, error) {
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+	return &Request{
+		ID:            id,
+		User:          user,
+		Info:          info,
+		Justification: justification,
+		State:         Pending,
+		Created:       time.Now().UTC(),
+	}, nil
+}
+
+// Decide approves or denies a pending request, a decision can't be changed
+func (r *Request) Decide(state, approver, reason string) error {
+	if r.State != Pending {
+		return fmt.Errorf("Request %s has already been %s by %s", r.ID, r.State, r.Approver)
+	}
+	r.State = state
+	r.Approver = approver
+	r.Reason = reason
+	return nil
+}
+
+// Approve adds the approver's approval, the request is approved once
+// it has the number required
+func (r *Request) Approve(approver, reason string, required int, now time.Time) error {
+	if r.State != Pending {
+		return r.Decide(Approved, approver, reason)
+	}
+	r.Required = required
+	for _, ap := range r.Approvals {
+		if ap.User == approver {
+			return fmt.Errorf("%s has already approved request %s, it needs %d more", approver, r.ID, r.Needed())
+		}
+	}
+	r.Approvals = append(r.Approvals, Approval{approver, reason, now.UTC()})
+	if r.Needed() > 0 {
+		return nil
+	}
+	return r.Decide(Approved, approver, reason)
+}
+
+// Lasts limits access to d from when the request is approved, with
+// several approvers the shortest any of them gives wins
+func (r *Request) Lasts(d time.Duration, now time.Time) {
+	if d != 0 && (r.ExpiresIn == 0 || d < r.ExpiresIn) {
+		r.ExpiresIn = d
+	}
+	if r.State == Approved && r.ExpiresIn != 0 {
+		expires := now.UTC().Add(r.ExpiresIn)
+		r.Expires = &expires
+	}
+}
+
+// Needed is how many more approvals a pending request needs
+func (r *Request) Needed() int {
+	if r.State != Pending {
+		return 0
+	}
+	required := r.Required
+	if required < 1 {
+		required = 1
+	}
+	if n := required - len(r.Approvals); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// Lapsed checks whether an approval's time is up
+func (r *Request) Lapsed(now time.Time) bool {
+	return r.State == Approved && r.Expires != nil && !now.Before(*r.Expires)
+}
+
+// Expire records that the user has been removed from the group
+func (r *Request) Expire(now time.Time) error {
+	if !r.Lapsed(now) {
+		return fmt.Errorf("Request %s hasn't lapsed", r.ID)
+	}
+	r.State = Expired
+	now = now.UTC()
+	r.Removed = &now
+	return nil
+}
+
+// NewID makes a short random ID that's easy to type, e.g. R3f9c2d1a
+func NewID() (string, error) {
+	return newID("R")
+}
+
+func newID(prefix string) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
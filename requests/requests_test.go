@@ -0,0 +1,115 @@
+package requests
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	info := prots.Info{Path: "//depot/...", Access: "write", Group: "grp"}
+	r, err := New("a.user", info, "because")
+	assert := assert.New(t)
+	assert.Nil(err)
+	assert.Regexp(regexp.MustCompile(`^R[0-9a-f]{8}$`), r.ID)
+	assert.Equal("a.user", r.User)
+	assert.Equal(info, r.Info)
+	assert.Equal("because", r.Justification)
+	assert.Equal(Pending, r.State)
+	assert.False(r.Created.IsZero())
+
+	r2, err := New("a.user", info, "because")
+	assert.Nil(err)
+	assert.NotEqual(r.ID, r2.ID)
+}
+
+func TestDecide(t *testing.T) {
+	assert := assert.New(t)
+	r, err := New("a.user", prots.Info{Group: "grp"}, "because")
+	assert.Nil(err)
+	assert.Nil(r.Decide(Denied, "owner.first", "Use the read group"))
+	assert.Equal(Denied, r.State)
+	assert.Equal("owner.first", r.Approver)
+	assert.Equal("Use the read group", r.Reason)
+
+	err = r.Decide(Approved, "owner.second", "")
+	assert.EqualError(err, "Request "+r.ID+" has already been denied by owner.first")
+	assert.Equal(Denied, r.State)
+}
+
+func TestApprove(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)
+	r, err := New("a.user", prots.Info{Group: "grp"}, "because")
+	assert.Nil(err)
+	assert.Equal(1, r.Needed())
+
+	assert.Nil(r.Approve("owner.first", "fine by me", 2, now))
+	assert.Equal(Pending, r.State)
+	assert.Equal(1, r.Needed())
+	assert.Empty(r.Approver)
+	assert.EqualError(r.Approve("owner.first", "", 2, now),
+		"owner.first has already approved request "+r.ID+", it needs 1 more")
+	assert.Len(r.Approvals, 1)
+
+	assert.Nil(r.Approve("owner.second", "ok", 2, now.Add(time.Hour)))
+	assert.Equal(Approved, r.State)
+	assert.Equal("owner.second", r.Approver)
+	assert.Equal("ok", r.Reason)
+	assert.Equal(0, r.Needed())
+	assert.Equal([]Approval{{"owner.first", "fine by me", now}, {"owner.second", "ok", now.Add(time.Hour)}}, r.Approvals)
+
+	assert.EqualError(r.Approve("owner.third", "", 2, now),
+		"Request "+r.ID+" has already been approved by owner.second")
+
+	// A single approval is enough by default
+	r, err = New("a.user", prots.Info{Group: "grp"}, "because")
+	assert.Nil(err)
+	assert.Nil(r.Approve("owner.first", "", 1, now))
+	assert.Equal(Approved, r.State)
+}
+
+func TestExpire(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)
+	r, err := New("a.user", prots.Info{Group: "grp"}, "because")
+	assert.Nil(err)
+	assert.False(r.Lapsed(now))
+	assert.Nil(r.Decide(Approved, "owner.first", ""))
+	// Approved forever
+	assert.False(r.Lapsed(now))
+
+	expires := now.Add(time.Hour)
+	r.Expires = &expires
+	assert.False(r.Lapsed(now))
+	assert.EqualError(r.Expire(now), "Request "+r.ID+" hasn't lapsed")
+	assert.True(r.Lapsed(expires))
+	assert.Nil(r.Expire(expires))
+	assert.Equal(Expired, r.State)
+	assert.Equal(expires, *r.Removed)
+	assert.False(r.Lapsed(expires))
+}
+
+func TestLasts(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)
+	r, err := New("a.user", prots.Info{Group: "grp"}, "because")
+	assert.Nil(err)
+	assert.Nil(r.Approve("owner.first", "", 2, now))
+	r.Lasts(7*24*time.Hour, now)
+	assert.Nil(r.Expires)
+
+	// A day later the second approver gives less, it runs from their approval
+	now = now.Add(24 * time.Hour)
+	assert.Nil(r.Approve("owner.second", "", 2, now))
+	r.Lasts(2*24*time.Hour, now)
+	assert.Equal(2*24*time.Hour, r.ExpiresIn)
+	assert.Equal(now.Add(2*24*time.Hour), *r.Expires)
+
+	// Longer doesn't override shorter
+	r.Lasts(7*24*time.Hour, now)
+	assert.Equal(now.Add(2*24*time.Hour), *r.Expires)
+}
@@ -0,0 +1,394 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Store keeps requests between runs of p4access
+type Store interface {
+	// Add saves a new request
+	Add(r *Request) error
+	// Get finds a request by its ID
+	Get(id string) (*Request, error)
+	// Update replaces a saved request with r, matching on ID
+	Update(r *Request) error
+	// Modify runs fn on the saved request with the given ID and saves the
+	// result under one lock, nothing is saved if fn returns an error
+	Modify(id string, fn func(*Request) error) error
+	// List returns the requests that match the filter, oldest first
+	List(f Filter) ([]*Request, error)
+	// AddCampaign saves a new recertification campaign
+	AddCampaign(c *Campaign) error
+	// GetCampaign finds a campaign by its ID
+	GetCampaign(id string) (*Campaign, error)
+	// UpdateCampaign replaces a saved campaign with c, matching on ID
+	UpdateCampaign(c *Campaign) error
+	// ModifyCampaign runs fn on the saved campaign with the given ID and saves
+	// the result under one lock, nothing is saved if fn returns an error
+	ModifyCampaign(id string, fn func(*Campaign) error) error
+	// Campaigns returns every campaign, oldest first
+	Campaigns() ([]*Campaign, error)
+}
+
+// Filter picks requests out of a store, empty fields match everything
+type Filter struct {
+	User  string
+	Group string
+	State string
+}
+
+// Match checks a request against the filter
+func (f Filter) Match(r *Request) bool {
+	return (f.User == "" || f.User == r.User) &&
+		(f.Group == "" || f.Group == r.Info.Group) &&
+		(f.State == "" || f.State == r.State)
+}
+
+// schemaVersion is the version of the store file this code writes
+// Bump it and add a migration whenever the file's layout changes
+const schemaVersion = 7
+
+// migrations upgrade the raw file one version at a time
+// migrations[i] takes a version i file to version i+1
+var migrations = []func(map[string]interface{}) error{
+	// 0 -> 1, the first version, an empty or new file
+	func(raw map[string]interface{}) error {
+		if _, ok := raw["requests"]; !ok {
+			raw["requests"] = []interface{}{}
+		}
+		return nil
+	},
+	// 1 -> 2, approvals can expire, nothing to change but older versions
+	// must not open the file or they would drop the expiry when writing it
+	func(raw map[string]interface{}) error {
+		return nil
+	},
+	// 2 -> 3, emergency break-glass requests, the same again
+	func(raw map[string]interface{}) error {
+		return nil
+	},
+	// 3 -> 4, requests can need several approvals, the approver of an
+	// older request becomes its one approval
+	func(raw map[string]interface{}) error {
+		rs, ok := raw["requests"].([]interface{})
+		if !ok {
+			return fmt.Errorf("requests is not a list")
+		}
+		for _, v := range rs {
+			r, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("request is not an object")
+			}
+			approver, _ := r["approver"].(string)
+			if approver == "" || r["state"] == Denied {
+				continue
+			}
+			ap := map[string]interface{}{"user": approver, "time": r["updated"]}
+			if reason, ok := r["reason"]; ok {
+				ap["reason"] = reason
+			}
+			r["approvals"] = []interface{}{ap}
+		}
+		return nil
+	},
+	// 4 -> 5, recertification campaigns are kept alongside the requests
+	func(raw map[string]interface{}) error {
+		if _, ok := raw["campaigns"]; !ok {
+			raw["campaigns"] = []interface{}{}
+		}
+		return nil
+	},
+	// 5 -> 6, suggestions to remove stale members, nothing to change but
+	// older versions would approve them by adding the user
+	func(raw map[string]interface{}) error {
+		return nil
+	},
+	// 6 -> 7, pending requests keep the length of access approvers gave
+	// rather than an expiry, older versions would drop it
+	func(raw map[string]interface{}) error {
+		return nil
+	},
+}
+
+// storeFile is the layout of the store on disk
+type storeFile struct {
+	Version   int         `json:"version"`
+	Requests  []*Request  `json:"requests"`
+	Campaigns []*Campaign `json:"campaigns"`
+}
+
+// FileStore keeps requests in a single json file
+// Every operation takes a lock on path.lock, so concurrent broker
+// invocations see each other's changes and never lose an update
+type FileStore struct {
+	path string
+	now  func() time.Time
+}
+
+// NewFileStore returns a store using the file at path, it is created when first written
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path, time.Now}
+}
+
+// Add saves a new request
+func (s *FileStore) Add(r *Request) error {
+	return s.update(func(f *storeFile) error {
+		for _, o := range f.Requests {
+			if o.ID == r.ID {
+				return fmt.Errorf("Request %s already exists", r.ID)
+			}
+		}
+		r.Updated = s.now().UTC()
+		f.Requests = append(f.Requests, r)
+		return nil
+	})
+}
+
+// Get finds a request by its ID
+func (s *FileStore) Get(id string) (*Request, error) {
+	var out *Request
+	err := s.view(func(f *storeFile) error {
+		for _, r := range f.Requests {
+			if r.ID == id {
+				out = r
+				return nil
+			}
+		}
+		return fmt.Errorf("No such request '%s'", id)
+	})
+	return out, err
+}
+
+// Update replaces a saved request with r, matching on ID
+func (s *FileStore) Update(r *Request) error {
+	return s.update(func(f *storeFile) error {
+		for i, o := range f.Requests {
+			if o.ID == r.ID {
+				r.Updated = s.now().UTC()
+				f.Requests[i] = r
+				return nil
+			}
+		}
+		return fmt.Errorf("No such request '%s'", r.ID)
+	})
+}
+
+// Modify runs fn on the saved request with the given ID and saves the
+// result, nothing is saved if fn returns an error
+// fn sees the request as it is inside the lock, so a decision made there
+// can't be lost to another one made at the same time
+func (s *FileStore) Modify(id string, fn func(*Request) error) error {
+	return s.update(func(f *storeFile) error {
+		for _, r := range f.Requests {
+			if r.ID == id {
+				if err := fn(r); err != nil {
+					return err
+				}
+				r.Updated = s.now().UTC()
+				return nil
+			}
+		}
+		return fmt.Errorf("No such request '%s'", id)
+	})
+}
+
+// List returns the requests that match the filter, oldest first
+func (s *FileStore) List(flt Filter) ([]*Request, error) {
+	out := []*Request{}
+	err := s.view(func(f *storeFile) error {
+		for _, r := range f.Requests {
+			if flt.Match(r) {
+				out = append(out, r)
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// AddCampaign saves a new recertification campaign
+func (s *FileStore) AddCampaign(c *Campaign) error {
+	return s.update(func(f *storeFile) error {
+		for _, o := range f.Campaigns {
+			if o.ID == c.ID {
+				return fmt.Errorf("Campaign %s already exists", c.ID)
+			}
+		}
+		c.Updated = s.now().UTC()
+		f.Campaigns = append(f.Campaigns, c)
+		return nil
+	})
+}
+
+// GetCampaign finds a campaign by its ID
+func (s *FileStore) GetCampaign(id string) (*Campaign, error) {
+	var out *Campaign
+	err := s.view(func(f *storeFile) error {
+		for _, c := range f.Campaigns {
+			if c.ID == id {
+				out = c
+				return nil
+			}
+		}
+		return fmt.Errorf("No such campaign '%s'", id)
+	})
+	return out, err
+}
+
+// UpdateCampaign replaces a saved campaign with c, matching on ID
+func (s *FileStore) UpdateCampaign(c *Campaign) error {
+	return s.update(func(f *storeFile) error {
+		for i, o := range f.Campaigns {
+			if o.ID == c.ID {
+				c.Updated = s.now().UTC()
+				f.Campaigns[i] = c
+				return nil
+			}
+		}
+		return fmt.Errorf("No such campaign '%s'", c.ID)
+	})
+}
+
+// ModifyCampaign runs fn on the saved campaign with the given ID and saves
+// the result, nothing is saved if fn returns an error
+// Like Modify, fn sees the campaign as it is inside the lock
+func (s *FileStore) ModifyCampaign(id string, fn func(*Campaign) error) error {
+	return s.update(func(f *storeFile) error {
+		for _, c := range f.Campaigns {
+			if c.ID == id {
+				if err := fn(c); err != nil {
+					return err
+				}
+				c.Updated = s.now().UTC()
+				return nil
+			}
+		}
+		return fmt.Errorf("No such campaign '%s'", id)
+	})
+}
+
+// Campaigns returns every campaign, oldest first
+func (s *FileStore) Campaigns() ([]*Campaign, error) {
+	out := []*Campaign{}
+	err := s.view(func(f *storeFile) error {
+		out = append(out, f.Campaigns...)
+		return nil
+	})
+	return out, err
+}
+
+// view runs fn against the store under a shared lock
+func (s *FileStore) view(fn func(*storeFile) error) error {
+	l, err := lock(s.path+".lock", false)
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+	return fn(f)
+}
+
+// update runs fn against the store under an exclusive lock and saves the result
+func (s *FileStore) update(fn func(*storeFile) error) error {
+	l, err := lock(s.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		return err
+	}
+	return s.write(f)
+}
+
+// read loads the store, migrating it to the current schema if needed
+func (s *FileStore) read() (*storeFile, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return &storeFile{schemaVersion, []*Request{}, []*Campaign{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return nil, fmt.Errorf("Failed to read request store %s, %v", s.path, err)
+		}
+	}
+	if err := migrate(raw); err != nil {
+		return nil, fmt.Errorf("Failed to migrate request store %s, %v", s.path, err)
+	}
+	// Round trip the migrated file into the current layout
+	b, err = json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	f := &storeFile{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("Failed to read request store %s, %v", s.path, err)
+	}
+	return f, nil
+}
+
+// migrate upgrades a raw store file to schemaVersion
+func migrate(raw map[string]interface{}) error {
+	version := 0
+	if v, ok := raw["version"]; ok {
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("version %v isn't a number", v)
+		}
+		version = int(f)
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("version %d is newer than this p4access understands (%d)", version, schemaVersion)
+	}
+	for ; version < schemaVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return fmt.Errorf("version %d to %d, %v", version, version+1, err)
+		}
+	}
+	raw["version"] = schemaVersion
+	return nil
+}
+
+// write saves the store by writing a temporary file and renaming it over the
+// old one, so a crash part way through can't leave a half written store
+func (s *FileStore) write(f *storeFile) error {
+	f.Version = schemaVersion
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// The temporary file is only readable by its owner, approvals and
+	// expiries mustn't be editable by anyone else on the machine
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,191 @@
+package requests
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brettbates/p4access/prots"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T) *FileStore {
+	s := NewFileStore(filepath.Join(t.TempDir(), "requests.json"))
+	s.now = func() time.Time { return time.Date(2021, 3, 9, 10, 18, 1, 0, time.UTC) }
+	return s
+}
+
+func testRequest(id, user, group, state string) *Request {
+	return &Request{
+		ID:            id,
+		User:          user,
+		Info:          prots.Info{Path: "//depot/...", Access: "write", Group: group},
+		Justification: "because",
+		State:         state,
+		Created:       time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestStore(t *testing.T) {
+	assert := assert.New(t)
+	s := testStore(t)
+
+	// An empty store doesn't need a file
+	res, err := s.List(Filter{})
+	assert.Nil(err)
+	assert.Empty(res)
+
+	r1 := testRequest("R1", "a.user", "grp", Pending)
+	r2 := testRequest("R2", "b.user", "grp", Pending)
+	r3 := testRequest("R3", "a.user", "grp2", Pending)
+	for _, r := range []*Request{r1, r2, r3} {
+		assert.Nil(s.Add(r))
+	}
+	assert.EqualError(s.Add(testRequest("R1", "c.user", "grp", Pending)), "Request R1 already exists")
+
+	got, err := s.Get("R2")
+	assert.Nil(err)
+	assert.Equal(r2, got)
+	assert.Equal(s.now(), got.Updated)
+	_, err = s.Get("R9")
+	assert.EqualError(err, "No such request 'R9'")
+
+	r2.State = Approved
+	r2.Approver = "owner.first"
+	assert.Nil(s.Update(r2))
+	got, err = s.Get("R2")
+	assert.Nil(err)
+	assert.Equal(Approved, got.State)
+	assert.Equal("owner.first", got.Approver)
+	assert.EqualError(s.Update(testRequest("R9", "c.user", "grp", Pending)), "No such request 'R9'")
+
+	res, err = s.List(Filter{User: "a.user"})
+	assert.Nil(err)
+	assert.Equal([]*Request{r1, r3}, res)
+	res, err = s.List(Filter{Group: "grp", State: Pending})
+	assert.Nil(err)
+	assert.Equal([]*Request{r1}, res)
+}
+
+func TestStorePrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't have unix permissions")
+	}
+	s := testStore(t)
+	assert.Nil(t, s.Add(testRequest("R1", "a.user", "grp", Pending)))
+	fi, err := os.Stat(s.path)
+	assert.Nil(t, err)
+	// Nobody else can add approvals or clear expiries
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	// Each broker invocation is its own process, but flock locks are per
+	// open file so goroutines opening the lock separately behave the same
+	s := testStore(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Nil(t, s.Add(testRequest(fmt.Sprintf("R%d", i), "a.user", "grp", Pending)))
+		}(i)
+	}
+	wg.Wait()
+	res, err := s.List(Filter{})
+	assert.Nil(t, err)
+	assert.Len(t, res, 20)
+}
+
+func TestStoreModify(t *testing.T) {
+	assert := assert.New(t)
+	s := testStore(t)
+	assert.Nil(s.Add(testRequest("R1", "a.user", "grp", Pending)))
+	assert.EqualError(s.Modify("R9", func(r *Request) error { return nil }), "No such request 'R9'")
+
+	// Nothing is saved when fn fails
+	err := s.Modify("R1", func(r *Request) error {
+		r.State = Denied
+		return fmt.Errorf("no")
+	})
+	assert.EqualError(err, "no")
+	got, err := s.Get("R1")
+	assert.Nil(err)
+	assert.Equal(Pending, got.State)
+
+	// Concurrent changes each see the one before, so none are lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Nil(s.Modify("R1", func(r *Request) error {
+				r.Approvals = append(r.Approvals, Approval{User: fmt.Sprintf("owner%d", i)})
+				return nil
+			}))
+		}(i)
+	}
+	wg.Wait()
+	got, err = s.Get("R1")
+	assert.Nil(err)
+	assert.Len(got.Approvals, 20)
+}
+
+func TestStoreMigrate(t *testing.T) {
+	assert := assert.New(t)
+	s := testStore(t)
+
+	// A file from before versions were recorded
+	assert.Nil(ioutil.WriteFile(s.path, []byte(`{}`), 0666))
+	res, err := s.List(Filter{})
+	assert.Nil(err)
+	assert.Empty(res)
+
+	// Files from a newer p4access are left alone
+	assert.Nil(ioutil.WriteFile(s.path, []byte(`{"version": 99, "requests": []}`), 0666))
+	_, err = s.List(Filter{})
+	assert.EqualError(err, fmt.Sprintf("Failed to migrate request store %s, version 99 is newer than this p4access understands (%d)",
+		s.path, schemaVersion))
+
+	// Version 1 requests carry over, and the file is written back as the current version
+	assert.Nil(ioutil.WriteFile(s.path, []byte(`{"version": 1, "requests": [{"id": "R1", "user": "a.user", "state": "approved"}]}`), 0666))
+	res, err = s.List(Filter{})
+	assert.Nil(err)
+	assert.Len(res, 1)
+	assert.Nil(res[0].Expires)
+	assert.Nil(s.Update(res[0]))
+	b, err := ioutil.ReadFile(s.path)
+	assert.Nil(err)
+	assert.Contains(string(b), fmt.Sprintf(`"version": %d`, schemaVersion))
+
+	// Version 3 approvers become the single approval, denials don't count
+	assert.Nil(ioutil.WriteFile(s.path, []byte(`{"version": 3, "requests": [
+		{"id": "R1", "user": "a.user", "state": "approved", "approver": "owner.first", "reason": "ok", "updated": "2021-03-09T10:00:00Z"},
+		{"id": "R2", "user": "a.user", "state": "denied", "approver": "owner.first", "reason": "no"},
+		{"id": "R3", "user": "a.user", "state": "pending"}
+	]}`), 0666))
+	res, err = s.List(Filter{})
+	assert.Nil(err)
+	assert.Len(res, 3)
+	assert.Equal([]Approval{{"owner.first", "ok", time.Date(2021, 3, 9, 10, 0, 0, 0, time.UTC)}}, res[0].Approvals)
+	assert.Empty(res[1].Approvals)
+	assert.Empty(res[2].Approvals)
+
+	assert.Nil(ioutil.WriteFile(s.path, []byte(`not json`), 0666))
+	_, err = s.List(Filter{})
+	assert.Error(err)
+}
+
+func TestMigrate(t *testing.T) {
+	// Every version from 0 must end up at the current schema
+	raw := map[string]interface{}{}
+	assert.Nil(t, migrate(raw))
+	assert.Equal(t, schemaVersion, raw["version"])
+	assert.Equal(t, []interface{}{}, raw["requests"])
+	assert.Equal(t, []interface{}{}, raw["campaigns"])
+}